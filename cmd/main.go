@@ -1,21 +1,35 @@
 package main
 
 import (
+    "context"
+    "flag"
     "fmt"
     "log"
     "net/http"
     "os"
+    "os/signal"
+    "syscall"
+    "time"
 
     "github.com/gin-gonic/gin"
-    "github.com/jinzhu/gorm"
-    _ "github.com/lib/pq"
+    "github.com/redis/go-redis/v9"
+    "gorm.io/driver/postgres"
+    "gorm.io/driver/sqlite"
+    "gorm.io/gorm"
 
     "github.com/PhosFactum/kvant-backend-practicum/docs"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/cache"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/config"
     "github.com/PhosFactum/kvant-backend-practicum/internal/handlers"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/health"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/metrics"
     "github.com/PhosFactum/kvant-backend-practicum/internal/middleware"
     "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/ratelimit"
     "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
     "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/tracing"
 
     swaggerFiles "github.com/swaggo/files"
     ginSwagger "github.com/swaggo/gin-swagger"
@@ -49,33 +63,101 @@ import (
 // @tag.name Auth
 // @tag.description Аутентификация и получение JWT-токена
 
+// @tag.name Privacy
+// @tag.description Выгрузка и удаление персональных данных
+
+// @tag.name Reports
+// @tag.description Поиск заказов и отчёты для администраторов
+
+// @tag.name Audit
+// @tag.description Журнал изменений (только для администраторов)
+
+// @tag.name Health
+// @tag.description Проверки работоспособности сервиса
+
 // @x-logo {"url": "https://kvant-team.com/logo.png", "backgroundColor": "#FFFFFF", "altText": "KVANT Logo"}
 func main() {
-    // Initialize DB connection
-    db := initDB()
-    defer db.Close()
+    cfg, err := config.Load()
+    if err != nil {
+        log.Fatal("invalid configuration:", err)
+    }
 
-    // Migrate schema
-    db.AutoMigrate(&models.User{}, &models.Order{})
+    flag.StringVar(&cfg.Storage, "storage", cfg.Storage, "storage backend: postgres, sqlite or memory")
+    flag.Parse()
+
+    // Stop on Ctrl+C or SIGTERM (docker stop)
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    shutdownTracing, err := tracing.Setup(context.Background(), cfg.TraceExporter)
+    if err != nil {
+        log.Fatal("tracing setup failed:", err)
+    }
+    defer shutdownTracing(context.Background())
 
     // Initialize repositories
-    userRepo := repository.NewGormUserRepo(db)
-    orderRepo := repository.NewGormOrderRepo(db)
+    repos, closeStorage := initStorage(ctx, cfg)
+    defer closeStorage()
+    caches, closeCache := initCache(cfg.Cache, &repos)
+    defer closeCache()
+    limiter, closeLimiter := initRateLimit(cfg.RateLimit, cfg.Cache)
+    defer closeLimiter()
 
     // Initialize services
-    authSvc := services.NewAuthService(userRepo)
-    userSvc := services.NewUserService(userRepo)
-    orderSvc := services.NewOrderService(userRepo, orderRepo)
+    auditSvc := services.NewTracedAuditService(services.NewAuditService(repos.audit))
+    authSvc := services.NewTracedAuthService(services.NewAuthService(repos.users, cfg.JWTSecret))
+    userSvc := services.NewTracedUserService(services.NewUserService(repos.users, auditSvc, repos.tx))
+    orderSvc := services.NewTracedOrderService(services.NewOrderService(repos.users, repos.orders, auditSvc, repos.tx))
+    privacySvc := services.NewTracedPrivacyService(services.NewPrivacyService(repos.users, repos.orders, repos.jobs, auditSvc, cfg.ExportDir))
+    importSvc := services.NewTracedImportService(services.NewImportService(repos.users, repos.jobs, auditSvc, repos.tx))
+    reportSvc := services.NewTracedReportService(services.NewReportService(repos.reports))
+    idempotencySvc := services.NewTracedIdempotencyService(services.NewIdempotencyService(repos.idempotency, cfg.IdempotencyTTL))
 
     // Initialize handlers
+    cursors := query.NewCursorCodec(cfg.CursorSecret)
     authH := handlers.NewAuthHandler(authSvc)
-    userH := handlers.NewUserHandler(userSvc)
-    orderH := handlers.NewOrderHandler(orderSvc)
+    userH := handlers.NewUserHandler(userSvc, cursors)
+    orderH := handlers.NewOrderHandler(orderSvc, cursors)
+    auditH := handlers.NewAuditHandler(auditSvc)
+    privacyH := handlers.NewPrivacyHandler(privacySvc)
+    importH := handlers.NewImportHandler(importSvc)
+    reportH := handlers.NewReportHandler(reportSvc, cursors)
+    cacheH := handlers.NewCacheHandler(caches...)
+
+    // Readiness checks
+    checker := health.New(cfg.HealthCheckTimeout)
+    if repos.db != nil {
+        checker.Add("database", repos.db.Ping)
+        checker.Add("migrations", func(ctx context.Context) error {
+            return repos.db.CheckSchema(ctx, schema...)
+        })
+    }
+    healthH := handlers.NewHealthHandler(checker)
+
+    // Background workers
+    go startPurgeWorker(ctx, userSvc, cfg.PurgeInterval, cfg.SoftDeleteRetention, checker.Worker("purge_worker", cfg.PurgeInterval))
+    go startIdempotencyCleanup(ctx, idempotencySvc, cfg.PurgeInterval, checker.Worker("idempotency_cleanup", cfg.PurgeInterval))
+    go startExportCleanup(ctx, privacySvc, cfg.PurgeInterval, cfg.ExportRetention, checker.Worker("export_cleanup", cfg.PurgeInterval))
 
     // Setup router
     router := gin.Default()
+    // The client IP keys rate limits and the audit log, so X-Forwarded-For
+    // is only believed from the configured proxies
+    if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+        log.Fatal("invalid TRUSTED_PROXIES:", err)
+    }
+    router.Use(middleware.TracingMiddleware())
+    router.Use(middleware.RequestMetaMiddleware())
+    router.Use(middleware.MetricsMiddleware())
+    router.Use(middleware.PrimaryReadsMiddleware())
+    if limiter != nil {
+        router.Use(middleware.RateLimitMiddleware(limiter, middleware.NewAPIKeys(cfg.APIKeys), cfg.JWTSecret))
+    }
     docs.SwaggerInfo.BasePath = "/"
     router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+    router.GET("/metrics", gin.WrapH(metrics.Handler()))
+    router.GET("/healthz", healthH.Liveness)
+    router.GET("/readyz", healthH.Readiness)
 
     // Public endpoints
     router.POST("/auth/login", authH.Login)
@@ -85,47 +167,320 @@ func main() {
 
     // Protected endpoints
     protected := router.Group("/")
-    protected.Use(middleware.JWTAuthMiddleware())
+    protected.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
     {
         protected.PUT("/user/:id", userH.UpdateUser)
+        protected.PATCH("/user/:id", userH.PatchUser)
         protected.DELETE("/user/:id", userH.DeleteUser)
 
         userGroup := protected.Group("/users/:user_id")
         {
-            userGroup.POST("/orders", orderH.CreateOrder)
+            userGroup.POST("/orders", middleware.Idempotency(idempotencySvc), orderH.CreateOrder)
+            userGroup.POST("/orders/batch", middleware.Idempotency(idempotencySvc), orderH.CreateOrdersBatch)
             userGroup.GET("/orders", orderH.GetOrdersByUser)
+            userGroup.GET("/orders/:id", orderH.GetOrder)
+            userGroup.PATCH("/orders/:id", orderH.UpdateOrder)
+            userGroup.DELETE("/orders/:id", orderH.DeleteOrder)
+            userGroup.GET("/export", privacyH.ExportUser)
+            userGroup.POST("/erase", privacyH.EraseUser)
+        }
+
+        protected.GET("/data-requests/:id", privacyH.GetDataRequest)
+        protected.GET("/data-requests/:id/download", privacyH.DownloadExport)
+
+        adminGroup := protected.Group("/admin")
+        adminGroup.Use(middleware.RequireAdmin())
+        {
+            adminGroup.GET("/audit", auditH.ListAuditEntries)
+            adminGroup.GET("/audit/verify", auditH.VerifyAuditChain)
+            adminGroup.GET("/users", userH.ListUsersAdmin)
+            adminGroup.POST("/users/:id/restore", userH.RestoreUser)
+            adminGroup.POST("/users/import", importH.ImportUsers)
+            adminGroup.GET("/users/export", userH.ExportUsers)
+            adminGroup.GET("/imports/:id", importH.GetImport)
+            adminGroup.GET("/orders", reportH.SearchOrders)
+            adminGroup.GET("/orders/export", reportH.ExportOrders)
+            adminGroup.GET("/reports/revenue", reportH.Revenue)
+            adminGroup.GET("/reports/top-products", reportH.TopProducts)
+            adminGroup.GET("/reports/top-customers", reportH.TopCustomers)
+            adminGroup.GET("/reports/average-order-value", reportH.AverageOrderValue)
+            adminGroup.GET("/cache", cacheH.GetCacheStats)
+        }
+    }
+
+    // A rate limit rule for a route that does not exist is most likely a typo
+    routes := make(map[string]bool)
+    for _, ri := range router.Routes() {
+        routes[ri.Method+" "+ri.Path] = true
+    }
+    for _, rule := range cfg.RateLimit.Rules {
+        if !routes[rule.Method+" "+rule.Route] {
+            log.Printf("rate limit rule for unknown route %s %s is ignored", rule.Method, rule.Route)
         }
     }
 
     // Start HTTP server
-    port := os.Getenv("PORT")
-    if port == "" {
-        port = "8080"
+    srv := &http.Server{Addr: fmt.Sprintf(":%s", cfg.Port), Handler: router}
+    go func() {
+        log.Printf("starting server on %s", srv.Addr)
+        if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            log.Fatal("server failed:", err)
+        }
+    }()
+
+    // Graceful shutdown: fail the readiness, then let requests in flight finish
+    <-ctx.Done()
+    stop()
+    log.Print("shutting down")
+    checker.Shutdown()
+    time.Sleep(cfg.ShutdownDelay)
+
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+    defer cancel()
+    if err := srv.Shutdown(shutdownCtx); err != nil {
+        log.Printf("server shutdown failed: %v", err)
     }
-    addr := fmt.Sprintf(":%s", port)
-    log.Printf("starting server on %s", addr)
-    if err := http.ListenAndServe(addr, router); err != nil {
-        log.Fatal("server failed:", err)
+}
+
+// schema are the models migrated at start
+var schema = []interface{}{&models.User{}, &models.Order{}, &models.AuditEntry{}, &models.Job{}, &models.IdempotencyKey{}}
+
+// repositories are the storage the services are built on
+type repositories struct {
+    users       repository.UserRepository
+    orders      repository.OrderRepository
+    audit       repository.AuditRepository
+    jobs        repository.JobRepository
+    reports     repository.ReportRepository
+    idempotency repository.IdempotencyRepository
+    tx          repository.TxManager
+
+    // db is the database of the GORM repositories, nil for in-memory storage
+    db *repository.DB
+}
+
+// initStorage creates the repositories of the configured backend and returns
+// them with a function releasing the backend. The replicas are monitored until
+// ctx is done
+func initStorage(ctx context.Context, cfg *config.Config) (repositories, func()) {
+    switch cfg.Storage {
+    case config.StorageMemory:
+        log.Print("using in-memory storage: data is lost on exit")
+        store := repository.NewMemoryStore()
+        return repositories{
+            users:       repository.NewMemoryUserRepo(store),
+            orders:      repository.NewMemoryOrderRepo(store),
+            audit:       repository.NewMemoryAuditRepo(store),
+            jobs:        repository.NewMemoryJobRepo(store),
+            reports:     repository.NewMemoryReportRepo(store),
+            idempotency: repository.NewMemoryIdempotencyRepo(store),
+            tx:          repository.NewMemoryTxManager(store),
+        }, func() {}
+    case config.StoragePostgres, config.StorageSQLite:
+    default:
+        log.Fatalf("unknown storage %q", cfg.Storage)
+    }
+
+    db := initDB(cfg.Storage, cfg.DB, false)
+    sqlDB, err := db.DB()
+    if err != nil {
+        log.Fatal("database connection failed:", err)
+    }
+    closers := []func() error{sqlDB.Close}
+    metrics.RegisterDB("primary", sqlDB)
+    if err := repository.TraceQueries(db, "primary"); err != nil {
+        log.Fatal("query tracing setup failed:", err)
+    }
+
+    // Migrate schema
+    if err := repository.Migrate(db, schema...); err != nil {
+        log.Fatal("schema migration failed:", err)
+    }
+
+    store := repository.NewDB(db, repository.Timeouts{Read: cfg.DB.ReadTimeout, Write: cfg.DB.WriteTimeout})
+    for i, rc := range cfg.DB.ReplicaConfigs(cfg.Storage) {
+        replica := initDB(cfg.Storage, rc, true)
+        replicaDB, err := replica.DB()
+        if err != nil {
+            log.Fatal("database connection failed:", err)
+        }
+        closers = append(closers, replicaDB.Close)
+        metrics.RegisterDB(fmt.Sprintf("replica%d", i+1), replicaDB)
+        if err := repository.TraceQueries(replica, fmt.Sprintf("replica%d", i+1)); err != nil {
+            log.Fatal("query tracing setup failed:", err)
+        }
+        store.AddReplica(cfg.DB.Replicas[i], replica)
+    }
+    go store.MonitorReplicas(ctx, cfg.DB.ReplicaCheckInterval)
+
+    return repositories{
+        users:       repository.NewGormUserRepo(store),
+        orders:      repository.NewGormOrderRepo(store),
+        audit:       repository.NewGormAuditRepo(store),
+        jobs:        repository.NewGormJobRepo(store),
+        reports:     repository.NewGormReportRepo(store),
+        idempotency: repository.NewGormIdempotencyRepo(store),
+        tx:          repository.NewGormTxManager(store),
+        db:          store,
+    }, func() {
+        for _, c := range closers {
+            c()
+        }
     }
 }
 
-// initDB builds the Postgres DSN and opens a GORM connection
-func initDB() *gorm.DB {
-    host := os.Getenv("DB_HOST")
-    user := os.Getenv("DB_USER")
-    pass := os.Getenv("DB_PASSWORD")
-    name := os.Getenv("DB_NAME")
-    port := os.Getenv("DB_PORT")
+// initCache puts the configured cache in front of the user and order
+// repositories and returns the caches with a function releasing the backend
+func initCache(cfg config.CacheConfig, repos *repositories) ([]*cache.Cache, func()) {
+    var store cache.Store
+    closeStore := func() {}
+    switch cfg.Backend {
+    case config.CacheNone:
+        return nil, closeStore
+    case config.CacheLRU:
+        store = cache.NewLRU(cfg.Size)
+    case config.CacheRedis:
+        client := newRedisClient(cfg)
+        store = cache.NewRedis(client, "kvant:")
+        closeStore = func() { client.Close() }
+    default:
+        log.Fatalf("unknown cache %q", cfg.Backend)
+    }
+
+    users := cache.New("users", store, cfg.TTL)
+    orders := cache.New("orders", store, cfg.TTL)
+    repos.users = repository.NewCachedUserRepo(repos.users, users)
+    repos.orders = repository.NewCachedOrderRepo(repos.orders, orders)
+    metrics.RegisterCache(users)
+    metrics.RegisterCache(orders)
+    return []*cache.Cache{users, orders}, closeStore
+}
+
+// initRateLimit creates the rate limiter of the configured rules, nil if rate
+// limiting is off, and a function releasing its store. The Redis store uses
+// the Redis server of the cache
+func initRateLimit(cfg config.RateLimitConfig, redisCfg config.CacheConfig) (*ratelimit.Limiter, func()) {
+    var store ratelimit.Store
+    closeStore := func() {}
+    switch cfg.Backend {
+    case config.RateLimitNone:
+        return nil, closeStore
+    case config.RateLimitMemory:
+        store = ratelimit.NewMemory()
+    case config.RateLimitRedis:
+        client := newRedisClient(redisCfg)
+        store = ratelimit.NewRedis(client, "kvant:ratelimit:")
+        closeStore = func() { client.Close() }
+    default:
+        log.Fatalf("unknown rate limit store %q", cfg.Backend)
+    }
 
-    dsn := fmt.Sprintf(
-        "host=%s port=%s user=%s dbname=%s password=%s sslmode=disable TimeZone=UTC",
-        host, port, user, name, pass,
-    )
+    policies := make(map[string]ratelimit.Policy, len(cfg.Rules))
+    for _, rule := range cfg.Rules {
+        policies[rule.Method+" "+rule.Route] = ratelimit.Policy{Limit: rule.Limit, Period: rule.Period, Key: rule.Key}
+    }
+    return ratelimit.New(store, policies), closeStore
+}
+
+// newRedisClient connects to the Redis server of cfg
+func newRedisClient(cfg config.CacheConfig) *redis.Client {
+    return redis.NewClient(&redis.Options{
+        Addr:     cfg.RedisAddr,
+        Password: cfg.RedisPassword,
+        DB:       cfg.RedisDB,
+        // Without Redis the service goes on without it; don't wait long for it
+        DialTimeout:  time.Second,
+        ReadTimeout:  500 * time.Millisecond,
+        WriteTimeout: 500 * time.Millisecond,
+    })
+}
+
+// initDB opens a GORM connection with the driver of the storage backend
+// (pgx for Postgres) and tunes its pool, see repository.Open
+func initDB(storage string, cfg config.DBConfig, replica bool) *gorm.DB {
+    dialector := postgres.Open(cfg.DSN())
+    if storage == config.StorageSQLite {
+        dialector = sqlite.Open(cfg.SQLiteDSN())
+    }
+
+    db, err := repository.Open(dialector, replica)
+    if err != nil {
+        log.Fatal("database connection failed:", err)
+    }
 
-    db, err := gorm.Open("postgres", dsn)
+    sqlDB, err := db.DB()
     if err != nil {
         log.Fatal("database connection failed:", err)
     }
+    sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+    sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+    sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+    sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
     return db
 }
 
+// startPurgeWorker periodically hard-deletes users soft-deleted longer than retention ago
+func startPurgeWorker(ctx context.Context, svc services.UserService, interval, retention time.Duration, w *health.Worker) {
+    defer w.Stop()
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        n, err := svc.PurgeDeleted(ctx, retention)
+        if err != nil {
+            log.Printf("purge of deleted users failed: %v", err)
+        } else if n > 0 {
+            log.Printf("purged %d deleted users", n)
+        }
+        w.Beat()
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// startIdempotencyCleanup periodically deletes expired idempotency keys
+func startIdempotencyCleanup(ctx context.Context, svc services.IdempotencyService, interval time.Duration, w *health.Worker) {
+    defer w.Stop()
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        if _, err := svc.PurgeExpired(ctx); err != nil {
+            log.Printf("cleanup of idempotency keys failed: %v", err)
+        }
+        w.Beat()
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// startExportCleanup periodically deletes export archives older than retention
+func startExportCleanup(ctx context.Context, svc services.PrivacyService, interval, retention time.Duration, w *health.Worker) {
+    defer w.Stop()
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        n, err := svc.ExpireExports(ctx, retention)
+        if err != nil {
+            log.Printf("cleanup of export archives failed: %v", err)
+        } else if n > 0 {
+            log.Printf("deleted %d expired export archives", n)
+        }
+        w.Beat()
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
@@ -0,0 +1,152 @@
+// internal/cache/cache.go
+package cache
+
+import (
+    "bytes"
+    "context"
+    "crypto/rand"
+    "encoding/gob"
+    "encoding/hex"
+    "errors"
+    "log"
+    "sync/atomic"
+    "time"
+
+    "golang.org/x/sync/singleflight"
+)
+
+// ErrMiss is returned by Store.Get when the key is absent or expired.
+var ErrMiss = errors.New("cache miss")
+
+// Store is a key-value store with expiring entries: the in-process LRU or
+// Redis, or anything offering the same three commands.
+type Store interface {
+    // Get returns the value of key, or ErrMiss.
+    Get(ctx context.Context, key string) ([]byte, error)
+    // Set stores value under key for ttl.
+    Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+    // Del removes the keys; absent keys are not an error.
+    Del(ctx context.Context, keys ...string) error
+}
+
+// Stats counts the lookups of a Cache. Errors are failed Store calls; the
+// lookup then falls back to loading.
+type Stats struct {
+    Hits   uint64 `json:"hits"`
+    Misses uint64 `json:"misses"`
+    Errors uint64 `json:"errors"`
+}
+
+// generationTTL is how long a generation is kept, see Generation. Losing it
+// early only costs misses.
+const generationTTL = 24 * time.Hour
+
+// Cache is a named cache-aside view of a Store: values are gob-encoded,
+// entries live for the TTL, and concurrent misses of the same key share one
+// load. Several caches may share a Store; their names only label the stats.
+type Cache struct {
+    name  string
+    store Store
+    ttl   time.Duration
+    group singleflight.Group
+
+    hits   atomic.Uint64
+    misses atomic.Uint64
+    errors atomic.Uint64
+}
+
+// New creates a cache over store keeping entries for ttl.
+func New(name string, store Store, ttl time.Duration) *Cache {
+    return &Cache{name: name, store: store, ttl: ttl}
+}
+
+// Name returns the name of the cache.
+func (c *Cache) Name() string {
+    return c.name
+}
+
+// Stats returns the lookups counted so far.
+func (c *Cache) Stats() Stats {
+    return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Errors: c.errors.Load()}
+}
+
+// Fetch returns the value cached under key or, on a miss, loads it, caches it
+// and returns it. Errors of load are returned and not cached. Concurrent
+// misses of key wait for a single load, which runs without the cancellation
+// of any one caller; every caller gets its own copy of the value.
+func Fetch[T any](ctx context.Context, c *Cache, key string, load func(ctx context.Context) (T, error)) (T, error) {
+    var v T
+    data, err := c.store.Get(ctx, key)
+    switch {
+    case err == nil:
+        if decode(data, &v) == nil {
+            c.hits.Add(1)
+            return v, nil
+        }
+        c.errors.Add(1)
+    case errors.Is(err, ErrMiss):
+        c.misses.Add(1)
+    default:
+        c.errors.Add(1)
+    }
+
+    shared, err, _ := c.group.Do(key, func() (interface{}, error) {
+        ctx := context.WithoutCancel(ctx)
+        v, err := load(ctx)
+        if err != nil {
+            return nil, err
+        }
+        data, err := encode(v)
+        if err != nil {
+            return nil, err
+        }
+        if err := c.store.Set(ctx, key, data, c.ttl); err != nil {
+            c.errors.Add(1)
+        }
+        return data, nil
+    })
+    if err != nil {
+        return v, err
+    }
+    return v, decode(shared.([]byte), &v)
+}
+
+// Generation returns the current generation of key, starting a new one if
+// there is none. Putting the generation into the keys of entries derived from
+// the same data lets Invalidate drop all of them at once, including entries
+// of loads still running: they are stored under the old generation.
+func (c *Cache) Generation(ctx context.Context, key string) string {
+    data, err := c.store.Get(ctx, key)
+    if err == nil {
+        return string(data)
+    }
+
+    buf := make([]byte, 8)
+    rand.Read(buf)
+    gen := hex.EncodeToString(buf)
+    if err := c.store.Set(ctx, key, []byte(gen), generationTTL); err != nil {
+        c.errors.Add(1)
+    }
+    return gen
+}
+
+// Invalidate removes the keys, which may be generations. A failure is logged:
+// the entries stay stale until they expire.
+func (c *Cache) Invalidate(ctx context.Context, keys ...string) {
+    if err := c.store.Del(context.WithoutCancel(ctx), keys...); err != nil {
+        c.errors.Add(1)
+        log.Printf("cache %s: invalidation of %v failed: %v", c.name, keys, err)
+    }
+}
+
+func encode(v interface{}) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func decode(data []byte, v interface{}) error {
+    return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
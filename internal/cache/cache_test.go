@@ -0,0 +1,152 @@
+package cache
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestLRU(t *testing.T) {
+    ctx := context.Background()
+    l := NewLRU(2)
+
+    l.Set(ctx, "a", []byte("1"), time.Minute)
+    l.Set(ctx, "b", []byte("2"), time.Minute)
+    // Reading a makes b the least recently used entry
+    if v, err := l.Get(ctx, "a"); err != nil || string(v) != "1" {
+        t.Fatalf("Get a: %q, %v", v, err)
+    }
+    l.Set(ctx, "c", []byte("3"), time.Minute)
+    if _, err := l.Get(ctx, "b"); !errors.Is(err, ErrMiss) {
+        t.Errorf("Get b after eviction: got %v, want ErrMiss", err)
+    }
+    for _, key := range []string{"a", "c"} {
+        if _, err := l.Get(ctx, key); err != nil {
+            t.Errorf("Get %s: %v", key, err)
+        }
+    }
+
+    l.Set(ctx, "short", []byte("x"), -time.Second)
+    if _, err := l.Get(ctx, "short"); !errors.Is(err, ErrMiss) {
+        t.Errorf("Get expired: got %v, want ErrMiss", err)
+    }
+
+    l.Del(ctx, "a", "missing")
+    if _, err := l.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+        t.Errorf("Get deleted: got %v, want ErrMiss", err)
+    }
+}
+
+type item struct {
+    Name string
+    Tags []string
+}
+
+func TestFetch(t *testing.T) {
+    ctx := context.Background()
+    c := New("test", NewLRU(10), time.Minute)
+
+    loads := 0
+    load := func(context.Context) (item, error) {
+        loads++
+        return item{Name: "ann", Tags: []string{"a"}}, nil
+    }
+
+    first, err := Fetch(ctx, c, "k", load)
+    if err != nil {
+        t.Fatalf("Fetch: %v", err)
+    }
+    // Callers get copies: changing one does not change the cached value
+    first.Tags[0] = "changed"
+    second, err := Fetch(ctx, c, "k", load)
+    if err != nil {
+        t.Fatalf("Fetch: %v", err)
+    }
+    if loads != 1 || second.Name != "ann" || second.Tags[0] != "a" {
+        t.Errorf("second Fetch: %+v after %d loads, want the cached value after 1", second, loads)
+    }
+    if got := c.Stats(); got.Hits != 1 || got.Misses != 1 {
+        t.Errorf("stats %+v, want 1 hit and 1 miss", got)
+    }
+
+    // Errors are returned and not cached
+    errLoad := errors.New("load failed")
+    for i := 0; i < 2; i++ {
+        if _, err := Fetch(ctx, c, "bad", func(context.Context) (item, error) { return item{}, errLoad }); !errors.Is(err, errLoad) {
+            t.Errorf("Fetch failing load: got %v, want %v", err, errLoad)
+        }
+    }
+    if got := c.Stats(); got.Misses != 3 {
+        t.Errorf("misses %d, want 3", got.Misses)
+    }
+}
+
+// TestFetchSingleflight checks that concurrent misses of a key share one load,
+// which is not cancelled with the caller that started it.
+func TestFetchSingleflight(t *testing.T) {
+    const callers = 10
+    c := New("test", NewLRU(10), time.Minute)
+
+    var loads atomic.Int32
+    release := make(chan struct{})
+    load := func(ctx context.Context) (int, error) {
+        loads.Add(1)
+        <-release
+        return 42, ctx.Err()
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    var wg sync.WaitGroup
+    errs := make(chan error, callers)
+    for i := 0; i < callers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            v, err := Fetch(ctx, c, "k", load)
+            if err == nil && v != 42 {
+                err = errors.New("wrong value")
+            }
+            errs <- err
+        }()
+    }
+    // Let every caller reach the load before it finishes: a miss is counted
+    // just before joining the load
+    for c.Stats().Misses < callers {
+        time.Sleep(time.Millisecond)
+    }
+    time.Sleep(20 * time.Millisecond)
+    cancel()
+    close(release)
+    wg.Wait()
+    close(errs)
+
+    for err := range errs {
+        if err != nil {
+            t.Errorf("Fetch: %v", err)
+        }
+    }
+    if n := loads.Load(); n != 1 {
+        t.Errorf("%d loads, want 1", n)
+    }
+}
+
+func TestGeneration(t *testing.T) {
+    ctx := context.Background()
+    c := New("test", NewLRU(10), time.Minute)
+
+    gen := c.Generation(ctx, "user:1:gen")
+    if again := c.Generation(ctx, "user:1:gen"); again != gen {
+        t.Errorf("generation changed without invalidation: %s, then %s", gen, again)
+    }
+    if other := c.Generation(ctx, "user:2:gen"); other == gen {
+        t.Error("two keys share a generation")
+    }
+
+    c.Invalidate(ctx, "user:1:gen")
+    if next := c.Generation(ctx, "user:1:gen"); next == gen {
+        t.Error("generation kept after invalidation")
+    }
+}
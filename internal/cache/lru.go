@@ -0,0 +1,87 @@
+// internal/cache/lru.go
+package cache
+
+import (
+    "container/list"
+    "context"
+    "sync"
+    "time"
+)
+
+// LRU is an in-process Store holding at most size entries; when full it
+// evicts the least recently used one. Expired entries stay until they are
+// read or evicted.
+type LRU struct {
+    mu    sync.Mutex
+    size  int
+    order *list.List
+    items map[string]*list.Element
+}
+
+type lruEntry struct {
+    key     string
+    value   []byte
+    expires time.Time
+}
+
+// NewLRU creates an LRU store for at most size entries.
+func NewLRU(size int) *LRU {
+    return &LRU{
+        size:  max(size, 1),
+        order: list.New(),
+        items: make(map[string]*list.Element),
+    }
+}
+
+func (l *LRU) Get(_ context.Context, key string) ([]byte, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    el, ok := l.items[key]
+    if !ok {
+        return nil, ErrMiss
+    }
+    e := el.Value.(*lruEntry)
+    if time.Now().After(e.expires) {
+        l.remove(el)
+        return nil, ErrMiss
+    }
+    l.order.MoveToFront(el)
+    return e.value, nil
+}
+
+func (l *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    expires := time.Now().Add(ttl)
+    if el, ok := l.items[key]; ok {
+        e := el.Value.(*lruEntry)
+        e.value, e.expires = value, expires
+        l.order.MoveToFront(el)
+        return nil
+    }
+
+    l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+    for l.order.Len() > l.size {
+        l.remove(l.order.Back())
+    }
+    return nil
+}
+
+func (l *LRU) Del(_ context.Context, keys ...string) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    for _, key := range keys {
+        if el, ok := l.items[key]; ok {
+            l.remove(el)
+        }
+    }
+    return nil
+}
+
+func (l *LRU) remove(el *list.Element) {
+    l.order.Remove(el)
+    delete(l.items, el.Value.(*lruEntry).key)
+}
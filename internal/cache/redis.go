@@ -0,0 +1,46 @@
+// internal/cache/redis.go
+package cache
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// Redis is a Store on a Redis server (or anything speaking its protocol),
+// shared by all instances of the service. Keys are prefixed to keep them
+// apart from other users of the server.
+type Redis struct {
+    client redis.UniversalClient
+    prefix string
+}
+
+// NewRedis creates a Redis store using client.
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+    return &Redis{client: client, prefix: prefix}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+    data, err := r.client.Get(ctx, r.prefix+key).Bytes()
+    if errors.Is(err, redis.Nil) {
+        return nil, ErrMiss
+    }
+    return data, err
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+    return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+func (r *Redis) Del(ctx context.Context, keys ...string) error {
+    if len(keys) == 0 {
+        return nil
+    }
+    prefixed := make([]string, len(keys))
+    for i, key := range keys {
+        prefixed[i] = r.prefix + key
+    }
+    return r.client.Del(ctx, prefixed...).Err()
+}
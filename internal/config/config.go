@@ -0,0 +1,378 @@
+// internal/config/config.go
+package config
+
+import (
+    "fmt"
+    "net"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Storage backends
+const (
+    StoragePostgres = "postgres"
+    StorageSQLite   = "sqlite"
+    StorageMemory   = "memory"
+)
+
+// Cache backends
+const (
+    CacheNone  = "none"
+    CacheLRU   = "lru"
+    CacheRedis = "redis"
+)
+
+// Rate limit stores
+const (
+    RateLimitNone   = "none"
+    RateLimitMemory = "memory"
+    RateLimitRedis  = "redis"
+)
+
+// defaultRateLimits are the rate limit rules of the public endpoints.
+const defaultRateLimits = "POST /users=5/1m, POST /auth/login=10/1m, GET /users=60/1m"
+
+// Config holds runtime settings read from the environment.
+type Config struct {
+    Port      string
+    DB        DBConfig
+    Cache     CacheConfig
+    RateLimit RateLimitConfig
+
+    // Storage selects the repository backend, see the Storage constants.
+    Storage string
+
+    // TrustedProxies are the addresses or CIDRs of the proxies whose
+    // X-Forwarded-For is believed for the client IP. None by default: the IP
+    // is the address of the peer.
+    TrustedProxies []string
+
+    // APIKeys are the keys issued to API clients (X-API-Key). Only these get
+    // their own rate limit buckets.
+    APIKeys []string
+
+    // SoftDeleteRetention is how long soft-deleted users are kept before purge.
+    SoftDeleteRetention time.Duration
+    // PurgeInterval is how often the purge worker runs.
+    PurgeInterval time.Duration
+
+    // ExportDir is where personal data export archives are stored.
+    ExportDir string
+    // ExportRetention is how long export archives can be downloaded before
+    // they are deleted.
+    ExportRetention time.Duration
+
+    // JWTSecret signs and verifies access tokens.
+    JWTSecret string
+    // CursorSecret signs pagination cursors.
+    CursorSecret string
+
+    // IdempotencyTTL is how long Idempotency-Key responses are kept for replay.
+    IdempotencyTTL time.Duration
+
+    // TraceExporter is where spans are sent: none, stdout or otlp.
+    TraceExporter string
+
+    // HealthCheckTimeout bounds each readiness check.
+    HealthCheckTimeout time.Duration
+    // ShutdownDelay is how long the readiness fails before the server stops
+    // accepting requests, so load balancers take it out of rotation first.
+    ShutdownDelay time.Duration
+    // ShutdownTimeout is how long requests in flight may take to finish on shutdown.
+    ShutdownTimeout time.Duration
+}
+
+// DBConfig holds database connection settings.
+type DBConfig struct {
+    Host     string
+    Port     string
+    User     string
+    Password string
+    Name     string
+
+    // SQLitePath is the database file used by the SQLite backend.
+    SQLitePath string
+
+    // Replicas are the read replicas of the database: host[:port] entries
+    // for Postgres, files for SQLite. See ReplicaConfigs.
+    Replicas []string
+    // ReplicaCheckInterval is how often the replicas are health-checked.
+    ReplicaCheckInterval time.Duration
+
+    // ReadTimeout and WriteTimeout bound single repository operations; zero
+    // disables them.
+    ReadTimeout  time.Duration
+    WriteTimeout time.Duration
+
+    // Connection pool settings of the underlying *sql.DB (see database/sql).
+    MaxOpenConns    int
+    MaxIdleConns    int
+    ConnMaxLifetime time.Duration
+    ConnMaxIdleTime time.Duration
+}
+
+// CacheConfig holds the settings of the repository cache.
+type CacheConfig struct {
+    // Backend is one of the Cache constants.
+    Backend string
+    // TTL bounds how stale a cached entry can get when an invalidation is missed.
+    TTL time.Duration
+    // Size is the number of entries the LRU backend holds.
+    Size int
+
+    RedisAddr     string
+    RedisPassword string
+    RedisDB       int
+}
+
+// RateLimitConfig holds the settings of the rate limiter.
+type RateLimitConfig struct {
+    // Backend is one of the RateLimit constants. The Redis store uses the
+    // connection settings of CacheConfig.
+    Backend string
+    Rules   []RateLimitRule
+}
+
+// RateLimitRule limits a route to Limit requests per Period per client, with
+// clients told apart by Key: ip, user or api_key.
+type RateLimitRule struct {
+    Method string
+    Route  string
+    Limit  int
+    Period time.Duration
+    Key    string
+}
+
+// DSN builds the Postgres connection string.
+func (c DBConfig) DSN() string {
+    return fmt.Sprintf(
+        "host=%s port=%s user=%s dbname=%s password=%s sslmode=disable TimeZone=UTC",
+        c.Host, c.Port, c.User, c.Name, c.Password,
+    )
+}
+
+// SQLiteDSN builds the SQLite connection string. Transactions take the write
+// lock up front and wait for it instead of failing with "database is locked";
+// LIKE is case-sensitive as in Postgres.
+func (c DBConfig) SQLiteDSN() string {
+    return fmt.Sprintf(
+        "file:%s?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate&_cslike=true",
+        c.SQLitePath,
+    )
+}
+
+// ReplicaConfigs returns the connection settings of each replica: c with the
+// replica's host and port (the port of c if it has none) or SQLite file.
+func (c DBConfig) ReplicaConfigs(storage string) []DBConfig {
+    cfgs := make([]DBConfig, 0, len(c.Replicas))
+    for _, r := range c.Replicas {
+        rc := c
+        rc.Replicas = nil
+        if storage == StorageSQLite {
+            rc.SQLitePath = r
+        } else if host, port, err := net.SplitHostPort(r); err == nil {
+            rc.Host, rc.Port = host, port
+        } else {
+            rc.Host = r
+        }
+        cfgs = append(cfgs, rc)
+    }
+    return cfgs
+}
+
+// Load reads the configuration from environment variables, applying defaults.
+func Load() (*Config, error) {
+    cfg := &Config{
+        Port:    getEnv("PORT", "8080"),
+        Storage: getEnv("STORAGE", StoragePostgres),
+
+        TrustedProxies: getList("TRUSTED_PROXIES"),
+        APIKeys:        getList("API_KEYS"),
+        DB: DBConfig{
+            Host:     os.Getenv("DB_HOST"),
+            Port:     os.Getenv("DB_PORT"),
+            User:     os.Getenv("DB_USER"),
+            Password: os.Getenv("DB_PASSWORD"),
+            Name:     os.Getenv("DB_NAME"),
+
+            SQLitePath: getEnv("SQLITE_PATH", "kvant.db"),
+            Replicas:   getList("DB_REPLICAS"),
+        },
+        Cache: CacheConfig{
+            Backend:       getEnv("CACHE", CacheLRU),
+            RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
+            RedisPassword: os.Getenv("REDIS_PASSWORD"),
+        },
+        RateLimit: RateLimitConfig{
+            Backend: getEnv("RATE_LIMIT", RateLimitMemory),
+        },
+        ExportDir:    getEnv("EXPORT_DIR", filepath.Join(os.TempDir(), "kvant-exports")),
+        JWTSecret:    os.Getenv("JWT_SECRET"),
+        CursorSecret: getEnv("CURSOR_SECRET", os.Getenv("JWT_SECRET")),
+
+        TraceExporter: getEnv("TRACING_EXPORTER", "none"),
+    }
+
+    // A well-known default would let anyone forge tokens and cursors
+    if cfg.JWTSecret == "" {
+        return nil, fmt.Errorf("JWT_SECRET must be set")
+    }
+
+    var err error
+    if cfg.SoftDeleteRetention, err = getDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour); err != nil {
+        return nil, err
+    }
+    if cfg.PurgeInterval, err = getDuration("PURGE_INTERVAL", time.Hour); err != nil {
+        return nil, err
+    }
+    if cfg.DB.ReadTimeout, err = getOptionalDuration("DB_READ_TIMEOUT", 5*time.Second); err != nil {
+        return nil, err
+    }
+    if cfg.DB.WriteTimeout, err = getOptionalDuration("DB_WRITE_TIMEOUT", 10*time.Second); err != nil {
+        return nil, err
+    }
+    if cfg.DB.MaxOpenConns, err = getInt("DB_MAX_OPEN_CONNS", 25); err != nil {
+        return nil, err
+    }
+    if cfg.DB.MaxIdleConns, err = getInt("DB_MAX_IDLE_CONNS", 10); err != nil {
+        return nil, err
+    }
+    if cfg.DB.ConnMaxLifetime, err = getOptionalDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute); err != nil {
+        return nil, err
+    }
+    if cfg.DB.ConnMaxIdleTime, err = getOptionalDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute); err != nil {
+        return nil, err
+    }
+    if cfg.DB.ReplicaCheckInterval, err = getDuration("DB_REPLICA_CHECK_INTERVAL", 10*time.Second); err != nil {
+        return nil, err
+    }
+    if cfg.Cache.TTL, err = getDuration("CACHE_TTL", time.Minute); err != nil {
+        return nil, err
+    }
+    if cfg.Cache.Size, err = getInt("CACHE_SIZE", 10000); err != nil {
+        return nil, err
+    }
+    if cfg.Cache.RedisDB, err = getInt("REDIS_DB", 0); err != nil {
+        return nil, err
+    }
+    if cfg.RateLimit.Rules, err = parseRateLimits(getEnv("RATE_LIMITS", defaultRateLimits)); err != nil {
+        return nil, err
+    }
+    if cfg.ExportRetention, err = getDuration("EXPORT_RETENTION", 24*time.Hour); err != nil {
+        return nil, err
+    }
+    if cfg.IdempotencyTTL, err = getDuration("IDEMPOTENCY_TTL", 24*time.Hour); err != nil {
+        return nil, err
+    }
+    if cfg.HealthCheckTimeout, err = getDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second); err != nil {
+        return nil, err
+    }
+    if cfg.ShutdownDelay, err = getOptionalDuration("SHUTDOWN_DELAY", 0); err != nil {
+        return nil, err
+    }
+    if cfg.ShutdownTimeout, err = getDuration("SHUTDOWN_TIMEOUT", 30*time.Second); err != nil {
+        return nil, err
+    }
+    return cfg, nil
+}
+
+func getEnv(key, def string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return def
+}
+
+// getList reads a comma-separated list, skipping empty entries.
+func getList(key string) []string {
+    var list []string
+    for _, v := range strings.Split(os.Getenv(key), ",") {
+        if v = strings.TrimSpace(v); v != "" {
+            list = append(list, v)
+        }
+    }
+    return list
+}
+
+// parseRateLimits reads comma-separated rules "METHOD /route=LIMIT/PERIOD[:KEY]",
+// e.g. "GET /users=60/1m:user"; the key defaults to ip.
+func parseRateLimits(v string) ([]RateLimitRule, error) {
+    var rules []RateLimitRule
+    for _, entry := range strings.Split(v, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        invalid := fmt.Errorf("invalid RATE_LIMITS entry: %q", entry)
+
+        target, spec, ok := strings.Cut(entry, "=")
+        method, route, ok2 := strings.Cut(strings.TrimSpace(target), " ")
+        if !ok || !ok2 {
+            return nil, invalid
+        }
+        rule := RateLimitRule{Method: strings.ToUpper(method), Route: strings.TrimSpace(route), Key: "ip"}
+
+        spec, key, hasKey := strings.Cut(strings.TrimSpace(spec), ":")
+        if hasKey {
+            rule.Key = key
+        }
+        limit, period, ok := strings.Cut(spec, "/")
+        if !ok {
+            return nil, invalid
+        }
+        var err error
+        if rule.Limit, err = strconv.Atoi(limit); err != nil || rule.Limit <= 0 {
+            return nil, invalid
+        }
+        if rule.Period, err = time.ParseDuration(period); err != nil || rule.Period <= 0 {
+            return nil, invalid
+        }
+        switch rule.Key {
+        case "ip", "user", "api_key":
+        default:
+            return nil, invalid
+        }
+        rules = append(rules, rule)
+    }
+    return rules, nil
+}
+
+func getDuration(key string, def time.Duration) (time.Duration, error) {
+    v := os.Getenv(key)
+    if v == "" {
+        return def, nil
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil || d <= 0 {
+        return 0, fmt.Errorf("invalid %s: %q", key, v)
+    }
+    return d, nil
+}
+
+// getOptionalDuration is getDuration for settings that 0 turns off.
+func getOptionalDuration(key string, def time.Duration) (time.Duration, error) {
+    v := os.Getenv(key)
+    if v == "" {
+        return def, nil
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil || d < 0 {
+        return 0, fmt.Errorf("invalid %s: %q", key, v)
+    }
+    return d, nil
+}
+
+func getInt(key string, def int) (int, error) {
+    v := os.Getenv(key)
+    if v == "" {
+        return def, nil
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("invalid %s: %q", key, v)
+    }
+    return n, nil
+}
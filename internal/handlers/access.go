@@ -0,0 +1,18 @@
+// internal/handlers/access.go
+package handlers
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// isAdmin reports whether the authenticated caller has the admin role.
+func isAdmin(c *gin.Context) bool {
+    return c.GetString("role") == models.RoleAdmin
+}
+
+// canAccessUser reports whether the authenticated caller may act on
+// the given user's data: either it is the user themselves or an admin.
+func canAccessUser(c *gin.Context, userID uint) bool {
+    return isAdmin(c) || c.GetUint("user_id") == userID
+}
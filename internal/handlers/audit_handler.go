@@ -0,0 +1,110 @@
+// internal/handlers/audit_handler.go
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// AuditHandler exposes the audit log to administrators.
+type AuditHandler struct {
+    svc services.AuditService
+}
+
+// NewAuditHandler creates a new AuditHandler.
+func NewAuditHandler(svc services.AuditService) *AuditHandler {
+    return &AuditHandler{svc: svc}
+}
+
+// ListAuditEntries returns audit log entries, newest first.
+// @Summary Query the audit log
+// @Tags Audit
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size" default(10)
+// @Param actor_id query int false "ID of the user who made the change"
+// @Param action query string false "create, update or delete"
+// @Param resource_type query string false "user or order"
+// @Param resource_id query int false "ID of the changed resource"
+// @Param from query string false "Lower bound of created_at, RFC3339"
+// @Param to query string false "Upper bound of created_at (exclusive), RFC3339"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/audit [get]
+func (h *AuditHandler) ListAuditEntries(c *gin.Context) {
+    page, limit, err := utils.ParsePagination(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    from, to, err := utils.ParseTimeRange(c, "from", "to")
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    filter := models.AuditFilter{
+        Action:       c.Query("action"),
+        ResourceType: c.Query("resource_type"),
+        From:         from,
+        To:           to,
+        Page:         page,
+        Limit:        limit,
+    }
+    if s := c.Query("actor_id"); s != "" {
+        id, err := strconv.ParseUint(s, 10, 64)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid actor_id parameter"})
+            return
+        }
+        actorID := uint(id)
+        filter.ActorID = &actorID
+    }
+    if s := c.Query("resource_id"); s != "" {
+        id, err := strconv.ParseUint(s, 10, 64)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resource_id parameter"})
+            return
+        }
+        filter.ResourceID = uint(id)
+    }
+
+    entries, total, err := h.svc.List(c.Request.Context(), filter)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "data":  entries,
+        "total": total,
+        "page":  page,
+        "limit": limit,
+    })
+}
+
+// VerifyAuditChain recomputes the hash chain to detect tampering.
+// @Summary Verify audit log integrity
+// @Tags Audit
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.AuditVerifyResult
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/audit/verify [get]
+func (h *AuditHandler) VerifyAuditChain(c *gin.Context) {
+    res, err := h.svc.Verify(c.Request.Context())
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, res)
+}
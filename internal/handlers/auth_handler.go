@@ -40,7 +40,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
     case services.IsAuthError(err):
         c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
     case err != nil:
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err)
     default:
         c.JSON(http.StatusOK, models.TokenResponse{Token: token})
     }
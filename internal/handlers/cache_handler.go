@@ -0,0 +1,40 @@
+// internal/handlers/cache_handler.go
+package handlers
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/cache"
+)
+
+// CacheHandler reports how well the repository caches work.
+type CacheHandler struct {
+    caches []*cache.Cache
+}
+
+// NewCacheHandler creates a new CacheHandler.
+func NewCacheHandler(caches ...*cache.Cache) *CacheHandler {
+    return &CacheHandler{caches: caches}
+}
+
+type cacheStats struct {
+    Name string `json:"name"`
+    cache.Stats
+}
+
+// GetCacheStats returns the hit, miss and error counts of each cache since start.
+// @Summary Cache statistics
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Router /admin/cache [get]
+func (h *CacheHandler) GetCacheStats(c *gin.Context) {
+    stats := make([]cacheStats, 0, len(h.caches))
+    for _, ch := range h.caches {
+        stats = append(stats, cacheStats{Name: ch.Name(), Stats: ch.Stats()})
+    }
+    c.JSON(http.StatusOK, gin.H{"data": stats})
+}
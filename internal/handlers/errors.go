@@ -0,0 +1,32 @@
+// internal/handlers/errors.go
+package handlers
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+)
+
+// statusClientClosedRequest is the non-standard status (from nginx) recorded
+// when the client disconnected before the response was ready.
+const statusClientClosedRequest = 499
+
+// respondError writes an error that has no more specific mapping: 499 if the
+// client went away, 504 if a database operation timed out, 500 otherwise.
+func respondError(c *gin.Context, err error) {
+    switch {
+    case services.IsCanceled(err):
+        c.JSON(statusClientClosedRequest, gin.H{"error": err.Error()})
+    case services.IsTimeout(err):
+        c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    }
+}
+
+// isAborted reports whether err comes from a cancelled or timed-out operation
+// rather than from the request itself.
+func isAborted(err error) bool {
+    return services.IsCanceled(err) || services.IsTimeout(err)
+}
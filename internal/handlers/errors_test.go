@@ -0,0 +1,79 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+)
+
+func TestRespondError(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+
+    cases := []struct {
+        name string
+        err  error
+        want int
+    }{
+        {"canceled", fmt.Errorf("list users: %w", services.ErrCanceled), statusClientClosedRequest},
+        {"timeout", fmt.Errorf("list users: %w", services.ErrTimeout), http.StatusGatewayTimeout},
+        {"other", errors.New("connection refused"), http.StatusInternalServerError},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            ctx, _ := gin.CreateTestContext(w)
+            respondError(ctx, c.err)
+            if w.Code != c.want {
+                t.Errorf("got %d, want %d", w.Code, c.want)
+            }
+        })
+    }
+}
+
+// TestAbortedLookup follows a request whose context ends from the handler
+// through the service to the repository and back.
+func TestAbortedLookup(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+
+    store := repository.NewMemoryStore()
+    audit := services.NewAuditService(repository.NewMemoryAuditRepo(store))
+    svc := services.NewUserService(repository.NewMemoryUserRepo(store), audit, repository.NewMemoryTxManager(store))
+    router := gin.New()
+    router.GET("/user/:id", NewUserHandler(svc, query.NewCursorCodec("secret")).GetUserByID)
+
+    cases := []struct {
+        name string
+        ctx  func() (context.Context, context.CancelFunc)
+        want int
+    }{
+        {"client went away", func() (context.Context, context.CancelFunc) {
+            ctx, cancel := context.WithCancel(context.Background())
+            cancel()
+            return ctx, cancel
+        }, statusClientClosedRequest},
+        {"deadline passed", func() (context.Context, context.CancelFunc) {
+            return context.WithTimeout(context.Background(), -time.Second)
+        }, http.StatusGatewayTimeout},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            ctx, cancel := c.ctx()
+            defer cancel()
+            req := httptest.NewRequest(http.MethodGet, "/user/1", nil).WithContext(ctx)
+            w := httptest.NewRecorder()
+            router.ServeHTTP(w, req)
+            if w.Code != c.want {
+                t.Errorf("got %d %s, want %d", w.Code, w.Body, c.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,46 @@
+// internal/handlers/health_handler.go
+package handlers
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/health"
+)
+
+// HealthHandler serves the liveness and readiness probes.
+type HealthHandler struct {
+    checker *health.Checker
+}
+
+// NewHealthHandler creates a new HealthHandler.
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+    return &HealthHandler{checker: checker}
+}
+
+// Liveness reports that the process is up and serving HTTP.
+// @Summary Liveness probe
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c *gin.Context) {
+    c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readiness runs the dependency checks: the database answers, its schema is
+// migrated and the background workers run. It fails during shutdown.
+// @Summary Readiness probe
+// @Tags Health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(c *gin.Context) {
+    report := h.checker.Run(c.Request.Context())
+    status := http.StatusOK
+    if report.Status != health.StatusOK {
+        status = http.StatusServiceUnavailable
+    }
+    c.JSON(status, report)
+}
@@ -0,0 +1,141 @@
+// internal/handlers/import_handler.go
+package handlers
+
+import (
+    "errors"
+    "io"
+    "net/http"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+)
+
+// maxImportFileSize limits the size of an uploaded import file.
+const maxImportFileSize = 10 << 20
+
+// ImportHandler serves bulk imports.
+type ImportHandler struct {
+    svc services.ImportService
+}
+
+// NewImportHandler creates a new ImportHandler.
+func NewImportHandler(svc services.ImportService) *ImportHandler {
+    return &ImportHandler{svc: svc}
+}
+
+// ImportUsers starts a bulk import of users from a CSV or XLSX file.
+// @Summary Import users from CSV or XLSX (admin)
+// @Description The file needs a header row with name, email, age and password columns. Rows are validated like POST /users.
+// @Description In atomic mode nothing is created if any row is invalid; in best_effort mode valid rows are created and the rest reported.
+// @Tags Users
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file"
+// @Param mode query string false "atomic (default) or best_effort"
+// @Param dry_run query bool false "Validate only"
+// @Success 202 {object} models.Job
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/import [post]
+func (h *ImportHandler) ImportUsers(c *gin.Context) {
+    dryRun := false
+    if raw := c.Query("dry_run"); raw != "" {
+        v, err := strconv.ParseBool(raw)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be a boolean"})
+            return
+        }
+        dryRun = v
+    }
+
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize+1<<20)
+    fh, err := c.FormFile("file")
+    if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+            return
+        }
+        c.JSON(http.StatusBadRequest, gin.H{"error": "multipart field \"file\" is required"})
+        return
+    }
+    if fh.Size > maxImportFileSize {
+        c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+        return
+    }
+    format, ok := importFormat(fh.Filename, fh.Header.Get("Content-Type"))
+    if !ok {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "file must be .csv or .xlsx"})
+        return
+    }
+
+    f, err := fh.Open()
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    defer f.Close()
+    data, err := io.ReadAll(f)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    job, err := h.svc.StartUserImport(c.Request.Context(), format, data, services.ImportOptions{
+        Mode:   c.Query("mode"),
+        DryRun: dryRun,
+    })
+    switch {
+    case services.IsInvalidImport(err), services.IsValidation(err):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
+    default:
+        c.Header("Location", "/admin/imports/"+job.ID)
+        c.JSON(http.StatusAccepted, job)
+    }
+}
+
+// GetImport returns the status and row report of an import job.
+// @Summary Get import status and report (admin)
+// @Tags Users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.ImportJob
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/imports/{id} [get]
+func (h *ImportHandler) GetImport(c *gin.Context) {
+    job, err := h.svc.GetImport(c.Request.Context(), c.Param("id"))
+    switch {
+    case services.IsJobNotFound(err):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
+    default:
+        c.JSON(http.StatusOK, job)
+    }
+}
+
+// importFormat picks the parser by file extension, falling back to the part's content type.
+func importFormat(filename, contentType string) (string, bool) {
+    switch strings.ToLower(filepath.Ext(filename)) {
+    case ".csv":
+        return services.ImportFormatCSV, true
+    case ".xlsx":
+        return services.ImportFormatXLSX, true
+    }
+    switch {
+    case strings.HasPrefix(contentType, "text/csv"):
+        return services.ImportFormatCSV, true
+    case strings.HasPrefix(contentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"):
+        return services.ImportFormatXLSX, true
+    }
+    return "", false
+}
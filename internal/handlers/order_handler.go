@@ -2,23 +2,25 @@
 package handlers
 
 import (
+    "context"
     "net/http"
-    "strconv"
 
     "github.com/gin-gonic/gin"
     "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
     "github.com/PhosFactum/kvant-backend-practicum/internal/services"
     "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
 )
 
 // OrderHandler manages order-related endpoints.
 type OrderHandler struct {
-    svc services.OrderService
+    svc     services.OrderService
+    cursors *query.CursorCodec
 }
 
 // NewOrderHandler creates a new OrderHandler.
-func NewOrderHandler(svc services.OrderService) *OrderHandler {
-    return &OrderHandler{svc: svc}
+func NewOrderHandler(svc services.OrderService, cursors *query.CursorCodec) *OrderHandler {
+    return &OrderHandler{svc: svc, cursors: cursors}
 }
 
 // CreateOrder creates a new order for a given user.
@@ -27,17 +29,21 @@ func NewOrderHandler(svc services.OrderService) *OrderHandler {
 // @Security BearerAuth
 // @Accept json
 // @Produce json
+// @Description With an Idempotency-Key header a retry returns the original response instead of creating another order.
 // @Param user_id path int true "User ID"
+// @Param Idempotency-Key header string false "Client-generated key, unique per logical request"
 // @Param order body models.OrderRequest true "Order info"
 // @Success 201 {object} models.Order
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /users/{user_id}/orders [post]
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
-    userID, err := strconv.Atoi(c.Param("user_id"))
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+    userID, ok := pathUserID(c)
+    if !ok {
         return
     }
 
@@ -47,7 +53,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
         return
     }
 
-    order, err := h.svc.Create(c.Request.Context(), uint(userID), req)
+    order, err := h.svc.Create(c.Request.Context(), userID, req)
     switch {
     case services.IsNotFound(err):
         c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -56,43 +62,264 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     case err != nil:
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err)
         return
     default:
         // Асинхронное уведомление о новом заказе
-        utils.Async(func() {
-            _ = h.svc.NotifyOrderCreated(c.Request.Context(), &order)
+        utils.Async(c.Request.Context(), "order_notification", func(ctx context.Context) error {
+            return h.svc.NotifyOrderCreated(ctx, &order)
         })
         c.JSON(http.StatusCreated, order)
     }
 }
 
-// GetOrdersByUser returns all orders for a given user.
+// CreateOrdersBatch creates several orders for a user in one request.
+// @Summary Create orders in bulk
+// @Description Every item is validated; valid items are stored with a single insert. With "atomic": true (default)
+// @Description one invalid item rejects the whole batch (422). With "atomic": false valid items are created and the
+// @Description response is 207 if some items failed. Items in the response follow the request order.
+// @Tags Orders
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param Idempotency-Key header string false "Client-generated key, unique per logical request"
+// @Param batch body models.OrderBatchRequest true "Orders"
+// @Success 201 {object} models.OrderBatchResult
+// @Success 207 {object} models.OrderBatchResult
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} models.OrderBatchResult
+// @Failure 500 {object} map[string]string
+// @Router /users/{user_id}/orders/batch [post]
+func (h *OrderHandler) CreateOrdersBatch(c *gin.Context) {
+    userID, ok := pathUserID(c)
+    if !ok {
+        return
+    }
+
+    var req models.OrderBatchRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    result, err := h.svc.CreateBatch(c.Request.Context(), userID, req)
+    switch {
+    case services.IsNotFound(err):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case services.IsValidation(err):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
+    case result.Created == 0:
+        c.JSON(http.StatusUnprocessableEntity, result)
+    default:
+        for _, item := range result.Items {
+            if order := item.Order; order != nil {
+                utils.Async(c.Request.Context(), "order_notification", func(ctx context.Context) error {
+                    return h.svc.NotifyOrderCreated(ctx, order)
+                })
+            }
+        }
+        status := http.StatusCreated
+        if result.Failed > 0 {
+            status = http.StatusMultiStatus
+        }
+        c.JSON(status, result)
+    }
+}
+
+// GetOrdersByUser returns a filtered page of orders for a given user with aggregates.
 // @Summary Get orders for a user
 // @Tags Orders
 // @Security BearerAuth
 // @Param user_id path int true "User ID"
-// @Success 200 {array} models.Order
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size, at most 100" default(10)
+// @Param after query string false "Cursor from next_cursor; switches to keyset paging"
+// @Param before query string false "Cursor from prev_cursor; switches to keyset paging"
+// @Param count query bool false "Set to false to skip computing total and summary" default(true)
+// @Param product query string false "Filter by product; also product[prefix], product[icontains] etc."
+// @Param status query string false "Filter by status: pending, paid, shipped, cancelled"
+// @Param created_at[gte] query string false "Created at or after, RFC3339 (also gt, lt, lte)"
+// @Param price[gte] query number false "Minimum price (also gt, lt, lte)"
+// @Param sort query string false "Comma-separated sort fields, prefix with - for descending, e.g. -created_at"
+// @Success 200 {object} map[string]interface{}
+// @Header 200 {string} Link "URLs of the next and previous pages"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /users/{user_id}/orders [get]
 func (h *OrderHandler) GetOrdersByUser(c *gin.Context) {
-    userID, err := strconv.Atoi(c.Param("user_id"))
+    userID, ok := pathUserID(c)
+    if !ok {
+        return
+    }
+
+    spec, err := query.Parse(c.Request.URL.Query(), models.OrderQuerySchema)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := parseListSpec(c, h.cursors, &spec, models.OrderQuerySchema); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
 
-    orders, err := h.svc.ListByUser(c.Request.Context(), uint(userID))
+    orders, info, summary, err := h.svc.ListByUser(c.Request.Context(), userID, spec)
     switch {
     case services.IsNotFound(err):
         c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
         return
     case err != nil:
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err)
+        return
+    }
+
+    resp, err := pageMeta(c, h.cursors, orders, spec, models.OrderQuerySchema, info)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    if resp["data"], err = query.Project(orders, spec.Fields); err != nil {
+        respondError(c, err)
         return
+    }
+    if summary != nil {
+        resp["summary"] = summary
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+
+// GetOrder returns a single order of a user.
+// @Summary Get order by ID
+// @Tags Orders
+// @Security BearerAuth
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param id path int true "Order ID"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{user_id}/orders/{id} [get]
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+    userID, orderID, ok := pathOrderID(c)
+    if !ok {
+        return
+    }
+
+    order, err := h.svc.GetByID(c.Request.Context(), userID, orderID)
+    switch {
+    case services.IsNotFound(err):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
+    default:
+        c.JSON(http.StatusOK, order)
+    }
+}
+
+// UpdateOrder corrects product, quantity or price of a pending order.
+// @Summary Update order
+// @Tags Orders
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param id path int true "Order ID"
+// @Param order body models.UpdateOrderInput true "Fields to change"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{user_id}/orders/{id} [patch]
+func (h *OrderHandler) UpdateOrder(c *gin.Context) {
+    userID, orderID, ok := pathOrderID(c)
+    if !ok {
+        return
+    }
+    var input models.UpdateOrderInput
+    if err := c.ShouldBindJSON(&input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    order, err := h.svc.Update(c.Request.Context(), userID, orderID, input)
+    switch {
+    case services.IsNotFound(err):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case services.IsValidation(err):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case services.IsConflict(err):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
     default:
-        c.JSON(http.StatusOK, orders)
+        c.JSON(http.StatusOK, order)
     }
 }
 
+// DeleteOrder removes an order of a user.
+// @Summary Delete order
+// @Tags Orders
+// @Security BearerAuth
+// @Param user_id path int true "User ID"
+// @Param id path int true "Order ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{user_id}/orders/{id} [delete]
+func (h *OrderHandler) DeleteOrder(c *gin.Context) {
+    userID, orderID, ok := pathOrderID(c)
+    if !ok {
+        return
+    }
+
+    err := h.svc.Delete(c.Request.Context(), userID, orderID)
+    switch {
+    case services.IsNotFound(err):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
+    default:
+        c.Status(http.StatusNoContent)
+    }
+}
+
+// pathUserID parses user_id from the path and checks the caller may act on
+// that user's orders. On failure the response is already written.
+func pathUserID(c *gin.Context) (uint, bool) {
+    userID, err := utils.ParseIDParam(c, "user_id")
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+        return 0, false
+    }
+    if !canAccessUser(c, userID) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "access to this user's orders is forbidden"})
+        return 0, false
+    }
+    return userID, true
+}
+
+// pathOrderID parses user_id and the order id from the path, see pathUserID.
+func pathOrderID(c *gin.Context) (uint, uint, bool) {
+    userID, ok := pathUserID(c)
+    if !ok {
+        return 0, 0, false
+    }
+    orderID, err := utils.ParseIDParam(c, "id")
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return 0, 0, false
+    }
+    return userID, orderID, true
+}
@@ -0,0 +1,92 @@
+// internal/handlers/pagination.go
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// parseListSpec reads the paging options shared by list endpoints into spec:
+// page/limit, after/before cursors and count=false to skip the total.
+func parseListSpec(c *gin.Context, codec *query.CursorCodec, spec *query.Spec, schema query.Schema) error {
+    page, limit, err := utils.ParsePagination(c)
+    if err != nil {
+        return err
+    }
+    count, err := strconv.ParseBool(c.DefaultQuery("count", "true"))
+    if err != nil {
+        return errors.New("invalid count parameter")
+    }
+    spec.Page, spec.Limit, spec.SkipCount = page, limit, !count
+    return query.ParseCursor(c.Request.URL.Query(), codec, spec, schema)
+}
+
+// pageMeta builds the paging fields of a list response (total, cursors)
+// and sets a Link header with next/prev URLs.
+func pageMeta[T any](
+    c *gin.Context,
+    codec *query.CursorCodec,
+    items []T,
+    spec query.Spec,
+    schema query.Schema,
+    info query.PageInfo,
+) (gin.H, error) {
+    meta := gin.H{"limit": spec.Limit}
+    if spec.Cursor == nil {
+        meta["page"] = spec.Page
+    }
+    if info.Total != nil {
+        meta["total"] = *info.Total
+    }
+    if len(items) == 0 {
+        return meta, nil
+    }
+
+    var links []string
+    if info.HasNext {
+        token, err := cursorToken(codec, items[len(items)-1], spec, schema)
+        if err != nil {
+            return nil, err
+        }
+        meta["next_cursor"] = token
+        links = append(links, pageLink(c, "after", token, "next"))
+    }
+    if info.HasPrev {
+        token, err := cursorToken(codec, items[0], spec, schema)
+        if err != nil {
+            return nil, err
+        }
+        meta["prev_cursor"] = token
+        links = append(links, pageLink(c, "before", token, "prev"))
+    }
+    if len(links) > 0 {
+        c.Header("Link", strings.Join(links, ", "))
+    }
+    return meta, nil
+}
+
+func cursorToken(codec *query.CursorCodec, item interface{}, spec query.Spec, schema query.Schema) (string, error) {
+    cur, err := query.CursorAt(item, spec, schema)
+    if err != nil {
+        return "", err
+    }
+    return codec.Encode(cur)
+}
+
+// pageLink returns the current request URL switched to the given cursor.
+func pageLink(c *gin.Context, param, token, rel string) string {
+    u := *c.Request.URL
+    q := u.Query()
+    q.Del("page")
+    q.Del("after")
+    q.Del("before")
+    q.Set(param, token)
+    u.RawQuery = q.Encode()
+    return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
@@ -0,0 +1,151 @@
+// internal/handlers/privacy_handler.go
+package handlers
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// PrivacyHandler serves personal data export and erasure requests.
+type PrivacyHandler struct {
+    svc services.PrivacyService
+}
+
+// NewPrivacyHandler creates a new PrivacyHandler.
+func NewPrivacyHandler(svc services.PrivacyService) *PrivacyHandler {
+    return &PrivacyHandler{svc: svc}
+}
+
+// ExportUser starts a personal data export.
+// @Summary Export all personal data of a user
+// @Description Starts a background job building a zip with JSON files. Poll the job and download the result when done.
+// @Tags Privacy
+// @Security BearerAuth
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Success 202 {object} models.Job
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{user_id}/export [get]
+func (h *PrivacyHandler) ExportUser(c *gin.Context) {
+    h.startJob(c, h.svc.StartExport)
+}
+
+// EraseUser starts anonymisation of a user's personal data.
+// @Summary Erase personal data of a user
+// @Description Anonymises name, email and age in the background; orders are kept for accounting.
+// @Tags Privacy
+// @Security BearerAuth
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Success 202 {object} models.Job
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{user_id}/erase [post]
+func (h *PrivacyHandler) EraseUser(c *gin.Context) {
+    h.startJob(c, h.svc.StartErasure)
+}
+
+func (h *PrivacyHandler) startJob(c *gin.Context, start func(ctx context.Context, userID uint) (*models.Job, error)) {
+    id, err := utils.ParseIDParam(c, "user_id")
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if !canAccessUser(c, id) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "access to this user is forbidden"})
+        return
+    }
+
+    job, err := start(c.Request.Context(), id)
+    switch {
+    case services.IsNotFound(err):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
+    default:
+        c.Header("Location", "/data-requests/"+job.ID)
+        c.JSON(http.StatusAccepted, job)
+    }
+}
+
+// GetDataRequest returns the status of an export or erasure job.
+// @Summary Get data request status
+// @Tags Privacy
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.Job
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /data-requests/{id} [get]
+func (h *PrivacyHandler) GetDataRequest(c *gin.Context) {
+    job, ok := h.loadJob(c)
+    if !ok {
+        return
+    }
+    c.JSON(http.StatusOK, job)
+}
+
+// DownloadExport streams the finished export archive.
+// @Summary Download a personal data export
+// @Tags Privacy
+// @Security BearerAuth
+// @Produce application/zip
+// @Param id path string true "Job ID"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Router /data-requests/{id}/download [get]
+func (h *PrivacyHandler) DownloadExport(c *gin.Context) {
+    job, ok := h.loadJob(c)
+    if !ok {
+        return
+    }
+
+    path, err := h.svc.ExportFile(c.Request.Context(), job.ID)
+    switch {
+    case services.IsJobNotFound(err):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case errors.Is(err, services.ErrJobNotFinished):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": job.Status})
+    case errors.Is(err, services.ErrExportExpired):
+        c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
+    default:
+        c.FileAttachment(path, "user-"+strconv.FormatUint(uint64(job.SubjectID), 10)+"-export.zip")
+    }
+}
+
+// loadJob fetches the job from the path and checks the caller may see it.
+func (h *PrivacyHandler) loadJob(c *gin.Context) (*models.Job, bool) {
+    job, err := h.svc.GetJob(c.Request.Context(), c.Param("id"))
+    if isAborted(err) {
+        respondError(c, err)
+        return nil, false
+    }
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return nil, false
+    }
+    requester := job.RequestedBy != nil && *job.RequestedBy == c.GetUint("user_id")
+    if !requester && !canAccessUser(c, job.SubjectID) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "access to this job is forbidden"})
+        return nil, false
+    }
+    return job, true
+}
@@ -0,0 +1,141 @@
+// internal/handlers/render.go
+package handlers
+
+import (
+    "encoding/csv"
+    "fmt"
+    "net/http"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
+)
+
+// Response formats selected by content negotiation
+const (
+    formatJSON   = "json"
+    formatCSV    = "csv"
+    formatNDJSON = "ndjson"
+)
+
+// responseFormat picks the output format from ?format= or, failing that,
+// the Accept header. JSON is the default.
+func responseFormat(c *gin.Context, allowed ...string) (string, bool) {
+    if f := c.Query("format"); f != "" {
+        for _, a := range allowed {
+            if f == a {
+                return f, true
+            }
+        }
+        return "", false
+    }
+    accept := c.GetHeader("Accept")
+    for _, a := range allowed {
+        if mime, ok := formatMIME[a]; ok && strings.Contains(accept, mime) {
+            return a, true
+        }
+    }
+    return formatJSON, true
+}
+
+var formatMIME = map[string]string{
+    formatJSON:   "application/json",
+    formatCSV:    "text/csv",
+    formatNDJSON: "application/x-ndjson",
+}
+
+// renderRows writes a slice of flat structs as JSON ({"data": rows, ...meta})
+// or as CSV with a header row built from the json tags.
+func renderRows(c *gin.Context, rows interface{}, meta gin.H, filename string) {
+    format, ok := responseFormat(c, formatJSON, formatCSV)
+    if !ok {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+        return
+    }
+    if format == formatJSON {
+        resp := gin.H{"data": rows}
+        for k, v := range meta {
+            resp[k] = v
+        }
+        c.JSON(http.StatusOK, resp)
+        return
+    }
+
+    c.Header("Content-Type", "text/csv; charset=utf-8")
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+    c.Status(http.StatusOK)
+
+    w := csv.NewWriter(c.Writer)
+    v := reflect.ValueOf(rows)
+    header, _ := csvColumns(v.Type().Elem())
+    _ = w.Write(header)
+    for i := 0; i < v.Len(); i++ {
+        _ = w.Write(csvRecord(v.Index(i)))
+    }
+    w.Flush()
+}
+
+// csvColumns returns the json names of the exported fields of a struct type.
+func csvColumns(t reflect.Type) ([]string, []int) {
+    var names []string
+    var idx []int
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        name := strings.Split(f.Tag.Get("json"), ",")[0]
+        if !f.IsExported() || name == "-" {
+            continue
+        }
+        if name == "" {
+            name = f.Name
+        }
+        names = append(names, name)
+        idx = append(idx, i)
+    }
+    return names, idx
+}
+
+func csvRecord(v reflect.Value) []string {
+    _, idx := csvColumns(v.Type())
+    rec := make([]string, 0, len(idx))
+    for _, i := range idx {
+        rec = append(rec, csvValue(v.Field(i)))
+    }
+    return rec
+}
+
+func csvValue(f reflect.Value) string {
+    if f.Kind() == reflect.Ptr {
+        if f.IsNil() {
+            return ""
+        }
+        f = f.Elem()
+    }
+    if f.Kind() == reflect.String {
+        return csvText(f.String())
+    }
+    switch val := f.Interface().(type) {
+    case time.Time:
+        return val.UTC().Format(time.RFC3339)
+    case gorm.DeletedAt:
+        if !val.Valid {
+            return ""
+        }
+        return val.Time.UTC().Format(time.RFC3339)
+    case float64:
+        return strconv.FormatFloat(val, 'f', -1, 64)
+    default:
+        return fmt.Sprint(val)
+    }
+}
+
+// csvText defuses text that a spreadsheet would run as a formula (CSV
+// injection) by prefixing it with a quote, which the spreadsheet hides.
+func csvText(s string) string {
+    if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+        return "'" + s
+    }
+    return s
+}
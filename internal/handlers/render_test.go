@@ -0,0 +1,64 @@
+package handlers
+
+import (
+    "encoding/csv"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+)
+
+// TestRenderRowsCSVInjection checks that text cells a spreadsheet would run as
+// formulas are defused, and that numbers and plain text are left alone.
+func TestRenderRowsCSVInjection(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+
+    type row struct {
+        Name    string  `json:"name"`
+        Comment *string `json:"comment"`
+        Amount  float64 `json:"amount"`
+    }
+    formula := "=HYPERLINK(\"http://evil.example\")"
+    rows := []row{
+        {Name: "=1+1", Comment: &formula, Amount: -5},
+        {Name: "+7 999 000-00-00", Amount: 1.5},
+        {Name: "-2+3"},
+        {Name: "@SUM(A1:A2)"},
+        {Name: "\tcmd"},
+        {Name: "\rcmd"},
+        {Name: "Ann-Marie = Ann"},
+        {Name: ""},
+    }
+    want := [][]string{
+        {"name", "comment", "amount"},
+        {"'=1+1", "'" + formula, "-5"},
+        {"'+7 999 000-00-00", "", "1.5"},
+        {"'-2+3", "", "0"},
+        {"'@SUM(A1:A2)", "", "0"},
+        {"'\tcmd", "", "0"},
+        {"'\rcmd", "", "0"},
+        {"Ann-Marie = Ann", "", "0"},
+        {"", "", "0"},
+    }
+
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = httptest.NewRequest(http.MethodGet, "/report?format=csv", nil)
+    renderRows(c, rows, nil, "report")
+
+    got, err := csv.NewReader(w.Body).ReadAll()
+    if err != nil {
+        t.Fatalf("response is not CSV: %v", err)
+    }
+    if len(got) != len(want) {
+        t.Fatalf("got %d records, want %d", len(got), len(want))
+    }
+    for i := range want {
+        for j := range want[i] {
+            if got[i][j] != want[i][j] {
+                t.Errorf("record %d field %d: got %q, want %q", i, j, got[i][j], want[i][j])
+            }
+        }
+    }
+}
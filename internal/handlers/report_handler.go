@@ -0,0 +1,244 @@
+// internal/handlers/report_handler.go
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// ReportHandler serves the admin order search and reports.
+type ReportHandler struct {
+    svc     services.ReportService
+    cursors *query.CursorCodec
+}
+
+// NewReportHandler creates a new ReportHandler.
+func NewReportHandler(svc services.ReportService, cursors *query.CursorCodec) *ReportHandler {
+    return &ReportHandler{svc: svc, cursors: cursors}
+}
+
+// SearchOrders searches orders across all users.
+// @Summary Search orders of all users
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json,text/csv
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size, at most 100" default(10)
+// @Param after query string false "Cursor from next_cursor"
+// @Param before query string false "Cursor from prev_cursor"
+// @Param user_email query string false "Filter by customer email; also user_email[icontains] etc."
+// @Param product query string false "Filter by product; also product[icontains] etc."
+// @Param status query string false "Filter by status"
+// @Param created_at[gte] query string false "Created at or after, RFC3339 (also gt, lt, lte)"
+// @Param amount[gte] query number false "Minimum price * quantity (also gt, lt, lte)"
+// @Param sort query string false "Sort fields, e.g. -amount" default(-created_at)
+// @Param format query string false "json or csv"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/orders [get]
+func (h *ReportHandler) SearchOrders(c *gin.Context) {
+    spec, err := query.Parse(c.Request.URL.Query(), models.AdminOrderQuerySchema)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := parseListSpec(c, h.cursors, &spec, models.AdminOrderQuerySchema); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    rows, info, err := h.svc.SearchOrders(c.Request.Context(), spec)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    meta, err := pageMeta(c, h.cursors, rows, spec, models.AdminOrderQuerySchema, info)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    renderRows(c, rows, meta, "orders")
+}
+
+// ExportOrders streams all matching orders of all users without paging.
+// @Summary Export orders as JSON, NDJSON or CSV (admin)
+// @Description Accepts the same filters, sort and fields as GET /admin/orders; page, limit and cursors are ignored.
+// @Description The format is chosen by ?format= or the Accept header. Rows are read with a server-side cursor.
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Param format query string false "json, ndjson or csv"
+// @Param user_email query string false "Filter by owner email"
+// @Param product query string false "Filter by product"
+// @Param status query string false "Filter by status"
+// @Param created_at[gte] query string false "Created at or after, RFC3339 (also gt, lt, lte)"
+// @Param sort query string false "Comma-separated sort fields, prefix with - for descending"
+// @Param fields query string false "Comma-separated fields to return"
+// @Success 200 {array} models.AdminOrder
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/orders/export [get]
+func (h *ReportHandler) ExportOrders(c *gin.Context) {
+    spec, err := query.Parse(c.Request.URL.Query(), models.AdminOrderQuerySchema)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    stream, ok := newRowStream(c, "orders", models.AdminOrder{}, spec.Fields)
+    if !ok {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+        return
+    }
+
+    err = h.svc.ExportOrders(c.Request.Context(), spec, func(o models.AdminOrder) error {
+        return stream.Write(o)
+    })
+    stream.Finish(err)
+}
+
+// Revenue reports revenue grouped by day, week or month.
+// @Summary Revenue per period
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json,text/csv
+// @Param period query string false "day, week or month" default(day)
+// @Param from query string false "Orders created at or after, RFC3339"
+// @Param to query string false "Orders created before, RFC3339"
+// @Param format query string false "json or csv"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/reports/revenue [get]
+func (h *ReportHandler) Revenue(c *gin.Context) {
+    rng, ok := parseReportRange(c)
+    if !ok {
+        return
+    }
+    period := c.DefaultQuery("period", models.PeriodDay)
+
+    points, err := h.svc.Revenue(c.Request.Context(), period, rng)
+    if !respondReportError(c, err) {
+        renderRows(c, points, gin.H{"period": period}, "revenue-"+period)
+    }
+}
+
+// TopProducts reports the best selling products by revenue.
+// @Summary Top products
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json,text/csv
+// @Param limit query int false "Number of products, at most 100" default(10)
+// @Param from query string false "Orders created at or after, RFC3339"
+// @Param to query string false "Orders created before, RFC3339"
+// @Param format query string false "json or csv"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/reports/top-products [get]
+func (h *ReportHandler) TopProducts(c *gin.Context) {
+    rng, ok := parseReportRange(c)
+    if !ok {
+        return
+    }
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+        return
+    }
+
+    stats, err := h.svc.TopProducts(c.Request.Context(), limit, rng)
+    if !respondReportError(c, err) {
+        renderRows(c, stats, nil, "top-products")
+    }
+}
+
+// TopCustomers reports the customers with the highest revenue.
+// @Summary Top customers
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json,text/csv
+// @Param limit query int false "Number of customers, at most 100" default(10)
+// @Param from query string false "Orders created at or after, RFC3339"
+// @Param to query string false "Orders created before, RFC3339"
+// @Param format query string false "json or csv"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/reports/top-customers [get]
+func (h *ReportHandler) TopCustomers(c *gin.Context) {
+    rng, ok := parseReportRange(c)
+    if !ok {
+        return
+    }
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+        return
+    }
+
+    stats, err := h.svc.TopCustomers(c.Request.Context(), limit, rng)
+    if !respondReportError(c, err) {
+        renderRows(c, stats, nil, "top-customers")
+    }
+}
+
+// AverageOrderValue reports order count, revenue and average order value.
+// @Summary Average order value
+// @Tags Reports
+// @Security BearerAuth
+// @Produce json,text/csv
+// @Param from query string false "Orders created at or after, RFC3339"
+// @Param to query string false "Orders created before, RFC3339"
+// @Param format query string false "json or csv"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/reports/average-order-value [get]
+func (h *ReportHandler) AverageOrderValue(c *gin.Context) {
+    rng, ok := parseReportRange(c)
+    if !ok {
+        return
+    }
+
+    stats, err := h.svc.OrderValue(c.Request.Context(), rng)
+    if !respondReportError(c, err) {
+        renderRows(c, []models.OrderValueStats{stats}, nil, "average-order-value")
+    }
+}
+
+func parseReportRange(c *gin.Context) (models.ReportRange, bool) {
+    from, to, err := utils.ParseTimeRange(c, "from", "to")
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return models.ReportRange{}, false
+    }
+    return models.ReportRange{From: from, To: to}, true
+}
+
+// respondReportError writes the error response, if any, and reports whether it did.
+func respondReportError(c *gin.Context, err error) bool {
+    switch {
+    case err == nil:
+        return false
+    case services.IsValidation(err):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    default:
+        respondError(c, err)
+    }
+    return true
+}
@@ -0,0 +1,181 @@
+// internal/handlers/stream.go
+package handlers
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "reflect"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// streamFlushEvery is how many rows are buffered before flushing to the client.
+const streamFlushEvery = 500
+
+// streamErrorTrailer carries an error that happened after the body was started.
+const streamErrorTrailer = "X-Stream-Error"
+
+// rowStream writes rows one at a time as JSON ({"data": [...]}), NDJSON or CSV.
+// Nothing is sent before the first row, so a failing query still gets a
+// normal error response; later errors are reported in the body (JSON, NDJSON)
+// and in the X-Stream-Error trailer.
+type rowStream struct {
+    c        *gin.Context
+    format   string
+    filename string
+    fields   []string
+    columns  []string
+    idx      []int
+    csv      *csv.Writer
+    started  bool
+    rows     int
+}
+
+// newRowStream negotiates the format. sample is a zero row used for the CSV header;
+// fields optionally restricts and orders the output columns.
+func newRowStream(c *gin.Context, filename string, sample interface{}, fields []string) (*rowStream, bool) {
+    format, ok := responseFormat(c, formatJSON, formatNDJSON, formatCSV)
+    if !ok {
+        return nil, false
+    }
+    s := &rowStream{c: c, format: format, filename: filename, fields: fields}
+    s.columns, s.idx = csvColumns(reflect.TypeOf(sample))
+    if len(fields) > 0 {
+        pos := make(map[string]int, len(s.columns))
+        for i, name := range s.columns {
+            pos[name] = s.idx[i]
+        }
+        s.columns, s.idx = nil, nil
+        for _, f := range fields {
+            if i, ok := pos[f]; ok {
+                s.columns = append(s.columns, f)
+                s.idx = append(s.idx, i)
+            }
+        }
+    }
+    return s, true
+}
+
+func (s *rowStream) start() {
+    s.started = true
+    h := s.c.Writer.Header()
+    h.Set("Trailer", streamErrorTrailer)
+    h.Set("Cache-Control", "no-store")
+    switch s.format {
+    case formatCSV:
+        h.Set("Content-Type", "text/csv; charset=utf-8")
+        h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, s.filename))
+    case formatNDJSON:
+        h.Set("Content-Type", "application/x-ndjson")
+        h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ndjson"`, s.filename))
+    default:
+        h.Set("Content-Type", "application/json; charset=utf-8")
+        h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, s.filename))
+    }
+    s.c.Status(http.StatusOK)
+
+    switch s.format {
+    case formatCSV:
+        s.csv = csv.NewWriter(s.c.Writer)
+        _ = s.csv.Write(s.columns)
+    case formatJSON:
+        s.c.Writer.WriteString(`{"data":[`)
+    }
+}
+
+// Write sends one row. It fails once the client has gone away, which stops the query.
+func (s *rowStream) Write(row interface{}) error {
+    if err := s.c.Request.Context().Err(); err != nil {
+        return err
+    }
+    if !s.started {
+        s.start()
+    }
+
+    if s.format == formatCSV {
+        v := reflect.ValueOf(row)
+        rec := make([]string, 0, len(s.idx))
+        for _, i := range s.idx {
+            rec = append(rec, csvValue(v.Field(i)))
+        }
+        if err := s.csv.Write(rec); err != nil {
+            return err
+        }
+    } else {
+        var item interface{} = row
+        if len(s.fields) > 0 {
+            m, err := query.ProjectItem(row, s.fields)
+            if err != nil {
+                return err
+            }
+            item = m
+        }
+        b, err := json.Marshal(item)
+        if err != nil {
+            return err
+        }
+        switch {
+        case s.format == formatNDJSON:
+            b = append(b, '\n')
+        case s.rows > 0:
+            b = append([]byte{','}, b...)
+        }
+        if _, err := s.c.Writer.Write(b); err != nil {
+            return err
+        }
+    }
+
+    s.rows++
+    if s.rows%streamFlushEvery == 0 {
+        s.flush()
+    }
+    return nil
+}
+
+// Finish completes the body, reporting err if the stream was cut short.
+func (s *rowStream) Finish(err error) {
+    if err != nil && !s.started {
+        if s.c.Request.Context().Err() == nil {
+            respondError(s.c, err)
+        }
+        return
+    }
+    if !s.started {
+        s.start()
+    }
+    if err != nil {
+        _ = s.c.Error(err)
+    }
+
+    switch s.format {
+    case formatCSV:
+        s.flush()
+    case formatNDJSON:
+        if err != nil {
+            b, _ := json.Marshal(gin.H{"error": err.Error()})
+            s.c.Writer.Write(append(b, '\n'))
+        }
+    default:
+        s.c.Writer.WriteString("]")
+        if err != nil {
+            b, _ := json.Marshal(err.Error())
+            s.c.Writer.WriteString(`,"error":`)
+            s.c.Writer.Write(b)
+        }
+        s.c.Writer.WriteString("}")
+    }
+    if err != nil {
+        s.c.Writer.Header().Set(streamErrorTrailer, err.Error())
+    }
+    s.flush()
+}
+
+func (s *rowStream) flush() {
+    if s.csv != nil {
+        s.csv.Flush()
+    }
+    s.c.Writer.Flush()
+}
@@ -2,63 +2,118 @@
 package handlers
 
 import (
+    "context"
+    "io"
+    "mime"
     "net/http"
+    "strconv"
 
     "github.com/gin-gonic/gin"
     "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
     "github.com/PhosFactum/kvant-backend-practicum/internal/services"
     "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
 )
 
 // UserHandler handles user-related endpoints.
 type UserHandler struct {
-    svc services.UserService
+    svc     services.UserService
+    cursors *query.CursorCodec
 }
 
 // NewUserHandler creates a new UserHandler.
-func NewUserHandler(svc services.UserService) *UserHandler {
-    return &UserHandler{svc: svc}
+func NewUserHandler(svc services.UserService, cursors *query.CursorCodec) *UserHandler {
+    return &UserHandler{svc: svc, cursors: cursors}
 }
 
-// GetUsers retrieves users with pagination and optional age filters.
-// @Summary Get all users with pagination and age filtering
+// GetUsers retrieves users with pagination, filtering and sorting.
+// @Summary Get all users with pagination, filtering and sorting
 // @Tags Users
 // @Security BearerAuth
 // @Produce json
 // @Param page query int false "Page number" default(1)
-// @Param limit query int false "Page size" default(10)
+// @Param limit query int false "Page size, at most 100" default(10)
+// @Param after query string false "Cursor from next_cursor; switches to keyset paging"
+// @Param before query string false "Cursor from prev_cursor; switches to keyset paging"
+// @Param count query bool false "Set to false to skip computing total" default(true)
 // @Param min_age query int false "Minimum age to filter"
 // @Param max_age query int false "Maximum age to filter"
+// @Param name query string false "Filter by name; also name[prefix], name[contains], name[iprefix], name[icontains], name[ieq]"
+// @Param email query string false "Filter by email; same operators as name"
+// @Param created_at[gte] query string false "Created at or after, RFC3339 (also gt, lt, lte)"
+// @Param sort query string false "Comma-separated sort fields, prefix with - for descending, e.g. -age,name"
+// @Param fields query string false "Comma-separated fields to return, e.g. id,name"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} Link "URLs of the next and previous pages"
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /users [get]
 func (h *UserHandler) GetUsers(c *gin.Context) {
-    page, limit, err := utils.ParsePagination(c)
+    h.listUsers(c, false, models.UserQuerySchema)
+}
+
+// ListUsersAdmin lists users for administrators, optionally including soft-deleted ones.
+// @Summary List users including soft-deleted ones
+// @Tags Users
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size, at most 100" default(10)
+// @Param after query string false "Cursor from next_cursor; switches to keyset paging"
+// @Param before query string false "Cursor from prev_cursor; switches to keyset paging"
+// @Param count query bool false "Set to false to skip computing total" default(true)
+// @Param min_age query int false "Minimum age to filter"
+// @Param max_age query int false "Maximum age to filter"
+// @Param name query string false "Filter by name; also name[prefix], name[contains], name[iprefix], name[icontains], name[ieq]"
+// @Param email query string false "Filter by email; same operators as name"
+// @Param created_at[gte] query string false "Created at or after, RFC3339 (also gt, lt, lte)"
+// @Param sort query string false "Comma-separated sort fields, prefix with - for descending, e.g. -age,name"
+// @Param fields query string false "Comma-separated fields to return, e.g. id,name"
+// @Param role query string false "Filter by role, e.g. admin"
+// @Param include_deleted query bool false "Include soft-deleted users"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users [get]
+func (h *UserHandler) ListUsersAdmin(c *gin.Context) {
+    includeDeleted, err := strconv.ParseBool(c.DefaultQuery("include_deleted", "false"))
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_deleted parameter"})
         return
     }
-    minAge, maxAge, err := utils.ParseAgeFilters(c)
+    h.listUsers(c, includeDeleted, models.AdminUserQuerySchema)
+}
+
+func (h *UserHandler) listUsers(c *gin.Context, includeDeleted bool, schema query.Schema) {
+    spec, minAge, maxAge, err := userListSpec(c, includeDeleted, schema)
     if err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if err := parseListSpec(c, h.cursors, &spec, schema); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
 
-    users, total, err := h.svc.List(c.Request.Context(), page, limit, minAge, maxAge)
+    users, info, err := h.svc.List(c.Request.Context(), spec)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    resp, err := pageMeta(c, h.cursors, users, spec, schema, info)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err)
+        return
+    }
+    if resp["data"], err = query.Project(users, spec.Fields); err != nil {
+        respondError(c, err)
         return
     }
+    resp["min_age"] = minAge
+    resp["max_age"] = maxAge
 
-    c.JSON(http.StatusOK, gin.H{
-        "data":    users,
-        "total":   total,
-        "page":    page,
-        "limit":   limit,
-        "min_age": minAge,
-        "max_age": maxAge,
-    })
+    c.JSON(http.StatusOK, resp)
 }
 
 // GetUserByID returns a single user by ID.
@@ -67,6 +122,7 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 // @Security BearerAuth
 // @Param id path int true "User ID"
 // @Success 200 {object} models.User
+// @Header 200 {string} ETag "Current version of the user"
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /user/{id} [get]
@@ -77,11 +133,16 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
         return
     }
     user, err := h.svc.GetByID(c.Request.Context(), id)
-    if err != nil {
+    if services.IsNotFound(err) {
         c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
         return
     }
+    if err != nil {
+        respondError(c, err)
+        return
+    }
 
+    c.Header("ETag", utils.ETag(user.Version))
     c.JSON(http.StatusOK, user)
 }
 
@@ -93,6 +154,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 // @Param user body models.CreateUserInput true "User to create"
 // @Success 201 {object} models.User
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /users [post]
 func (h *UserHandler) CreateUser(c *gin.Context) {
@@ -103,28 +165,37 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
     }
 
     user, err := h.svc.Create(c.Request.Context(), input)
+    if services.IsConflict(err) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondError(c, err)
         return
     }
 
     // Асинхронная задача, например, отправка welcome-email
-    utils.Async(func() {
-        _ = h.svc.SendWelcomeEmail(c.Request.Context(), user)
+    utils.Async(c.Request.Context(), "welcome_email", func(ctx context.Context) error {
+        return h.svc.SendWelcomeEmail(ctx, user)
     })
 
     c.JSON(http.StatusCreated, user)
 }
 
-// UpdateUser modifies an existing user.
+// UpdateUser replaces the editable fields of an existing user.
 // @Summary Update user by ID
 // @Tags Users
 // @Security BearerAuth
 // @Param id path int true "User ID"
-// @Param user body models.User true "Updated user data"
+// @Param If-Match header string true "ETags returned by GET /user/{id} (strong, comma-separated), or *"
+// @Param user body models.UpdateUserInput true "Updated user data, all fields required"
 // @Success 200 {object} models.User
+// @Header 200 {string} ETag "New version of the user"
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /user/{id} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
@@ -133,26 +204,110 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    var input models.User
+    ifMatch := c.GetHeader("If-Match")
+    if ifMatch == "" {
+        c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+        return
+    }
+    match, err := utils.ParseIfMatch(ifMatch)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    var input models.UpdateUserInput
     if err := c.ShouldBindJSON(&input); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if input.Name == nil || input.Email == nil || input.Age == nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "name, email and age are required; use PATCH for partial updates"})
+        return
+    }
 
-    updated, err := h.svc.Update(c.Request.Context(), id, input)
-    if services.IsNotFound(err) {
-        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    updated, err := h.svc.Update(c.Request.Context(), id, match, input)
+    h.respondUpdated(c, updated, err)
+}
+
+// PatchUser partially updates a user.
+// @Summary Partially update user by ID
+// @Description Accepts application/merge-patch+json (RFC 7396, also used for plain application/json)
+// @Description or application/json-patch+json (RFC 6902). Only name, email and age can be patched.
+// @Tags Users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string true "ETags returned by GET /user/{id} (strong, comma-separated), or *"
+// @Param patch body object true "Merge patch object or JSON Patch array"
+// @Success 200 {object} models.User
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /user/{id} [patch]
+func (h *UserHandler) PatchUser(c *gin.Context) {
+    id, err := utils.ParseIDParam(c, "id")
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    ifMatch := c.GetHeader("If-Match")
+    if ifMatch == "" {
+        c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+        return
+    }
+    match, err := utils.ParseIfMatch(ifMatch)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
 
-    c.JSON(http.StatusOK, updated)
+    var format string
+    mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+    switch mediaType {
+    case "application/merge-patch+json", "application/json":
+        format = services.PatchMerge
+    case "application/json-patch+json":
+        format = services.PatchJSON
+    default:
+        c.Header("Accept-Patch", "application/merge-patch+json, application/json-patch+json")
+        c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported patch content type"})
+        return
+    }
+
+    patch, err := io.ReadAll(c.Request.Body)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    updated, err := h.svc.Patch(c.Request.Context(), id, match, format, patch)
+    h.respondUpdated(c, updated, err)
+}
+
+func (h *UserHandler) respondUpdated(c *gin.Context, updated *models.User, err error) {
+    switch {
+    case services.IsNotFound(err):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case services.IsValidation(err):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case services.IsVersionConflict(err):
+        c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+    case services.IsConflict(err):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    case err != nil:
+        respondError(c, err)
+    default:
+        c.Header("ETag", utils.ETag(updated.Version))
+        c.JSON(http.StatusOK, updated)
+    }
 }
 
-// DeleteUser removes a user.
+// DeleteUser soft-deletes a user and its orders; an admin can restore them.
 // @Summary Delete user by ID
 // @Tags Users
 // @Security BearerAuth
@@ -174,10 +329,106 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
         return
     }
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err)
         return
     }
 
     c.Status(http.StatusNoContent)
 }
 
+
+// RestoreUser undoes a soft delete.
+// @Summary Restore a soft-deleted user
+// @Tags Users
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/restore [post]
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+    id, err := utils.ParseIDParam(c, "id")
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    user, err := h.svc.Restore(c.Request.Context(), id)
+    if services.IsNotFound(err) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, user)
+}
+
+// ExportUsers streams all matching users without paging.
+// @Summary Export users as JSON, NDJSON or CSV (admin)
+// @Description Accepts the same filters, sort and fields as GET /admin/users; page, limit and cursors are ignored.
+// @Description The format is chosen by ?format= or the Accept header. Rows are read with a server-side cursor.
+// @Tags Users
+// @Security BearerAuth
+// @Produce json
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Param format query string false "json, ndjson or csv"
+// @Param role query string false "Filter by role, e.g. admin"
+// @Param include_deleted query bool false "Include soft-deleted users"
+// @Param min_age query int false "Minimum age to filter"
+// @Param max_age query int false "Maximum age to filter"
+// @Param sort query string false "Comma-separated sort fields, prefix with - for descending"
+// @Param fields query string false "Comma-separated fields to return"
+// @Success 200 {array} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/export [get]
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+    includeDeleted, err := strconv.ParseBool(c.DefaultQuery("include_deleted", "false"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid include_deleted parameter"})
+        return
+    }
+    spec, _, _, err := userListSpec(c, includeDeleted, models.AdminUserQuerySchema)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    stream, ok := newRowStream(c, "users", models.User{}, spec.Fields)
+    if !ok {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+        return
+    }
+
+    err = h.svc.Export(c.Request.Context(), spec, func(u models.User) error {
+        return stream.Write(u)
+    })
+    stream.Finish(err)
+}
+
+// userListSpec parses filters, sort and fields of a user listing, including the
+// legacy min_age/max_age parameters.
+func userListSpec(c *gin.Context, includeDeleted bool, schema query.Schema) (query.Spec, int, int, error) {
+    minAge, maxAge, err := utils.ParseAgeFilters(c)
+    if err != nil {
+        return query.Spec{}, 0, 0, err
+    }
+    spec, err := query.Parse(c.Request.URL.Query(), schema)
+    if err != nil {
+        return query.Spec{}, 0, 0, err
+    }
+    if minAge > 0 {
+        spec.Filters = append(spec.Filters, query.Filter{Field: "age", Op: query.Gte, Value: minAge})
+    }
+    if maxAge > 0 {
+        spec.Filters = append(spec.Filters, query.Filter{Field: "age", Op: query.Lte, Value: maxAge})
+    }
+    spec.IncludeDeleted = includeDeleted
+    return spec, minAge, maxAge, nil
+}
@@ -0,0 +1,177 @@
+// internal/health/health.go
+package health
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Statuses of a check and of the whole report
+const (
+    StatusOK   = "ok"
+    StatusFail = "fail"
+)
+
+// ErrShuttingDown is reported once Shutdown was called.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Check tells whether a dependency works; it should give up when ctx is done.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check.
+type Result struct {
+    Status   string `json:"status"`
+    Duration string `json:"duration"`
+    Error    string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks. Status is StatusOK if all passed.
+type Report struct {
+    Status string            `json:"status"`
+    Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the readiness checks of the service.
+type Checker struct {
+    timeout time.Duration
+
+    mu     sync.Mutex
+    names  []string
+    checks map[string]Check
+
+    shuttingDown atomic.Bool
+}
+
+// New creates a Checker giving each check at most timeout.
+func New(timeout time.Duration) *Checker {
+    return &Checker{timeout: timeout, checks: make(map[string]Check)}
+}
+
+// Add registers check under name, replacing a check of the same name.
+func (c *Checker) Add(name string, check Check) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if _, ok := c.checks[name]; !ok {
+        c.names = append(c.names, name)
+        sort.Strings(c.names)
+    }
+    c.checks[name] = check
+}
+
+// Shutdown makes the readiness fail from now on, so that load balancers
+// stop sending requests before the server stops accepting them.
+func (c *Checker) Shutdown() {
+    c.shuttingDown.Store(true)
+}
+
+// Run runs all checks concurrently, each within the timeout, and collects
+// the results.
+func (c *Checker) Run(ctx context.Context) Report {
+    c.mu.Lock()
+    names := append([]string(nil), c.names...)
+    checks := make([]Check, len(names))
+    for i, name := range names {
+        checks[i] = c.checks[name]
+    }
+    c.mu.Unlock()
+
+    results := make([]Result, len(names))
+    var wg sync.WaitGroup
+    for i := range checks {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            results[i] = c.run(ctx, checks[i])
+        }(i)
+    }
+    wg.Wait()
+
+    report := Report{Status: StatusOK, Checks: make(map[string]Result, len(names)+1)}
+    if c.shuttingDown.Load() {
+        report.Checks["shutdown"] = Result{Status: StatusFail, Duration: "0s", Error: ErrShuttingDown.Error()}
+        report.Status = StatusFail
+    }
+    for i, name := range names {
+        report.Checks[name] = results[i]
+        if results[i].Status != StatusOK {
+            report.Status = StatusFail
+        }
+    }
+    return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+    ctx, cancel := context.WithTimeout(ctx, c.timeout)
+    defer cancel()
+
+    start := time.Now()
+    errc := make(chan error, 1)
+    go func() {
+        defer func() {
+            if r := recover(); r != nil {
+                errc <- fmt.Errorf("panic: %v", r)
+            }
+        }()
+        errc <- check(ctx)
+    }()
+
+    // A check ignoring ctx does not hold up the report
+    var err error
+    select {
+    case err = <-errc:
+    case <-ctx.Done():
+        err = fmt.Errorf("timed out after %s", c.timeout)
+    }
+
+    result := Result{Status: StatusOK, Duration: time.Since(start).Round(time.Microsecond).String()}
+    if err != nil {
+        result.Status = StatusFail
+        result.Error = err.Error()
+    }
+    return result
+}
+
+// Worker tracks a background worker doing its work every interval. Its check
+// fails if the worker has stopped or has not finished a round for two
+// intervals (plus the check timeout of slack).
+type Worker struct {
+    interval time.Duration
+    slack    time.Duration
+    lastBeat atomic.Int64
+    stopped  atomic.Bool
+}
+
+// Worker registers the check of a worker running every interval under name.
+// The worker should call Beat after each round and Stop when it returns.
+func (c *Checker) Worker(name string, interval time.Duration) *Worker {
+    w := &Worker{interval: interval, slack: c.timeout}
+    w.Beat()
+    c.Add(name, w.check)
+    return w
+}
+
+// Beat records that the worker finished a round.
+func (w *Worker) Beat() {
+    w.lastBeat.Store(time.Now().UnixNano())
+}
+
+// Stop records that the worker returned.
+func (w *Worker) Stop() {
+    w.stopped.Store(true)
+}
+
+func (w *Worker) check(context.Context) error {
+    if w.stopped.Load() {
+        return errors.New("not running")
+    }
+    since := time.Since(time.Unix(0, w.lastBeat.Load()))
+    if since > 2*w.interval+w.slack {
+        return fmt.Errorf("no round finished for %s", since.Round(time.Second))
+    }
+    return nil
+}
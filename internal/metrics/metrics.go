@@ -0,0 +1,135 @@
+// internal/metrics/metrics.go
+package metrics
+
+import (
+    "database/sql"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/collectors"
+    "github.com/prometheus/client_golang/prometheus/promauto"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/cache"
+)
+
+const namespace = "kvant"
+
+var (
+    httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "http_requests_total",
+        Help:      "HTTP requests by method, route template and status.",
+    }, []string{"method", "route", "status"})
+
+    httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+        Namespace: namespace,
+        Name:      "http_request_duration_seconds",
+        Help:      "HTTP request latency by method, route template and status.",
+        Buckets:   prometheus.DefBuckets,
+    }, []string{"method", "route", "status"})
+
+    tasks = promauto.NewCounterVec(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "background_tasks_total",
+        Help:      "Background tasks started, by task.",
+    }, []string{"task"})
+
+    taskFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "background_task_failures_total",
+        Help:      "Background tasks that returned an error or panicked, by task.",
+    }, []string{"task"})
+
+    logins = promauto.NewCounterVec(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "logins_total",
+        Help:      "Login attempts by result (success, failure).",
+    }, []string{"result"})
+
+    usersCreated = promauto.NewCounter(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "users_created_total",
+        Help:      "Users registered or imported.",
+    })
+
+    ordersCreated = promauto.NewCounter(prometheus.CounterOpts{
+        Namespace: namespace,
+        Name:      "orders_created_total",
+        Help:      "Orders created.",
+    })
+
+    orderValue = promauto.NewHistogram(prometheus.HistogramOpts{
+        Namespace: namespace,
+        Name:      "order_value",
+        Help:      "Value (price times quantity) of created orders.",
+        Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+    })
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+    return promhttp.Handler()
+}
+
+// ObserveHTTPRequest records a served request. route is the route template
+// (/user/:id), not the path, to keep the number of series bounded.
+func ObserveHTTPRequest(method, route string, status int, elapsed time.Duration) {
+    code := strconv.Itoa(status)
+    httpRequests.WithLabelValues(method, route, code).Inc()
+    httpDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// TaskStarted records a background task being started.
+func TaskStarted(task string) {
+    tasks.WithLabelValues(task).Inc()
+}
+
+// TaskFailed records a background task failing.
+func TaskFailed(task string) {
+    taskFailures.WithLabelValues(task).Inc()
+}
+
+// LoginAttempted records a login with valid or invalid credentials.
+func LoginAttempted(success bool) {
+    result := "failure"
+    if success {
+        result = "success"
+    }
+    logins.WithLabelValues(result).Inc()
+}
+
+// UsersCreated records n new users.
+func UsersCreated(n int) {
+    usersCreated.Add(float64(n))
+}
+
+// OrderCreated records a new order and its value.
+func OrderCreated(value float64) {
+    ordersCreated.Inc()
+    orderValue.Observe(value)
+}
+
+// RegisterDB exports the connection pool statistics of db, labelled with name.
+func RegisterDB(name string, db *sql.DB) {
+    prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterCache exports the lookup counts of c as kvant_cache_requests_total.
+func RegisterCache(c *cache.Cache) {
+    results := map[string]func(cache.Stats) uint64{
+        "hit":   func(s cache.Stats) uint64 { return s.Hits },
+        "miss":  func(s cache.Stats) uint64 { return s.Misses },
+        "error": func(s cache.Stats) uint64 { return s.Errors },
+    }
+    for result, count := range results {
+        prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+            Namespace:   namespace,
+            Name:        "cache_requests_total",
+            Help:        "Cache lookups by cache and result (hit, miss, error).",
+            ConstLabels: prometheus.Labels{"cache": c.Name(), "result": result},
+        }, func() float64 { return float64(count(c.Stats())) }))
+    }
+}
@@ -0,0 +1,21 @@
+// internal/middleware/admin.go
+package middleware
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// RequireAdmin only lets through callers whose token carries the admin role.
+// Must run after JWTAuthMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if c.GetString("role") != models.RoleAdmin {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+            return
+        }
+        c.Next()
+    }
+}
@@ -0,0 +1,41 @@
+// internal/middleware/apikey.go
+package middleware
+
+import (
+    "crypto/sha256"
+    "crypto/subtle"
+)
+
+// APIKeyHeader is the header identifying API clients.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys is the set of API keys issued to clients. Only their SHA-256
+// hashes are kept, and they are compared in constant time.
+type APIKeys struct {
+    hashes [][sha256.Size]byte
+}
+
+// NewAPIKeys returns the set of the given keys; empty keys are skipped.
+func NewAPIKeys(keys []string) *APIKeys {
+    k := &APIKeys{}
+    for _, key := range keys {
+        if key != "" {
+            k.hashes = append(k.hashes, sha256.Sum256([]byte(key)))
+        }
+    }
+    return k
+}
+
+// Authenticate reports whether key is one of the set and returns its hash.
+// Every key is compared, so the time taken does not tell which one matched.
+func (k *APIKeys) Authenticate(key string) ([sha256.Size]byte, bool) {
+    sum := sha256.Sum256([]byte(key))
+    if k == nil || key == "" {
+        return sum, false
+    }
+    match := 0
+    for _, h := range k.hashes {
+        match |= subtle.ConstantTimeCompare(sum[:], h[:])
+    }
+    return sum, match == 1
+}
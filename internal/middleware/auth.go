@@ -2,56 +2,68 @@
 package middleware
 
 import (
+    "errors"
     "fmt"
     "net/http"
-    "os"
     "strings"
 
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v5"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
 )
 
-// JWTAuthMiddleware checks for a valid Bearer token and
-// injects the user_id claim into the context.
-func JWTAuthMiddleware() gin.HandlerFunc {
+// JWTAuthMiddleware checks for a valid Bearer token signed with secret and
+// injects the user_id and role claims into the context.
+func JWTAuthMiddleware(secret string) gin.HandlerFunc {
     return func(c *gin.Context) {
-        header := c.GetHeader("Authorization")
-        if !strings.HasPrefix(header, "Bearer ") {
-            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid Authorization header"})
+        uid, role, err := bearerClaims(c.GetHeader("Authorization"), secret)
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
             return
         }
 
-        tokenStr := strings.TrimPrefix(header, "Bearer ")
-        secret := os.Getenv("JWT_SECRET")
-        if secret == "" {
-            secret = "supersecret"
-        }
+        c.Set("user_id", uid)
+        c.Set("role", role)
+        c.Request = c.Request.WithContext(
+            utils.WithActor(c.Request.Context(), utils.Actor{ID: uid, Role: role}),
+        )
+        c.Next()
+    }
+}
 
-        token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-            if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-                return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-            }
-            return []byte(secret), nil
-        })
-        if err != nil || !token.Valid {
-            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
-            return
-        }
+// bearerClaims verifies the Bearer token of an Authorization header and
+// returns its user_id and role claims. The error is fit for the response.
+func bearerClaims(header, secret string) (uint, string, error) {
+    if !strings.HasPrefix(header, "Bearer ") {
+        return 0, "", errors.New("missing or invalid Authorization header")
+    }
 
-        claims, ok := token.Claims.(jwt.MapClaims)
-        if !ok {
-            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
-            return
-        }
+    tokenStr := strings.TrimPrefix(header, "Bearer ")
 
-        uidF, ok := claims["user_id"].(float64)
-        if !ok {
-            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user_id missing in token"})
-            return
+    token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
+        if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+            return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
         }
+        return []byte(secret), nil
+    })
+    if err != nil || !token.Valid {
+        return 0, "", errors.New("invalid or expired token")
+    }
 
-        c.Set("user_id", uint(uidF))
-        c.Next()
+    claims, ok := token.Claims.(jwt.MapClaims)
+    if !ok {
+        return 0, "", errors.New("invalid token claims")
     }
-}
 
+    uidF, ok := claims["user_id"].(float64)
+    if !ok {
+        return 0, "", errors.New("user_id missing in token")
+    }
+
+    role, _ := claims["role"].(string)
+    if role == "" {
+        role = models.RoleUser
+    }
+    return uint(uidF), role, nil
+}
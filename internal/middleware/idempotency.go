@@ -0,0 +1,132 @@
+// internal/middleware/idempotency.go
+package middleware
+
+import (
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "io"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+)
+
+const (
+    // IdempotencyKeyHeader is the request header carrying a client-chosen key.
+    IdempotencyKeyHeader = "Idempotency-Key"
+    // IdempotentReplayedHeader marks responses replayed from a previous request.
+    IdempotentReplayedHeader = "Idempotent-Replayed"
+
+    maxIdempotencyKeyLength = 255
+    // idempotencyStoreTimeout bounds storing or releasing a key once the
+    // handler has run, which no longer depends on the client.
+    idempotencyStoreTimeout = 5 * time.Second
+)
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key. Reusing a key with a different method, path or body is
+// rejected with 422, and a retry while the first request still runs gets 409.
+// Server errors are not stored, so the request can be retried.
+// Must run after JWTAuthMiddleware: keys are scoped to the caller.
+func Idempotency(svc services.IdempotencyService) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        key := c.GetHeader(IdempotencyKeyHeader)
+        if key == "" {
+            c.Next()
+            return
+        }
+        if len(key) > maxIdempotencyKeyLength {
+            c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+            return
+        }
+
+        body, err := io.ReadAll(c.Request.Body)
+        if err != nil {
+            c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+            return
+        }
+        c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+        h := sha256.New()
+        h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+        h.Write(body)
+        hash := hex.EncodeToString(h.Sum(nil))
+
+        ctx := c.Request.Context()
+        userID := c.GetUint("user_id")
+        stored, err := svc.Begin(ctx, userID, key, hash)
+        switch {
+        case errors.Is(err, services.ErrIdempotencyKeyReused):
+            c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+            return
+        case errors.Is(err, services.ErrIdempotencyInProgress):
+            c.Header("Retry-After", "1")
+            c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+            return
+        case err != nil:
+            c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        case stored != nil:
+            c.Header(IdempotentReplayedHeader, "true")
+            c.Data(stored.ResponseCode, "application/json; charset=utf-8", []byte(stored.ResponseBody))
+            c.Abort()
+            return
+        }
+
+        rec := &bodyRecorder{ResponseWriter: c.Writer}
+        c.Writer = rec
+        completed := false
+        defer func() {
+            // Also reached when the handler panics: free the key for a retry
+            if !completed {
+                abortCtx, cancel := outcomeContext(ctx)
+                defer cancel()
+                if err := svc.Abort(abortCtx, userID, key); err != nil {
+                    log.Printf("failed to release idempotency key: %v", err)
+                }
+            }
+        }()
+
+        c.Next()
+
+        if rec.Status() >= http.StatusInternalServerError {
+            return
+        }
+        storeCtx, cancel := outcomeContext(ctx)
+        defer cancel()
+        if err := svc.Complete(storeCtx, userID, key, rec.Status(), rec.body.Bytes()); err != nil {
+            log.Printf("failed to store idempotent response: %v", err)
+            return
+        }
+        completed = true
+    }
+}
+
+// outcomeContext returns the context to record the outcome of a request in.
+// It is not cancelled with the request: if the client went away after the
+// handler committed, the key must still be completed, or it would stay in
+// progress until a retry takes it over and repeats the work.
+func outcomeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+    return context.WithTimeout(context.WithoutCancel(ctx), idempotencyStoreTimeout)
+}
+
+// bodyRecorder keeps a copy of the response body.
+type bodyRecorder struct {
+    gin.ResponseWriter
+    body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+    w.body.Write(b)
+    return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+    w.body.WriteString(s)
+    return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/services"
+)
+
+// TestIdempotencyClientGone has the client go away while the handler runs:
+// the response must still be stored, so the retry replays it instead of
+// repeating the work.
+func TestIdempotencyClientGone(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+
+    svc := services.NewIdempotencyService(repository.NewMemoryIdempotencyRepo(repository.NewMemoryStore()), time.Hour)
+    created := 0
+    var disconnect context.CancelFunc
+
+    router := gin.New()
+    router.Use(func(c *gin.Context) { c.Set("user_id", uint(1)) })
+    router.POST("/orders", Idempotency(svc), func(c *gin.Context) {
+        created++
+        if disconnect != nil {
+            disconnect()
+        }
+        c.JSON(http.StatusCreated, gin.H{"id": created})
+    })
+
+    send := func(ctx context.Context) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"product":"a"}`)).WithContext(ctx)
+        req.Header.Set(IdempotencyKeyHeader, "key-1")
+        w := httptest.NewRecorder()
+        router.ServeHTTP(w, req)
+        return w
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    disconnect = cancel
+    first := send(ctx)
+    disconnect = nil
+
+    retry := send(context.Background())
+    if retry.Code != http.StatusCreated || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+        t.Fatalf("retry: got %d %s, want the replayed 201", retry.Code, retry.Body)
+    }
+    if retry.Body.String() != first.Body.String() {
+        t.Errorf("retry body %s, want %s", retry.Body, first.Body)
+    }
+    if created != 1 {
+        t.Errorf("handler ran %d times, want 1", created)
+    }
+}
@@ -0,0 +1,24 @@
+// internal/middleware/metrics.go
+package middleware
+
+import (
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/metrics"
+)
+
+// MetricsMiddleware records the count and latency of every request by its
+// route template. Requests matching no route share the "unmatched" route.
+func MetricsMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+        c.Next()
+
+        route := c.FullPath()
+        if route == "" {
+            route = "unmatched"
+        }
+        metrics.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+    }
+}
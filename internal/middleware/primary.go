@@ -0,0 +1,22 @@
+// internal/middleware/primary.go
+package middleware
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+)
+
+// PrimaryReadsMiddleware pins requests that may write (anything but GET and
+// HEAD) to the primary database, so that what they read before writing is
+// current and what they return reflects their own writes. Reads of GET and
+// HEAD requests may be served by a lagging replica.
+func PrimaryReadsMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if m := c.Request.Method; m != http.MethodGet && m != http.MethodHead {
+            c.Request = c.Request.WithContext(repository.PinPrimary(c.Request.Context()))
+        }
+        c.Next()
+    }
+}
@@ -0,0 +1,76 @@
+// internal/middleware/ratelimit.go
+package middleware
+
+import (
+    "encoding/hex"
+    "fmt"
+    "log"
+    "math"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/ratelimit"
+)
+
+// RateLimitMiddleware throttles the routes that have a policy in l, per
+// client as the policy says, and reports the limit in the RateLimit-* headers
+// (IETF draft). Over the limit it responds 429 with Retry-After. If the store
+// fails, requests are let through. Clients get a bucket of their API key only
+// if it is one of keys, and of their user only if the token is signed with
+// jwtSecret.
+func RateLimitMiddleware(l *ratelimit.Limiter, keys *APIKeys, jwtSecret string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        route := c.FullPath()
+        p, ok := l.Policy(c.Request.Method, route)
+        if !ok {
+            c.Next()
+            return
+        }
+
+        res, err := l.Take(c.Request.Context(), c.Request.Method, route, rateLimitClient(c, p.Key, keys, jwtSecret), p)
+        if err != nil {
+            log.Printf("rate limit store failed, request let through: %v", err)
+            c.Next()
+            return
+        }
+
+        c.Header("RateLimit-Policy", p.String())
+        c.Header("RateLimit-Limit", strconv.Itoa(p.Limit))
+        c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+        c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+        if !res.Allowed {
+            retry := ceilSeconds(res.RetryAfter)
+            c.Header("Retry-After", strconv.Itoa(retry))
+            c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+                "error": fmt.Sprintf("rate limit exceeded, retry in %ds", retry),
+            })
+            return
+        }
+        c.Next()
+    }
+}
+
+// rateLimitClient identifies the client of a request by key: a valid API key
+// (hashed, so it is not kept in the store), the user of a valid Bearer token,
+// or the IP address, which is also used when the request lacks the first two.
+// Unknown API keys fall back to the IP, or made-up keys would get fresh buckets.
+func rateLimitClient(c *gin.Context, key string, keys *APIKeys, jwtSecret string) string {
+    switch key {
+    case ratelimit.KeyAPIKey:
+        if sum, ok := keys.Authenticate(c.GetHeader(APIKeyHeader)); ok {
+            return "key:" + hex.EncodeToString(sum[:16])
+        }
+    case ratelimit.KeyUser:
+        if uid, _, err := bearerClaims(c.GetHeader("Authorization"), jwtSecret); err == nil {
+            return "user:" + strconv.FormatUint(uint64(uid), 10)
+        }
+    }
+    return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds rounds d up to whole seconds, as the headers carry.
+func ceilSeconds(d time.Duration) int {
+    return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/golang-jwt/jwt/v5"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/ratelimit"
+)
+
+const (
+    testJWTSecret = "secret"
+    proxyAddr     = "10.0.0.1:4000"
+)
+
+// rateLimitedRouter limits GET /ping to two requests a minute per client,
+// counted by key. GET /free has no policy.
+func rateLimitedRouter(t *testing.T, key string, trustedProxies []string) *gin.Engine {
+    t.Helper()
+    gin.SetMode(gin.TestMode)
+
+    limiter := ratelimit.New(ratelimit.NewMemory(), map[string]ratelimit.Policy{
+        "GET /ping": {Limit: 2, Period: time.Minute, Key: key},
+    })
+    router := gin.New()
+    if err := router.SetTrustedProxies(trustedProxies); err != nil {
+        t.Fatalf("SetTrustedProxies: %v", err)
+    }
+    router.Use(RateLimitMiddleware(limiter, NewAPIKeys([]string{"good-key"}), testJWTSecret))
+    ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+    router.GET("/ping", ok)
+    router.GET("/free", ok)
+    return router
+}
+
+func get(router *gin.Engine, path string, header http.Header) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(http.MethodGet, path, nil)
+    req.RemoteAddr = proxyAddr
+    for k, vs := range header {
+        for _, v := range vs {
+            req.Header.Add(k, v)
+        }
+    }
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, req)
+    return w
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+    router := rateLimitedRouter(t, ratelimit.KeyIP, nil)
+
+    cases := []struct {
+        code      int
+        remaining string
+        reset     string
+        retry     string
+    }{
+        {http.StatusOK, "1", "30", ""},
+        {http.StatusOK, "0", "60", ""},
+        {http.StatusTooManyRequests, "0", "60", "30"},
+    }
+    for i, c := range cases {
+        w := get(router, "/ping", nil)
+        h := w.Header()
+        if w.Code != c.code {
+            t.Errorf("request %d: got %d, want %d", i+1, w.Code, c.code)
+        }
+        if h.Get("RateLimit-Policy") != "2;w=60" || h.Get("RateLimit-Limit") != "2" {
+            t.Errorf("request %d: policy %q, limit %q", i+1, h.Get("RateLimit-Policy"), h.Get("RateLimit-Limit"))
+        }
+        if h.Get("RateLimit-Remaining") != c.remaining {
+            t.Errorf("request %d: remaining %q, want %q", i+1, h.Get("RateLimit-Remaining"), c.remaining)
+        }
+        // Rounded up, so the refill while the test runs does not show
+        if h.Get("RateLimit-Reset") != c.reset {
+            t.Errorf("request %d: reset %q, want %q", i+1, h.Get("RateLimit-Reset"), c.reset)
+        }
+        if h.Get("Retry-After") != c.retry {
+            t.Errorf("request %d: Retry-After %q, want %q", i+1, h.Get("Retry-After"), c.retry)
+        }
+    }
+
+    if w := get(router, "/free", nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+        t.Errorf("route without a policy: %d, headers %v", w.Code, w.Header())
+    }
+}
+
+// TestRateLimitClient sends three requests per case, which differ only in
+// the headers given, and checks whether they share a bucket.
+func TestRateLimitClient(t *testing.T) {
+    token := func(secret string, userID uint) string {
+        signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": userID}).SignedString([]byte(secret))
+        if err != nil {
+            t.Fatalf("sign token: %v", err)
+        }
+        return "Bearer " + signed
+    }
+
+    cases := []struct {
+        name    string
+        key     string
+        trusted []string
+        headers []http.Header
+        shared  bool
+    }{
+        {
+            name:    "forwarded for, untrusted proxy",
+            key:     ratelimit.KeyIP,
+            headers: forEach("X-Forwarded-For", "203.0.113.1", "203.0.113.2", "203.0.113.3"),
+            shared:  true,
+        },
+        {
+            name:    "forwarded for, trusted proxy",
+            key:     ratelimit.KeyIP,
+            trusted: []string{"10.0.0.0/8"},
+            headers: forEach("X-Forwarded-For", "203.0.113.1", "203.0.113.2", "203.0.113.3"),
+        },
+        {
+            name:    "same client, trusted proxy",
+            key:     ratelimit.KeyIP,
+            trusted: []string{"10.0.0.0/8"},
+            headers: forEach("X-Forwarded-For", "203.0.113.1", "203.0.113.1", "203.0.113.1"),
+            shared:  true,
+        },
+        {
+            name:    "spoofed hop before a trusted proxy",
+            key:     ratelimit.KeyIP,
+            trusted: []string{"10.0.0.0/8"},
+            headers: forEach("X-Forwarded-For", "198.51.100.1, 203.0.113.1", "198.51.100.2, 203.0.113.1", "198.51.100.3, 203.0.113.1"),
+            shared:  true,
+        },
+        {
+            name:    "users",
+            key:     ratelimit.KeyUser,
+            headers: forEach("Authorization", token(testJWTSecret, 1), token(testJWTSecret, 2), token(testJWTSecret, 3)),
+        },
+        {
+            name:    "tokens signed with another secret",
+            key:     ratelimit.KeyUser,
+            headers: forEach("Authorization", token("other", 1), token("other", 2), token("other", 3)),
+            shared:  true,
+        },
+        {
+            name:    "unknown API keys",
+            key:     ratelimit.KeyAPIKey,
+            headers: forEach(APIKeyHeader, "made-up-1", "made-up-2", "made-up-3"),
+            shared:  true,
+        },
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            router := rateLimitedRouter(t, c.key, c.trusted)
+            var last int
+            for _, h := range c.headers {
+                last = get(router, "/ping", h).Code
+            }
+            if limited := last == http.StatusTooManyRequests; limited != c.shared {
+                t.Errorf("third request got %d; shared bucket = %v, want %v", last, limited, c.shared)
+            }
+        })
+    }
+
+    // A configured API key gets its own bucket, apart from the IP's
+    router := rateLimitedRouter(t, ratelimit.KeyAPIKey, nil)
+    get(router, "/ping", nil)
+    get(router, "/ping", nil)
+    if w := get(router, "/ping", http.Header{APIKeyHeader: {"good-key"}}); w.Code != http.StatusOK {
+        t.Errorf("configured API key: got %d, want 200", w.Code)
+    }
+}
+
+// forEach returns one header set per value of the header.
+func forEach(name string, values ...string) []http.Header {
+    headers := make([]http.Header, len(values))
+    for i, v := range values {
+        headers[i] = http.Header{name: {v}}
+    }
+    return headers
+}
@@ -0,0 +1,28 @@
+// internal/middleware/request_id.go
+package middleware
+
+import (
+    "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// RequestIDHeader is the header used to pass request IDs in and out.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestMetaMiddleware assigns a request ID (reusing the incoming one if present)
+// and stores it together with the client IP in the request context.
+func RequestMetaMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        reqID := c.GetHeader(RequestIDHeader)
+        if reqID == "" || len(reqID) > 128 {
+            reqID = utils.NewID()
+        }
+
+        c.Set("request_id", reqID)
+        c.Header(RequestIDHeader, reqID)
+        c.Request = c.Request.WithContext(
+            utils.WithRequestMeta(c.Request.Context(), reqID, c.ClientIP()),
+        )
+        c.Next()
+    }
+}
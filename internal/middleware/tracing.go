@@ -0,0 +1,51 @@
+// internal/middleware/tracing.go
+package middleware
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/propagation"
+    "go.opentelemetry.io/otel/trace"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/tracing"
+)
+
+// TracingMiddleware runs every request in a server span named after its route
+// template, continuing the trace of an incoming traceparent header. It should
+// come first so that the span covers the other middleware.
+func TracingMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+        route := c.FullPath()
+        if route == "" {
+            route = "unmatched"
+        }
+        ctx, span := tracing.Start(ctx, c.Request.Method+" "+route,
+            trace.WithSpanKind(trace.SpanKindServer),
+            trace.WithAttributes(
+                attribute.String("http.request.method", c.Request.Method),
+                attribute.String("http.route", route),
+                attribute.String("url.path", c.Request.URL.Path),
+                attribute.String("client.address", c.ClientIP()),
+            ),
+        )
+        defer span.End()
+
+        c.Request = c.Request.WithContext(ctx)
+        c.Next()
+
+        status := c.Writer.Status()
+        span.SetAttributes(
+            attribute.Int("http.response.status_code", status),
+            attribute.String("request.id", c.GetString("request_id")),
+        )
+        if status >= http.StatusInternalServerError {
+            span.SetStatus(codes.Error, http.StatusText(status))
+        }
+    }
+}
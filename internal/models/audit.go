@@ -0,0 +1,92 @@
+// models/audit.go
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit actions recorded by the service layer
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+	AuditActionErase   = "erase"
+)
+
+// Audited resource types
+const (
+	AuditResourceUser  = "user"
+	AuditResourceOrder = "order"
+)
+
+// AuditEntry is a single append-only record of a mutation.
+// Entries are chained: Hash covers the entry fields and PrevHash,
+// so editing or removing a row breaks the chain.
+// swagger:model
+type AuditEntry struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ActorID      *uint     `json:"actor_id" gorm:"index"`
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resource_type" gorm:"index:idx_audit_resource"`
+	ResourceID   uint      `json:"resource_id" gorm:"index:idx_audit_resource"`
+	Diff         string    `json:"diff" gorm:"type:text"`
+	RequestID    string    `json:"request_id"`
+	IP           string    `json:"ip"`
+	PrevHash     string    `json:"prev_hash"`
+	Hash         string    `json:"hash" gorm:"unique"`
+	CreatedAt    time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName pins the table name used by migrations
+func (AuditEntry) TableName() string {
+	return "audit_logs"
+}
+
+// ComputeHash returns the chain hash of the entry based on PrevHash
+func (e *AuditEntry) ComputeHash() string {
+	actor := ""
+	if e.ActorID != nil {
+		actor = strconv.FormatUint(uint64(*e.ActorID), 10)
+	}
+	payload := strings.Join([]string{
+		e.PrevHash,
+		actor,
+		e.Action,
+		e.ResourceType,
+		strconv.FormatUint(uint64(e.ResourceID), 10),
+		e.Diff,
+		e.RequestID,
+		e.IP,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}, "\x1f")
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter narrows down audit log queries.
+// SubjectID matches entries either made by or about the given user.
+type AuditFilter struct {
+	SubjectID    uint
+	ActorID      *uint
+	Action       string
+	ResourceType string
+	ResourceID   uint
+	From         *time.Time
+	To           *time.Time
+	Page         int
+	Limit        int
+}
+
+// AuditVerifyResult reports the outcome of a hash chain check
+// swagger:model
+type AuditVerifyResult struct {
+	Valid    bool  `json:"valid"`
+	Checked  int   `json:"checked"`
+	BrokenAt *uint `json:"broken_at,omitempty"`
+}
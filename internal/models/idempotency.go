@@ -0,0 +1,25 @@
+// models/idempotency.go
+package models
+
+import "time"
+
+// Idempotency key states
+const (
+	IdempotencyInProgress = "in_progress"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyKey remembers the outcome of a request sent with an Idempotency-Key
+// header so that a retry gets the original response instead of repeating the work.
+// Keys are scoped to the caller.
+type IdempotencyKey struct {
+	UserID       uint   `gorm:"primaryKey;autoIncrement:false"`
+	Key          string `gorm:"primaryKey"`
+	RequestHash  string `gorm:"not null"`
+	Status       string `gorm:"not null"`
+	ResponseCode int
+	ResponseBody string `gorm:"type:text"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ExpiresAt    time.Time `gorm:"index"`
+}
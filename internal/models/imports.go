@@ -0,0 +1,34 @@
+// models/imports.go
+package models
+
+// Bulk import modes
+const (
+	// ImportModeAtomic imports nothing if any row is invalid
+	ImportModeAtomic = "atomic"
+	// ImportModeBestEffort imports the valid rows and reports the rest
+	ImportModeBestEffort = "best_effort"
+)
+
+// ImportRowError describes why a row of an import file was rejected
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportReport is the outcome of a bulk import job
+type ImportReport struct {
+	Mode    string           `json:"mode"`
+	DryRun  bool             `json:"dry_run"`
+	Total   int              `json:"total"`
+	Valid   int              `json:"valid"`
+	Created int              `json:"created"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+// ImportJob is a bulk import job together with its report
+// swagger:model
+type ImportJob struct {
+	Job
+	Report *ImportReport `json:"report,omitempty"`
+}
@@ -0,0 +1,53 @@
+// models/jobs.go
+package models
+
+import "time"
+
+// Background job types
+const (
+	JobTypeExport     = "export"
+	JobTypeErasure    = "erasure"
+	JobTypeUserImport = "user_import"
+)
+
+// Background job statuses
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// Job tracks an asynchronous operation that clients poll for completion
+// swagger:model
+type Job struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status" gorm:"index"`
+	SubjectID   uint       `json:"subject_id" gorm:"index"`
+	RequestedBy *uint      `json:"requested_by"`
+	ResultPath  string     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	Report      string     `json:"-" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobFilter selects jobs for listing. Zero fields do not filter.
+type JobFilter struct {
+	Type      string
+	SubjectID uint
+	// FinishedBefore keeps jobs that finished before this time
+	FinishedBefore *time.Time
+	// HasResult keeps jobs that still have a result file
+	HasResult bool
+}
+
+// UserExport is the machine-readable content of a personal data export
+type UserExport struct {
+	ExportedAt time.Time    `json:"exported_at"`
+	Profile    User         `json:"profile"`
+	Orders     []Order      `json:"orders"`
+	Audit      []AuditEntry `json:"audit"`
+}
@@ -1,17 +1,53 @@
 // models/orders.go
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// Order statuses
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusCancelled = "cancelled"
+)
 
 // Order represents a purchase order linked to a user
 // swagger:model
 type Order struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserID    uint      `json:"-" gorm:"index"`
-	Product   string    `json:"product"`
-	Quantity  int       `json:"quantity"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp with time zone;default:CURRENT_TIMESTAMP"`
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	UserID    uint           `json:"-" gorm:"index"`
+	Product   string         `json:"product"`
+	Quantity  int            `json:"quantity"`
+	Price     float64        `json:"price"`
+	Status    string         `json:"status" gorm:"not null;default:'pending';index"`
+	CreatedAt time.Time      `json:"created_at" gorm:"default:CURRENT_TIMESTAMP"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// OrderQuerySchema lists the order fields that list requests may filter, sort and select
+var OrderQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Kind: query.Number, Filterable: true, Sortable: true},
+		"product":    {Column: "product", Kind: query.String, Filterable: true, Sortable: true},
+		"quantity":   {Column: "quantity", Kind: query.Number, Filterable: true, Sortable: true},
+		"price":      {Column: "price", Kind: query.Number, Filterable: true, Sortable: true},
+		"status":     {Column: "status", Kind: query.String, Filterable: true, Sortable: true},
+		"created_at": {Column: "created_at", Kind: query.Time, Filterable: true, Sortable: true},
+	},
+	DefaultSort: []query.Sort{{Field: "id"}},
+}
+
+// OrderSummary holds aggregates over all orders matching a list request
+// swagger:model
+type OrderSummary struct {
+	Count       int     `json:"count"`
+	TotalAmount float64 `json:"total_amount"`
 }
 
 // OrderRequest defines the payload for creating an order
@@ -22,3 +58,39 @@ type OrderRequest struct {
 	Price    float64 `json:"price" binding:"required,min=0"`
 }
 
+// MaxOrderBatchSize is the largest number of orders accepted in one batch request
+const MaxOrderBatchSize = 100
+
+// OrderBatchRequest defines the payload for creating several orders at once.
+// Atomic defaults to true: one invalid item rejects the whole batch.
+// swagger:model
+type OrderBatchRequest struct {
+	Orders []OrderRequest `json:"orders" binding:"required,min=1"`
+	Atomic *bool          `json:"atomic"`
+}
+
+// OrderBatchItem is the outcome for one item of a batch, in request order
+// swagger:model
+type OrderBatchItem struct {
+	Index int    `json:"index"`
+	Order *Order `json:"order,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// OrderBatchResult is the response to a batch order request
+// swagger:model
+type OrderBatchResult struct {
+	Atomic  bool             `json:"atomic"`
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Items   []OrderBatchItem `json:"items"`
+}
+
+// UpdateOrderInput defines the editable fields of an order.
+// Nil fields are left untouched.
+// swagger:model
+type UpdateOrderInput struct {
+	Product  *string  `json:"product" binding:"omitempty,min=1"`
+	Quantity *int     `json:"quantity" binding:"omitempty,min=1"`
+	Price    *float64 `json:"price" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,86 @@
+// models/reports.go
+package models
+
+import (
+	"time"
+
+	"github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// Report grouping periods
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// AdminOrder is an order as seen in the cross-user admin search
+// swagger:model
+type AdminOrder struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"user_id"`
+	UserEmail string    `json:"user_email"`
+	Product   string    `json:"product"`
+	Quantity  int       `json:"quantity"`
+	Price     float64   `json:"price"`
+	Amount    float64   `json:"amount"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminOrderQuerySchema lists the fields the admin order search may filter and sort by
+var AdminOrderQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":         {Column: "orders.id", Kind: query.Number, Filterable: true, Sortable: true},
+		"user_id":    {Column: "orders.user_id", Kind: query.Number, Filterable: true, Sortable: true},
+		"user_email": {Column: "users.email", Kind: query.String, Filterable: true, Sortable: true},
+		"product":    {Column: "orders.product", Kind: query.String, Filterable: true, Sortable: true},
+		"quantity":   {Column: "orders.quantity", Kind: query.Number, Filterable: true, Sortable: true},
+		"price":      {Column: "orders.price", Kind: query.Number, Filterable: true, Sortable: true},
+		"amount":     {Column: "orders.price * orders.quantity", Kind: query.Number, Filterable: true, Sortable: true},
+		"status":     {Column: "orders.status", Kind: query.String, Filterable: true, Sortable: true},
+		"created_at": {Column: "orders.created_at", Kind: query.Time, Filterable: true, Sortable: true},
+	},
+	DefaultSort: []query.Sort{{Field: "created_at", Desc: true}},
+}
+
+// RevenuePoint is the revenue of one period
+// swagger:model
+type RevenuePoint struct {
+	Period  time.Time `json:"period"`
+	Orders  int       `json:"orders"`
+	Revenue float64   `json:"revenue"`
+}
+
+// ProductStat is the sales summary of one product
+// swagger:model
+type ProductStat struct {
+	Product  string  `json:"product"`
+	Orders   int     `json:"orders"`
+	Quantity int     `json:"quantity"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// CustomerStat is the purchase summary of one customer
+// swagger:model
+type CustomerStat struct {
+	UserID  uint    `json:"user_id"`
+	Email   string  `json:"email"`
+	Name    string  `json:"name"`
+	Orders  int     `json:"orders"`
+	Revenue float64 `json:"revenue"`
+}
+
+// OrderValueStats holds the average order value over a time range
+// swagger:model
+type OrderValueStats struct {
+	Orders            int     `json:"orders"`
+	Revenue           float64 `json:"revenue"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+// ReportRange limits a report to orders created in [From, To)
+type ReportRange struct {
+	From *time.Time
+	To   *time.Time
+}
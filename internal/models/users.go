@@ -1,16 +1,66 @@
 // models/users.go
 package models
 
-// User represents a registered user in the system
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// User represents a registered user in the system. Personal data is tagged
+// audit:"redact": the audit log, which cannot be erased, only records that it changed.
 // swagger:model
 type User struct {
-	ID           uint   `json:"id" gorm:"primaryKey"`
-	Name         string `json:"name"`
-	Email        string `json:"email" gorm:"unique"`
-	Age          int    `json:"age"`
-	PasswordHash string `json:"-" gorm:"column:password_hash"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" audit:"redact"`
+	Email        string         `json:"email" gorm:"unique" audit:"redact"`
+	Age          int            `json:"age" audit:"redact"`
+	Role         string         `json:"role" gorm:"not null;default:'user'"`
+	Version      uint           `json:"version" gorm:"not null;default:1"`
+	PasswordHash string         `json:"-" gorm:"column:password_hash"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DeletedAt    gorm.DeletedAt `json:"deleted_at,omitzero" gorm:"index" swaggertype:"string" format:"date-time"`
 }
 
+// UserQuerySchema lists the user fields that list requests may filter, sort and select.
+// Roles are not filterable here: the public listing must not single out administrators.
+var UserQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Kind: query.Number, Filterable: true, Sortable: true},
+		"name":       {Column: "name", Kind: query.String, Filterable: true, Sortable: true},
+		"email":      {Column: "email", Kind: query.String, Filterable: true, Sortable: true},
+		"age":        {Column: "age", Kind: query.Number, Filterable: true, Sortable: true},
+		"role":       {Column: "role", Kind: query.String},
+		"version":    {Column: "version", Kind: query.Number},
+		"created_at": {Column: "created_at", Kind: query.Time, Filterable: true, Sortable: true},
+		"deleted_at": {Column: "deleted_at", Kind: query.Time},
+	},
+	DefaultSort: []query.Sort{{Field: "id"}},
+}
+
+// AdminUserQuerySchema is UserQuerySchema for administrators, who may also filter by role
+var AdminUserQuerySchema = func() query.Schema {
+	schema := query.Schema{
+		Fields:      make(map[string]query.Field, len(UserQuerySchema.Fields)),
+		DefaultSort: UserQuerySchema.DefaultSort,
+	}
+	for name, field := range UserQuerySchema.Fields {
+		schema.Fields[name] = field
+	}
+	role := schema.Fields["role"]
+	role.Filterable = true
+	schema.Fields["role"] = role
+	return schema
+}()
+
 // CreateUserInput defines the payload for registering a new user
 // swagger:model
 type CreateUserInput struct {
@@ -20,3 +70,11 @@ type CreateUserInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// UpdateUserInput defines the editable fields of a user.
+// Nil fields are left untouched.
+// swagger:model
+type UpdateUserInput struct {
+	Name  *string `json:"name" binding:"omitempty,min=1,max=255"`
+	Email *string `json:"email" binding:"omitempty,email"`
+	Age   *int    `json:"age" binding:"omitempty,min=0,max=150"`
+}
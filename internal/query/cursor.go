@@ -0,0 +1,175 @@
+// internal/query/cursor.go
+package query
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// Cursor is a position in a keyset-ordered list: the values of the sort
+// fields (including the id tie-breaker) of the row to continue from.
+type Cursor struct {
+    Sort   string        `json:"s"`
+    Values []interface{} `json:"v"`
+    // Before means "rows preceding the position" (previous page).
+    Before bool `json:"-"`
+}
+
+// PageInfo describes where a returned page sits in the whole list.
+type PageInfo struct {
+    // Total is nil when counting was skipped.
+    Total   *int
+    HasNext bool
+    HasPrev bool
+}
+
+// CursorCodec signs cursors so clients cannot forge arbitrary positions.
+type CursorCodec struct {
+    secret []byte
+}
+
+// NewCursorCodec returns a codec signing with the given secret.
+func NewCursorCodec(secret string) *CursorCodec {
+    return &CursorCodec{secret: []byte(secret)}
+}
+
+// Encode returns an opaque, URL-safe token for the cursor.
+func (c *CursorCodec) Encode(cur Cursor) (string, error) {
+    payload, err := json.Marshal(cur)
+    if err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(payload) + "." +
+        base64.RawURLEncoding.EncodeToString(c.sign(payload)), nil
+}
+
+// Decode verifies and unpacks a token produced by Encode.
+func (c *CursorCodec) Decode(token string) (Cursor, error) {
+    var cur Cursor
+    p, s, ok := strings.Cut(token, ".")
+    if !ok {
+        return cur, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    payload, err1 := base64.RawURLEncoding.DecodeString(p)
+    sig, err2 := base64.RawURLEncoding.DecodeString(s)
+    if err1 != nil || err2 != nil || !hmac.Equal(sig, c.sign(payload)) {
+        return cur, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    if err := json.Unmarshal(payload, &cur); err != nil {
+        return cur, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    return cur, nil
+}
+
+func (c *CursorCodec) sign(payload []byte) []byte {
+    mac := hmac.New(sha256.New, c.secret)
+    mac.Write(payload)
+    return mac.Sum(nil)
+}
+
+// ParseCursor reads the after/before parameters into spec.Cursor. The cursor
+// must have been issued for the same sort order as the current request.
+func ParseCursor(values url.Values, codec *CursorCodec, spec *Spec, schema Schema) error {
+    after, before := values.Get("after"), values.Get("before")
+    if after == "" && before == "" {
+        return nil
+    }
+    if after != "" && before != "" {
+        return fmt.Errorf("%w: after and before cannot be combined", ErrInvalidQuery)
+    }
+
+    token := after
+    if before != "" {
+        token = before
+    }
+    cur, err := codec.Decode(token)
+    if err != nil {
+        return err
+    }
+
+    order := spec.Ordering(schema)
+    if cur.Sort != sortSignature(order) || len(cur.Values) != len(order) {
+        return fmt.Errorf("%w: cursor does not match the requested sort", ErrInvalidQuery)
+    }
+    for i, s := range order {
+        if schema.Fields[s.Field].Kind != Time {
+            continue
+        }
+        str, ok := cur.Values[i].(string)
+        if !ok {
+            return fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+        }
+        t, err := time.Parse(time.RFC3339Nano, str)
+        if err != nil {
+            return fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+        }
+        cur.Values[i] = t
+    }
+    cur.Before = before != ""
+    spec.Cursor = &cur
+    return nil
+}
+
+// CursorAt builds the cursor pointing at item (a model serialised with the
+// schema's JSON names) under the spec's ordering.
+func CursorAt(item interface{}, spec Spec, schema Schema) (Cursor, error) {
+    b, err := json.Marshal(item)
+    if err != nil {
+        return Cursor{}, err
+    }
+    var m map[string]interface{}
+    if err := json.Unmarshal(b, &m); err != nil {
+        return Cursor{}, err
+    }
+
+    order := spec.Ordering(schema)
+    cur := Cursor{Sort: sortSignature(order), Values: make([]interface{}, len(order))}
+    for i, s := range order {
+        v, ok := m[s.Field]
+        if !ok {
+            return Cursor{}, fmt.Errorf("field %q missing from item", s.Field)
+        }
+        cur.Values[i] = v
+    }
+    return cur, nil
+}
+
+// Window trims the extra row fetched to detect more data, restores natural
+// order for backward pages and reports which neighbouring pages exist.
+func Window[T any](items []T, spec Spec) ([]T, PageInfo) {
+    var info PageInfo
+    extra := len(items) > spec.Limit
+    if extra {
+        items = items[:spec.Limit]
+    }
+
+    switch {
+    case spec.Cursor == nil:
+        info.HasNext, info.HasPrev = extra, spec.Page > 1
+    case spec.Cursor.Before:
+        for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+            items[i], items[j] = items[j], items[i]
+        }
+        info.HasNext, info.HasPrev = true, extra
+    default:
+        info.HasNext, info.HasPrev = extra, true
+    }
+    return items, info
+}
+
+func sortSignature(order []Sort) string {
+    parts := make([]string, len(order))
+    for i, s := range order {
+        parts[i] = s.Field
+        if s.Desc {
+            parts[i] = "-" + s.Field
+        }
+    }
+    return strings.Join(parts, ",")
+}
@@ -0,0 +1,119 @@
+package query
+
+import (
+    "errors"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+var cursorSchema = Schema{
+    Fields: map[string]Field{
+        "id":         {Column: "id", Kind: Number, Sortable: true},
+        "name":       {Column: "name", Kind: String, Sortable: true},
+        "created_at": {Column: "created_at", Kind: Time, Sortable: true},
+    },
+    DefaultSort: []Sort{{Field: "id"}},
+}
+
+type cursorItem struct {
+    ID        uint      `json:"id"`
+    Name      string    `json:"name"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// issueCursor returns the token a listing sorted by sort hands out for item.
+func issueCursor(t *testing.T, codec *CursorCodec, sort string, item cursorItem) string {
+    t.Helper()
+    spec, err := Parse(url.Values{"sort": {sort}}, cursorSchema)
+    if err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    cur, err := CursorAt(item, spec, cursorSchema)
+    if err != nil {
+        t.Fatalf("CursorAt: %v", err)
+    }
+    token, err := codec.Encode(cur)
+    if err != nil {
+        t.Fatalf("Encode: %v", err)
+    }
+    return token
+}
+
+// parseCursor reads the cursor parameters of a listing sorted by sort.
+func parseCursor(codec *CursorCodec, sort string, params url.Values) (Spec, error) {
+    params.Set("sort", sort)
+    spec, err := Parse(params, cursorSchema)
+    if err != nil {
+        return Spec{}, err
+    }
+    err = ParseCursor(params, codec, &spec, cursorSchema)
+    return spec, err
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+    codec := NewCursorCodec("secret")
+    item := cursorItem{ID: 7, Name: "Ann", CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)}
+    token := issueCursor(t, codec, "-created_at,name", item)
+
+    for _, param := range []string{"after", "before"} {
+        spec, err := parseCursor(codec, "-created_at,name", url.Values{param: {token}})
+        if err != nil {
+            t.Fatalf("%s: %v", param, err)
+        }
+        cur := spec.Cursor
+        if cur == nil {
+            t.Fatalf("%s: no cursor", param)
+        }
+        if cur.Before != (param == "before") {
+            t.Errorf("%s: Before = %v", param, cur.Before)
+        }
+        if len(cur.Values) != 3 {
+            t.Fatalf("%s: got %d values, want 3", param, len(cur.Values))
+        }
+        if at, ok := cur.Values[0].(time.Time); !ok || !at.Equal(item.CreatedAt) {
+            t.Errorf("%s: created_at = %v, want %v", param, cur.Values[0], item.CreatedAt)
+        }
+        if cur.Values[1] != "Ann" || cur.Values[2] != float64(7) {
+            t.Errorf("%s: values = %v", param, cur.Values)
+        }
+    }
+}
+
+func TestCursorRejected(t *testing.T) {
+    codec := NewCursorCodec("secret")
+    item := cursorItem{ID: 7, Name: "Ann", CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+    token := issueCursor(t, codec, "name", item)
+    payload, sig, _ := strings.Cut(token, ".")
+
+    // A payload of the same shape pointing elsewhere, with the original signature
+    forged := issueCursor(t, NewCursorCodec("other"), "name", cursorItem{ID: 1, Name: "Zed"})
+    forgedPayload, _, _ := strings.Cut(forged, ".")
+
+    cases := []struct {
+        name   string
+        sort   string
+        params url.Values
+    }{
+        {"tampered payload", "name", url.Values{"after": {forgedPayload + "." + sig}}},
+        {"tampered signature", "name", url.Values{"after": {payload + "." + sig[:len(sig)-2] + "AA"}}},
+        {"other secret", "name", url.Values{"after": {forged}}},
+        {"missing signature", "name", url.Values{"after": {payload}}},
+        {"not base64", "name", url.Values{"after": {"!!." + sig}}},
+        {"sort direction mismatch", "-name", url.Values{"after": {token}}},
+        {"sort field mismatch", "created_at", url.Values{"before": {token}}},
+        {"after and before", "name", url.Values{"after": {token}, "before": {token}}},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            spec, err := parseCursor(codec, c.sort, c.params)
+            if !errors.Is(err, ErrInvalidQuery) {
+                t.Errorf("got %v, want ErrInvalidQuery", err)
+            }
+            if spec.Cursor != nil {
+                t.Error("cursor set despite the error")
+            }
+        })
+    }
+}
@@ -0,0 +1,106 @@
+// internal/query/gorm.go
+package query
+
+import (
+    "strings"
+
+    "gorm.io/gorm"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Apply adds the filters, cursor condition, ordering and column selection of
+// the spec to q. Column names come from the schema only, user input is always
+// bound as a parameter. Backward cursors are fetched in reverse order, see Window.
+func Apply(q *gorm.DB, spec Spec, schema Schema) *gorm.DB {
+    q = ApplyFilters(q, spec, schema)
+    order := spec.Ordering(schema)
+
+    if spec.Cursor != nil {
+        q = applyKeyset(q, spec.Cursor, order, schema)
+    }
+
+    reverse := spec.Cursor != nil && spec.Cursor.Before
+    for _, s := range order {
+        col := schema.Fields[s.Field].Column
+        if s.Desc != reverse {
+            col += " DESC"
+        }
+        q = q.Order(col)
+    }
+
+    if len(spec.Fields) > 0 {
+        // Sort columns are always loaded so a cursor can be built from the last row
+        seen := make(map[string]bool)
+        var cols []string
+        for _, s := range order {
+            seen[s.Field] = true
+            cols = append(cols, schema.Fields[s.Field].Column)
+        }
+        for _, name := range spec.Fields {
+            if !seen[name] {
+                seen[name] = true
+                cols = append(cols, schema.Fields[name].Column)
+            }
+        }
+        q = q.Select(cols)
+    }
+    return q
+}
+
+// applyKeyset adds "(a, b, id) after cursor" as an OR chain, which also works
+// for mixed sort directions: a > x OR (a = x AND b < y) OR (... AND id > z).
+func applyKeyset(q *gorm.DB, cur *Cursor, order []Sort, schema Schema) *gorm.DB {
+    var clauses []string
+    var args []interface{}
+
+    for i, s := range order {
+        var parts []string
+        for j := 0; j < i; j++ {
+            parts = append(parts, schema.Fields[order[j].Field].Column+" = ?")
+            args = append(args, cur.Values[j])
+        }
+        op := " > ?"
+        if s.Desc != cur.Before {
+            op = " < ?"
+        }
+        parts = append(parts, schema.Fields[s.Field].Column+op)
+        args = append(args, cur.Values[i])
+        clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+    }
+    return q.Where("("+strings.Join(clauses, " OR ")+")", args...)
+}
+
+// ApplyFilters adds only the WHERE conditions of the spec, e.g. for counting.
+func ApplyFilters(q *gorm.DB, spec Spec, schema Schema) *gorm.DB {
+    for _, f := range spec.Filters {
+        field, ok := schema.Fields[f.Field]
+        if !ok {
+            continue
+        }
+        col := field.Column
+        switch f.Op {
+        case Eq:
+            q = q.Where(col+" = ?", f.Value)
+        case IEq:
+            q = q.Where("LOWER("+col+") = LOWER(?)", f.Value)
+        case Prefix:
+            q = q.Where(col+` LIKE ? ESCAPE '\'`, likeEscaper.Replace(f.Value.(string))+"%")
+        case IPrefix:
+            q = q.Where("LOWER("+col+`) LIKE LOWER(?) ESCAPE '\'`, likeEscaper.Replace(f.Value.(string))+"%")
+        case Contains:
+            q = q.Where(col+` LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(f.Value.(string))+"%")
+        case IContains:
+            q = q.Where("LOWER("+col+`) LIKE LOWER(?) ESCAPE '\'`, "%"+likeEscaper.Replace(f.Value.(string))+"%")
+        case Gt:
+            q = q.Where(col+" > ?", f.Value)
+        case Gte:
+            q = q.Where(col+" >= ?", f.Value)
+        case Lt:
+            q = q.Where(col+" < ?", f.Value)
+        case Lte:
+            q = q.Where(col+" <= ?", f.Value)
+        }
+    }
+    return q
+}
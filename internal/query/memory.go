@@ -0,0 +1,220 @@
+// internal/query/memory.go
+package query
+
+import (
+    "cmp"
+    "encoding/json"
+    "sort"
+    "strings"
+    "time"
+)
+
+// FilterSlice is the in-memory counterpart of ApplyFilters: it returns the
+// items matching the filters of the spec. Items are read through their JSON
+// field names, the same names the schema uses.
+func FilterSlice[T any](items []T, spec Spec, schema Schema) ([]T, error) {
+    recs, err := toRecords(items)
+    if err != nil {
+        return nil, err
+    }
+    out := make([]T, 0, len(items))
+    for i, rec := range recs {
+        if matchFilters(rec, spec, schema) {
+            out = append(out, items[i])
+        }
+    }
+    return out, nil
+}
+
+// ApplySlice is the in-memory counterpart of Apply: it keeps the items
+// matching the filters and the cursor condition and sorts them by the spec
+// ordering, in reverse for backward cursors (see Window). Field selection is
+// left to Project.
+func ApplySlice[T any](items []T, spec Spec, schema Schema) ([]T, error) {
+    recs, err := toRecords(items)
+    if err != nil {
+        return nil, err
+    }
+    order := spec.Ordering(schema)
+
+    var idx []int
+    for i, rec := range recs {
+        if !matchFilters(rec, spec, schema) {
+            continue
+        }
+        if spec.Cursor != nil && !afterCursor(rec, spec.Cursor, order, schema) {
+            continue
+        }
+        idx = append(idx, i)
+    }
+
+    reverse := spec.Cursor != nil && spec.Cursor.Before
+    sort.SliceStable(idx, func(a, b int) bool {
+        for _, s := range order {
+            kind := schema.Fields[s.Field].Kind
+            c := compareValues(kind, value(recs[idx[a]], s.Field, kind), value(recs[idx[b]], s.Field, kind))
+            if c == 0 {
+                continue
+            }
+            if s.Desc != reverse {
+                return c > 0
+            }
+            return c < 0
+        }
+        return false
+    })
+
+    out := make([]T, len(idx))
+    for i, j := range idx {
+        out[i] = items[j]
+    }
+    return out, nil
+}
+
+func toRecords[T any](items []T) ([]map[string]interface{}, error) {
+    recs := make([]map[string]interface{}, len(items))
+    for i, item := range items {
+        b, err := json.Marshal(item)
+        if err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(b, &recs[i]); err != nil {
+            return nil, err
+        }
+    }
+    return recs, nil
+}
+
+// value returns the field of rec as float64, time.Time or string, or nil
+// when it is missing or null.
+func value(rec map[string]interface{}, name string, kind Kind) interface{} {
+    switch v := rec[name].(type) {
+    case float64:
+        return v
+    case string:
+        if kind == Time {
+            t, err := time.Parse(time.RFC3339Nano, v)
+            if err != nil {
+                return nil
+            }
+            return t
+        }
+        return v
+    default:
+        return nil
+    }
+}
+
+// compareValues orders two values of the same kind. Like SQL, null sorts
+// after every other value.
+func compareValues(kind Kind, a, b interface{}) int {
+    switch {
+    case a == nil && b == nil:
+        return 0
+    case a == nil:
+        return 1
+    case b == nil:
+        return -1
+    }
+    switch kind {
+    case Number:
+        return cmp.Compare(toFloat(a), toFloat(b))
+    case Time:
+        at, _ := a.(time.Time)
+        bt, _ := b.(time.Time)
+        return at.Compare(bt)
+    default:
+        as, _ := a.(string)
+        bs, _ := b.(string)
+        return strings.Compare(as, bs)
+    }
+}
+
+func toFloat(v interface{}) float64 {
+    switch n := v.(type) {
+    case float64:
+        return n
+    case int:
+        return float64(n)
+    case uint:
+        return float64(n)
+    }
+    return 0
+}
+
+func matchFilters(rec map[string]interface{}, spec Spec, schema Schema) bool {
+    for _, f := range spec.Filters {
+        field, ok := schema.Fields[f.Field]
+        if !ok {
+            continue
+        }
+        v := value(rec, f.Field, field.Kind)
+        if v == nil {
+            // Comparisons with NULL are never true in SQL either
+            return false
+        }
+        if !matchFilter(field.Kind, v, f) {
+            return false
+        }
+    }
+    return true
+}
+
+func matchFilter(kind Kind, v interface{}, f Filter) bool {
+    switch f.Op {
+    case Eq:
+        return compareValues(kind, v, f.Value) == 0
+    case Gt:
+        return compareValues(kind, v, f.Value) > 0
+    case Gte:
+        return compareValues(kind, v, f.Value) >= 0
+    case Lt:
+        return compareValues(kind, v, f.Value) < 0
+    case Lte:
+        return compareValues(kind, v, f.Value) <= 0
+    }
+
+    s, _ := v.(string)
+    pattern, _ := f.Value.(string)
+    switch f.Op {
+    case IEq:
+        return strings.ToLower(s) == strings.ToLower(pattern)
+    case Prefix:
+        return strings.HasPrefix(s, pattern)
+    case IPrefix:
+        return strings.HasPrefix(strings.ToLower(s), strings.ToLower(pattern))
+    case Contains:
+        return strings.Contains(s, pattern)
+    case IContains:
+        return strings.Contains(strings.ToLower(s), strings.ToLower(pattern))
+    }
+    return false
+}
+
+// afterCursor is applyKeyset for a single record.
+func afterCursor(rec map[string]interface{}, cur *Cursor, order []Sort, schema Schema) bool {
+    for i, s := range order {
+        kind := schema.Fields[s.Field].Kind
+        c := compareValues(kind, value(rec, s.Field, kind), cursorValue(cur.Values[i], kind))
+        if c == 0 {
+            continue
+        }
+        if s.Desc != cur.Before {
+            return c < 0
+        }
+        return c > 0
+    }
+    return false
+}
+
+// cursorValue normalises a decoded cursor value to the types used by value.
+func cursorValue(v interface{}, kind Kind) interface{} {
+    if s, ok := v.(string); ok && kind == Time {
+        t, err := time.Parse(time.RFC3339Nano, s)
+        if err != nil {
+            return nil
+        }
+        return t
+    }
+    return v
+}
@@ -0,0 +1,45 @@
+// internal/query/project.go
+package query
+
+import (
+    "encoding/json"
+    "reflect"
+)
+
+// Project reduces each element of items (a slice) to the requested JSON fields.
+// With no fields the items are returned unchanged.
+func Project(items interface{}, fields []string) (interface{}, error) {
+    if len(fields) == 0 {
+        return items, nil
+    }
+
+    v := reflect.ValueOf(items)
+    out := make([]map[string]interface{}, 0, v.Len())
+    for i := 0; i < v.Len(); i++ {
+        m, err := ProjectItem(v.Index(i).Interface(), fields)
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, m)
+    }
+    return out, nil
+}
+
+// ProjectItem reduces a single item to the requested JSON fields.
+func ProjectItem(item interface{}, fields []string) (map[string]interface{}, error) {
+    b, err := json.Marshal(item)
+    if err != nil {
+        return nil, err
+    }
+    var full map[string]json.RawMessage
+    if err := json.Unmarshal(b, &full); err != nil {
+        return nil, err
+    }
+    m := make(map[string]interface{}, len(fields))
+    for _, f := range fields {
+        if raw, ok := full[f]; ok {
+            m[f] = raw
+        }
+    }
+    return m, nil
+}
@@ -0,0 +1,199 @@
+// internal/query/spec.go
+package query
+
+import (
+    "errors"
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Kind is the value type of a field, it decides which operators are allowed.
+type Kind int
+
+const (
+    String Kind = iota
+    Number
+    Time
+)
+
+// Op is a filter operator.
+type Op string
+
+const (
+    Eq        Op = "eq"
+    IEq       Op = "ieq"
+    Prefix    Op = "prefix"
+    IPrefix   Op = "iprefix"
+    Contains  Op = "contains"
+    IContains Op = "icontains"
+    Gt        Op = "gt"
+    Gte       Op = "gte"
+    Lt        Op = "lt"
+    Lte       Op = "lte"
+)
+
+var opsByKind = map[Kind][]Op{
+    String: {Eq, IEq, Prefix, IPrefix, Contains, IContains},
+    Number: {Eq, Gt, Gte, Lt, Lte},
+    Time:   {Eq, Gt, Gte, Lt, Lte},
+}
+
+// ErrInvalidQuery wraps every parse error, so handlers can answer 400.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Field describes an attribute of a resource as exposed in the API.
+type Field struct {
+    Column     string
+    Kind       Kind
+    Filterable bool
+    Sortable   bool
+}
+
+// Schema whitelists the fields of a resource. Keys are API (JSON) names;
+// only Column values ever reach SQL.
+type Schema struct {
+    Fields      map[string]Field
+    DefaultSort []Sort
+}
+
+// Filter is a single "field op value" condition.
+type Filter struct {
+    Field string
+    Op    Op
+    Value interface{}
+}
+
+// Sort orders by one field.
+type Sort struct {
+    Field string
+    Desc  bool
+}
+
+// Spec is a parsed list request: filters, ordering, sparse fieldset and paging.
+type Spec struct {
+    Filters        []Filter
+    Sort           []Sort
+    Fields         []string
+    Page           int
+    Limit          int
+    IncludeDeleted bool
+    // Cursor switches paging from offset to keyset mode.
+    Cursor *Cursor
+    // SkipCount avoids the COUNT(*) query; PageInfo.Total stays nil.
+    SkipCount bool
+}
+
+// reserved are query parameters that are never treated as filters.
+var reserved = map[string]bool{
+    "page": true, "limit": true, "sort": true, "fields": true,
+    "include_deleted": true, "format": true,
+    "after": true, "before": true, "count": true,
+}
+
+// Parse reads filters (name=x, name[prefix]=x, age[gte]=18), sort (sort=-age,name)
+// and fields (fields=id,name) from query parameters. Paging is left to the caller.
+// Plain parameters that are not fields of the schema are ignored.
+func Parse(values url.Values, schema Schema) (Spec, error) {
+    var spec Spec
+
+    for key, vals := range values {
+        if reserved[key] || len(vals) == 0 {
+            continue
+        }
+        name, op := key, Eq
+        if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+            name, op = key[:i], Op(key[i+1:len(key)-1])
+        }
+        field, ok := schema.Fields[name]
+        if !ok {
+            if name != key {
+                return Spec{}, fmt.Errorf("%w: unknown filter field %q", ErrInvalidQuery, name)
+            }
+            continue
+        }
+        if !field.Filterable {
+            return Spec{}, fmt.Errorf("%w: field %q cannot be filtered", ErrInvalidQuery, name)
+        }
+        if !allowed(field.Kind, op) {
+            return Spec{}, fmt.Errorf("%w: operator %q is not supported for %q", ErrInvalidQuery, op, name)
+        }
+        for _, raw := range vals {
+            v, err := parseValue(field.Kind, raw)
+            if err != nil {
+                return Spec{}, fmt.Errorf("%w: bad value for %s: %v", ErrInvalidQuery, key, err)
+            }
+            spec.Filters = append(spec.Filters, Filter{Field: name, Op: op, Value: v})
+        }
+    }
+
+    if s := values.Get("sort"); s != "" {
+        for _, part := range strings.Split(s, ",") {
+            part = strings.TrimSpace(part)
+            desc := strings.HasPrefix(part, "-")
+            name := strings.TrimPrefix(strings.TrimPrefix(part, "-"), "+")
+            field, ok := schema.Fields[name]
+            if !ok || !field.Sortable {
+                return Spec{}, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, name)
+            }
+            spec.Sort = append(spec.Sort, Sort{Field: name, Desc: desc})
+        }
+    }
+
+    if s := values.Get("fields"); s != "" {
+        for _, name := range strings.Split(s, ",") {
+            name = strings.TrimSpace(name)
+            if _, ok := schema.Fields[name]; !ok {
+                return Spec{}, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, name)
+            }
+            spec.Fields = append(spec.Fields, name)
+        }
+    }
+
+    return spec, nil
+}
+
+// Sorting returns the requested sort, or the schema default when none was given.
+func (s Spec) Sorting(schema Schema) []Sort {
+    if len(s.Sort) > 0 {
+        return s.Sort
+    }
+    return schema.DefaultSort
+}
+
+// Ordering is Sorting with the id tie-breaker appended, which makes the order
+// total. Both offset and keyset paging rely on it for stable pages.
+func (s Spec) Ordering(schema Schema) []Sort {
+    order := append([]Sort(nil), s.Sorting(schema)...)
+    for _, o := range order {
+        if o.Field == "id" {
+            return order
+        }
+    }
+    if _, ok := schema.Fields["id"]; ok {
+        order = append(order, Sort{Field: "id"})
+    }
+    return order
+}
+
+func allowed(kind Kind, op Op) bool {
+    for _, o := range opsByKind[kind] {
+        if o == op {
+            return true
+        }
+    }
+    return false
+}
+
+func parseValue(kind Kind, raw string) (interface{}, error) {
+    switch kind {
+    case Number:
+        return strconv.ParseFloat(raw, 64)
+    case Time:
+        return time.Parse(time.RFC3339, raw)
+    default:
+        return raw, nil
+    }
+}
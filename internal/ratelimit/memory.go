@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// sweepInterval is how often Memory drops the buckets that have refilled.
+const sweepInterval = time.Minute
+
+// Memory is a Store in process memory: each instance of the service limits
+// its clients on its own.
+type Memory struct {
+    mu        sync.Mutex
+    buckets   map[string]*bucket
+    lastSweep time.Time
+}
+
+type bucket struct {
+    tokens float64
+    last   time.Time
+    // full is when the bucket is full again; after that it is as good as new
+    full time.Time
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory() *Memory {
+    return &Memory{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+func (m *Memory) Take(_ context.Context, key string, p Policy) (Result, error) {
+    now := time.Now()
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if now.Sub(m.lastSweep) >= sweepInterval {
+        m.sweep(now)
+    }
+
+    b, ok := m.buckets[key]
+    if !ok {
+        b = &bucket{tokens: float64(p.Limit), last: now}
+        m.buckets[key] = b
+    }
+    res, tokens := take(b.tokens, b.last, now, p)
+    b.tokens, b.last, b.full = tokens, now, now.Add(res.Reset)
+    return res, nil
+}
+
+// sweep drops the full buckets, so that the store only holds the clients
+// seen lately.
+func (m *Memory) sweep(now time.Time) {
+    for key, b := range m.buckets {
+        if !now.Before(b.full) {
+            delete(m.buckets, key)
+        }
+    }
+    m.lastSweep = now
+}
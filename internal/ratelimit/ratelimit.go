@@ -0,0 +1,98 @@
+// internal/ratelimit/ratelimit.go
+package ratelimit
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "time"
+)
+
+// What a policy counts requests by
+const (
+    KeyIP     = "ip"
+    KeyUser   = "user"
+    KeyAPIKey = "api_key"
+)
+
+// Policy is a token bucket: it holds up to Limit requests and refills at
+// Limit per Period, so a client may burst Limit requests and then go on at
+// the average rate. Key is one of the Key constants.
+type Policy struct {
+    Limit  int
+    Period time.Duration
+    Key    string
+}
+
+// interval is the time it takes to refill one token.
+func (p Policy) interval() time.Duration {
+    return p.Period / time.Duration(p.Limit)
+}
+
+// String formats p as the RateLimit-Policy header value, e.g. "10;w=60".
+func (p Policy) String() string {
+    return fmt.Sprintf("%d;w=%d", p.Limit, int(math.Ceil(p.Period.Seconds())))
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+    // Allowed tells whether a token was left.
+    Allowed bool
+    // Remaining is the number of whole tokens left.
+    Remaining int
+    // Reset is the time until the bucket is full again.
+    Reset time.Duration
+    // RetryAfter is the time until the next token, zero if Allowed.
+    RetryAfter time.Duration
+}
+
+// Store keeps the buckets: in process memory, or shared by all instances of
+// the service (e.g. Redis) so that a client gets the same limit whichever
+// instance serves it.
+type Store interface {
+    // Take takes a token from the bucket of key, filled according to p.
+    Take(ctx context.Context, key string, p Policy) (Result, error)
+}
+
+// Limiter applies the policies of routes.
+type Limiter struct {
+    store    Store
+    policies map[string]Policy
+}
+
+// New creates a limiter keeping buckets in store. policies are keyed by
+// "METHOD /route/:template", as the routes are registered.
+func New(store Store, policies map[string]Policy) *Limiter {
+    return &Limiter{store: store, policies: policies}
+}
+
+// Policy returns the policy of a route, if it has one.
+func (l *Limiter) Policy(method, route string) (Policy, bool) {
+    p, ok := l.policies[method+" "+route]
+    return p, ok
+}
+
+// Take takes a token from the bucket of client for the route's policy p.
+func (l *Limiter) Take(ctx context.Context, method, route, client string, p Policy) (Result, error) {
+    return l.store.Take(ctx, method+" "+route+"|"+client, p)
+}
+
+// take applies the token bucket p to a bucket that had tokens at last and
+// returns the outcome with the tokens left now.
+func take(tokens float64, last, now time.Time, p Policy) (Result, float64) {
+    interval := p.interval()
+    if elapsed := now.Sub(last); elapsed > 0 {
+        tokens = math.Min(float64(p.Limit), tokens+float64(elapsed)/float64(interval))
+    }
+
+    var res Result
+    if tokens >= 1 {
+        tokens--
+        res.Allowed = true
+    } else {
+        res.RetryAfter = time.Duration((1 - tokens) * float64(interval))
+    }
+    res.Remaining = int(tokens)
+    res.Reset = time.Duration((float64(p.Limit) - tokens) * float64(interval))
+    return res, tokens
+}
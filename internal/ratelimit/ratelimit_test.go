@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestTake(t *testing.T) {
+    // One token every 15s
+    p := Policy{Limit: 4, Period: time.Minute}
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    tokens, last := float64(p.Limit), start
+    step := func(at time.Duration) Result {
+        now := start.Add(at)
+        var res Result
+        res, tokens = take(tokens, last, now, p)
+        last = now
+        return res
+    }
+
+    cases := []struct {
+        name string
+        at   time.Duration
+        want Result
+    }{
+        // A full bucket allows a burst of Limit requests at once
+        {"burst 1", 0, Result{Allowed: true, Remaining: 3, Reset: 15 * time.Second}},
+        {"burst 2", 0, Result{Allowed: true, Remaining: 2, Reset: 30 * time.Second}},
+        {"burst 3", 0, Result{Allowed: true, Remaining: 1, Reset: 45 * time.Second}},
+        {"burst 4", 0, Result{Allowed: true, Remaining: 0, Reset: time.Minute}},
+        {"empty", 0, Result{Remaining: 0, Reset: time.Minute, RetryAfter: 15 * time.Second}},
+        // Tokens refill continuously
+        {"partly refilled", 5 * time.Second, Result{Remaining: 0, Reset: 55 * time.Second, RetryAfter: 10 * time.Second}},
+        {"one token back", 15 * time.Second, Result{Allowed: true, Remaining: 0, Reset: time.Minute}},
+        {"two and a half back", 52500 * time.Millisecond, Result{Allowed: true, Remaining: 1, Reset: 37500 * time.Millisecond}},
+        // An idle bucket fills up to Limit and no further
+        {"refilled", time.Hour, Result{Allowed: true, Remaining: 3, Reset: 15 * time.Second}},
+    }
+    for _, c := range cases {
+        if got := step(c.at); got != c.want {
+            t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+        }
+    }
+}
+
+func TestPolicyString(t *testing.T) {
+    cases := []struct {
+        p    Policy
+        want string
+    }{
+        {Policy{Limit: 10, Period: time.Minute}, "10;w=60"},
+        {Policy{Limit: 5, Period: 1500 * time.Millisecond}, "5;w=2"},
+    }
+    for _, c := range cases {
+        if got := c.p.String(); got != c.want {
+            t.Errorf("%+v: got %q, want %q", c.p, got, c.want)
+        }
+    }
+}
+
+func TestMemory(t *testing.T) {
+    ctx := context.Background()
+    m := NewMemory()
+    p := Policy{Limit: 2, Period: time.Hour}
+
+    for i := 0; i < 2; i++ {
+        if res, _ := m.Take(ctx, "a", p); !res.Allowed {
+            t.Fatalf("take %d of a denied within the burst", i+1)
+        }
+    }
+    res, _ := m.Take(ctx, "a", p)
+    if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > 30*time.Minute {
+        t.Errorf("take over the limit: %+v", res)
+    }
+    // Buckets are per key
+    if res, _ := m.Take(ctx, "b", p); !res.Allowed || res.Remaining != 1 {
+        t.Errorf("first take of b: %+v", res)
+    }
+
+    // Full buckets are swept, the others are kept
+    m.sweep(time.Now().Add(time.Hour / 2))
+    if _, ok := m.buckets["a"]; !ok {
+        t.Error("bucket a swept before it refilled")
+    }
+    m.sweep(time.Now().Add(2 * time.Hour))
+    if len(m.buckets) != 0 {
+        t.Errorf("%d buckets left after they refilled", len(m.buckets))
+    }
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+    "context"
+    "sync"
+
+    "gorm.io/gorm"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// AuditRepository defines DB operations for the append-only audit log.
+type AuditRepository interface {
+    Append(ctx context.Context, entry *models.AuditEntry) error
+    List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, int, error)
+    ListAfter(ctx context.Context, afterID uint, limit int) ([]models.AuditEntry, error)
+}
+
+type gormAuditRepo struct {
+    db *DB
+    // mu serialises appends on databases without advisory locks; on Postgres
+    // the advisory lock in Append serialises them across app instances.
+    // Either is held until the transaction of the append ends.
+    mu sync.Mutex
+}
+
+// NewGormAuditRepo creates a GORM implementation.
+func NewGormAuditRepo(db *DB) AuditRepository {
+    return &gormAuditRepo{db: db}
+}
+
+// Append links the entry to the current chain head and stores it.
+func (r *gormAuditRepo) Append(ctx context.Context, entry *models.AuditEntry) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return withinTx(ctx, r.db, func(ctx context.Context, tx *gorm.DB) error {
+        // Both locks are held until the enclosing transaction ends: released
+        // earlier, another append could read the same chain head before this
+        // entry commits and fork the chain. The advisory lock also serializes
+        // appends across instances.
+        if r.db.dialect() == dialectPostgres {
+            if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('audit_logs'))").Error; err != nil {
+                return err
+            }
+        } else {
+            state, _ := txFromContext(ctx)
+            state.hooks.lockUntilEnd(&r.mu)
+        }
+
+        var last models.AuditEntry
+        if err := tx.Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+            return err
+        }
+
+        entry.PrevHash = last.Hash
+        entry.Hash = entry.ComputeHash()
+        return tx.Create(entry).Error
+    })
+}
+
+func (r *gormAuditRepo) List(ctx context.Context, f models.AuditFilter) (_ []models.AuditEntry, _ int, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var entries []models.AuditEntry
+    var total int64
+
+    q := r.db.readConn(ctx).Model(&models.AuditEntry{})
+    if f.SubjectID > 0 {
+        q = q.Where("actor_id = ? OR (resource_type = ? AND resource_id = ?)",
+            f.SubjectID, models.AuditResourceUser, f.SubjectID)
+    }
+    if f.ActorID != nil {
+        q = q.Where("actor_id = ?", *f.ActorID)
+    }
+    if f.Action != "" {
+        q = q.Where("action = ?", f.Action)
+    }
+    if f.ResourceType != "" {
+        q = q.Where("resource_type = ?", f.ResourceType)
+    }
+    if f.ResourceID > 0 {
+        q = q.Where("resource_id = ?", f.ResourceID)
+    }
+    if f.From != nil {
+        q = q.Where("created_at >= ?", *f.From)
+    }
+    if f.To != nil {
+        q = q.Where("created_at < ?", *f.To)
+    }
+
+    // Session lets the filtered query be reused for both the count and the page
+    q = q.Session(&gorm.Session{})
+    if err := q.Count(&total).Error; err != nil {
+        return nil, 0, err
+    }
+
+    offset := (f.Page - 1) * f.Limit
+    if err := q.Order("id DESC").Limit(f.Limit).Offset(offset).Find(&entries).Error; err != nil {
+        return nil, 0, err
+    }
+
+    return entries, int(total), nil
+}
+
+// ListAfter returns entries in chain order starting after the given ID.
+func (r *gormAuditRepo) ListAfter(ctx context.Context, afterID uint, limit int) (_ []models.AuditEntry, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var entries []models.AuditEntry
+    if err := r.db.readConn(ctx).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&entries).Error; err != nil {
+        return nil, err
+    }
+    return entries, nil
+}
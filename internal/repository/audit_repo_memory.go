@@ -0,0 +1,93 @@
+package repository
+
+import (
+    "context"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+type memoryAuditRepo struct {
+    s *MemoryStore
+}
+
+// NewMemoryAuditRepo creates an in-memory implementation.
+func NewMemoryAuditRepo(s *MemoryStore) AuditRepository {
+    return &memoryAuditRepo{s: s}
+}
+
+// Append links the entry to the current chain head and stores it. Outside a
+// transaction it waits for the running one: undoing an entry of that
+// transaction would also drop every entry linked to it meanwhile.
+func (r *memoryAuditRepo) Append(ctx context.Context, entry *models.AuditEntry) error {
+    if memoryTxFromContext(ctx) == nil {
+        r.s.txMu.Lock()
+        defer r.s.txMu.Unlock()
+    }
+    return r.s.update(ctx, func(undo func(func())) error {
+        n := len(r.s.audit)
+        entry.ID = uint(n + 1)
+        entry.PrevHash = ""
+        if n > 0 {
+            entry.PrevHash = r.s.audit[n-1].Hash
+        }
+        entry.Hash = entry.ComputeHash()
+        r.s.audit = append(r.s.audit, *entry)
+        undo(func() { r.s.audit = r.s.audit[:n] })
+        return nil
+    })
+}
+
+func (r *memoryAuditRepo) List(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+    var matched []models.AuditEntry
+    err := r.s.view(ctx, func() error {
+        // Newest first, as in the GORM implementation
+        for i := len(r.s.audit) - 1; i >= 0; i-- {
+            if e := r.s.audit[i]; matchAudit(e, f) {
+                matched = append(matched, e)
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, 0, err
+    }
+
+    total := len(matched)
+    offset := min(max((f.Page-1)*f.Limit, 0), total)
+    end := min(offset+f.Limit, total)
+    return matched[offset:end], total, nil
+}
+
+func matchAudit(e models.AuditEntry, f models.AuditFilter) bool {
+    switch {
+    case f.SubjectID > 0 && !(e.ActorID != nil && *e.ActorID == f.SubjectID) &&
+        !(e.ResourceType == models.AuditResourceUser && e.ResourceID == f.SubjectID):
+        return false
+    case f.ActorID != nil && (e.ActorID == nil || *e.ActorID != *f.ActorID):
+        return false
+    case f.Action != "" && e.Action != f.Action:
+        return false
+    case f.ResourceType != "" && e.ResourceType != f.ResourceType:
+        return false
+    case f.ResourceID > 0 && e.ResourceID != f.ResourceID:
+        return false
+    case f.From != nil && e.CreatedAt.Before(*f.From):
+        return false
+    case f.To != nil && !e.CreatedAt.Before(*f.To):
+        return false
+    }
+    return true
+}
+
+// ListAfter returns entries in chain order starting after the given ID.
+func (r *memoryAuditRepo) ListAfter(ctx context.Context, afterID uint, limit int) ([]models.AuditEntry, error) {
+    var entries []models.AuditEntry
+    err := r.s.view(ctx, func() error {
+        // Entry IDs are their 1-based position in the chain
+        start := min(int(afterID), len(r.s.audit))
+        end := min(start+limit, len(r.s.audit))
+        entries = append(entries, r.s.audit[start:end]...)
+        return nil
+    })
+    return entries, err
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// TestAuditConcurrentAppends appends from concurrent transactions, some of
+// which roll back, and from outside any transaction, and checks that the
+// chain neither forks nor loses committed entries.
+func TestAuditConcurrentAppends(t *testing.T) {
+    const workers = 8
+    errRollback := errors.New("rollback")
+
+    for _, b := range backends() {
+        t.Run(b.name, func(t *testing.T) {
+            r := b.open(t)
+            ctx := context.Background()
+
+            var wg sync.WaitGroup
+            errs := make(chan error, 2*workers)
+            for i := 0; i < workers; i++ {
+                wg.Add(2)
+                go func() {
+                    defer wg.Done()
+                    err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+                        // Two appends of one transaction must not wait for each other
+                        for j := 0; j < 2; j++ {
+                            if err := r.audit.Append(ctx, auditEntry(uint(i))); err != nil {
+                                return err
+                            }
+                            time.Sleep(time.Millisecond)
+                        }
+                        if i%2 == 1 {
+                            return errRollback
+                        }
+                        return nil
+                    })
+                    if err != nil && !errors.Is(err, errRollback) {
+                        errs <- err
+                    }
+                }()
+                go func() {
+                    defer wg.Done()
+                    // Land among the appends of the transactions
+                    time.Sleep(time.Duration(2*i+1) * time.Millisecond)
+                    if err := r.audit.Append(ctx, auditEntry(uint(i))); err != nil {
+                        errs <- err
+                    }
+                }()
+            }
+            wg.Wait()
+            close(errs)
+            for err := range errs {
+                t.Fatalf("Append: %v", err)
+            }
+
+            entries, err := r.audit.ListAfter(ctx, 0, 100)
+            if err != nil {
+                t.Fatalf("ListAfter: %v", err)
+            }
+            // Committed transactions made two entries each, the others one
+            if want := workers + workers/2*2; len(entries) != want {
+                t.Errorf("got %d entries, want %d", len(entries), want)
+            }
+            prev := ""
+            for _, e := range entries {
+                if e.PrevHash != prev || e.Hash != e.ComputeHash() {
+                    t.Fatalf("chain broken at entry %d", e.ID)
+                }
+                prev = e.Hash
+            }
+        })
+    }
+}
+
+func auditEntry(resourceID uint) *models.AuditEntry {
+    return &models.AuditEntry{
+        Action:       models.AuditActionUpdate,
+        ResourceType: models.AuditResourceUser,
+        ResourceID:   resourceID,
+        Diff:         "{}",
+        CreatedAt:    time.Now().UTC().Truncate(time.Microsecond),
+    }
+}
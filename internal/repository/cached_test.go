@@ -0,0 +1,105 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/cache"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// TestCachedReposFollowCommits checks that cached reads see committed writes
+// at once, and that writes of a rolled-back transaction leave the cache alone.
+func TestCachedReposFollowCommits(t *testing.T) {
+    errRollback := errors.New("rollback")
+    spec := query.Spec{Page: 1, Limit: 10}
+
+    for _, b := range backends() {
+        t.Run(b.name, func(t *testing.T) {
+            r := b.open(t)
+            ctx := context.Background()
+            c := cache.New("test", cache.NewLRU(100), time.Minute)
+            users := NewCachedUserRepo(r.users, c)
+            orders := NewCachedOrderRepo(r.orders, c)
+
+            user := createUser(t, ctx, r, "ann@example.com", 30)
+            assertCachedName(t, ctx, users, user.ID, user.Name)
+            if page, _, err := orders.ListByUser(ctx, user.ID, spec); err != nil || len(page) != 0 {
+                t.Fatalf("ListByUser: %d orders, %v", len(page), err)
+            }
+            // Served from the cache now
+            hits := c.Stats().Hits
+            assertCachedName(t, ctx, users, user.ID, user.Name)
+            if got := c.Stats().Hits; got != hits+1 {
+                t.Fatalf("second read: %d hits, want %d", got, hits+1)
+            }
+            gen := c.Generation(ctx, userGenerationKey(user.ID))
+
+            err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+                changed := *user
+                changed.Name = "Rolled back"
+                if err := users.Update(ctx, &changed); err != nil {
+                    return err
+                }
+                if err := orders.Create(ctx, &models.Order{UserID: user.ID, Product: "a", Quantity: 1, Price: 1}); err != nil {
+                    return err
+                }
+                // Reads in the transaction see its writes, bypassing the cache
+                assertCachedName(t, ctx, users, user.ID, "Rolled back")
+                return errRollback
+            })
+            if !errors.Is(err, errRollback) {
+                t.Fatalf("WithinTx: %v", err)
+            }
+            if got := c.Generation(ctx, userGenerationKey(user.ID)); got != gen {
+                t.Error("rolled-back writes dropped the cached entries")
+            }
+            assertCachedName(t, ctx, users, user.ID, user.Name)
+            if page, _, err := orders.ListByUser(ctx, user.ID, spec); err != nil || len(page) != 0 {
+                t.Errorf("ListByUser after rollback: %d orders, %v", len(page), err)
+            }
+
+            err = r.tx.WithinTx(ctx, func(ctx context.Context) error {
+                changed := *user
+                changed.Name = "Committed"
+                if err := users.Update(ctx, &changed); err != nil {
+                    return err
+                }
+                return orders.Create(ctx, &models.Order{UserID: user.ID, Product: "b", Quantity: 1, Price: 1})
+            })
+            if err != nil {
+                t.Fatalf("WithinTx: %v", err)
+            }
+            if got := c.Generation(ctx, userGenerationKey(user.ID)); got == gen {
+                t.Error("committed writes kept the cached entries")
+            }
+            assertCachedName(t, ctx, users, user.ID, "Committed")
+            if page, _, err := orders.ListByUser(ctx, user.ID, spec); err != nil || len(page) != 1 {
+                t.Errorf("ListByUser after commit: %d orders, %v", len(page), err)
+            }
+
+            // A read pinned to the primary does not use the cache
+            stats := c.Stats()
+            if _, err := users.GetByID(PinPrimary(ctx), user.ID); err != nil {
+                t.Fatalf("GetByID pinned to the primary: %v", err)
+            }
+            if got := c.Stats(); got != stats {
+                t.Errorf("pinned read used the cache: stats %+v, then %+v", stats, got)
+            }
+        })
+    }
+}
+
+func assertCachedName(t *testing.T, ctx context.Context, users UserRepository, id uint, want string) {
+    t.Helper()
+    user, err := users.GetByID(ctx, id)
+    if err != nil {
+        t.Fatalf("GetByID: %v", err)
+    }
+    if user.Name != want {
+        t.Errorf("name %q, want %q", user.Name, want)
+    }
+}
@@ -0,0 +1,629 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "gorm.io/driver/postgres"
+    "gorm.io/driver/sqlite"
+    "gorm.io/gorm"
+    "gorm.io/gorm/logger"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// testDSNEnv names the variable with a Postgres DSN to run the GORM cases on
+// Postgres too, e.g. "host=localhost user=kvant dbname=kvant_test
+// sslmode=disable TimeZone=UTC". The tests drop and recreate the tables there.
+const testDSNEnv = "TEST_POSTGRES_DSN"
+
+// repos is one backend's implementation of the repositories under contract.
+type repos struct {
+    users   UserRepository
+    orders  OrderRepository
+    reports ReportRepository
+    audit   AuditRepository
+    tx      TxManager
+    // db is nil for the in-memory backend
+    db *DB
+}
+
+// backend opens an empty set of repositories, released when the test ends.
+type backend struct {
+    name string
+    open func(t *testing.T) repos
+}
+
+func backends() []backend {
+    return []backend{
+        {name: "memory", open: openMemory},
+        {name: "sqlite", open: openSQLite},
+        {name: "postgres", open: openPostgres},
+    }
+}
+
+// sqlBackends are the backends with a database, see openDB.
+func sqlBackends() []backend {
+    return backends()[1:]
+}
+
+func openMemory(t *testing.T) repos {
+    s := NewMemoryStore()
+    return repos{
+        users:   NewMemoryUserRepo(s),
+        orders:  NewMemoryOrderRepo(s),
+        reports: NewMemoryReportRepo(s),
+        audit:   NewMemoryAuditRepo(s),
+        tx:      NewMemoryTxManager(s),
+    }
+}
+
+// openSQLite opens a database in a temporary file, set up as the server does.
+func openSQLite(t *testing.T) repos {
+    return gormRepos(openDB(t, sqlite.Open(sqliteDSN(t.TempDir()))))
+}
+
+// openPostgres opens the database named by testDSNEnv with empty tables, or
+// skips the test if there is none.
+func openPostgres(t *testing.T) repos {
+    dsn := os.Getenv(testDSNEnv)
+    if dsn == "" {
+        t.Skip(testDSNEnv + " is not set")
+    }
+    return gormRepos(openDB(t, postgres.Open(dsn)))
+}
+
+func sqliteDSN(dir string) string {
+    return "file:" + filepath.Join(dir, "kvant.db") + "?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate&_cslike=true"
+}
+
+// openDB connects and migrates like the server, starting from empty tables.
+func openDB(t *testing.T, dialector gorm.Dialector) *gorm.DB {
+    t.Helper()
+    db, err := Open(dialector, false)
+    if err != nil {
+        t.Fatalf("open %s: %v", dialector.Name(), err)
+    }
+    db.Logger = logger.Discard
+    sqlDB, err := db.DB()
+    if err != nil {
+        t.Fatalf("open %s: %v", dialector.Name(), err)
+    }
+    t.Cleanup(func() { sqlDB.Close() })
+
+    schema := []interface{}{&models.User{}, &models.Order{}, &models.AuditEntry{}, &models.Job{}, &models.IdempotencyKey{}}
+    if err := db.Migrator().DropTable(schema...); err != nil {
+        t.Fatalf("drop tables: %v", err)
+    }
+    if err := Migrate(db, schema...); err != nil {
+        t.Fatalf("migrate: %v", err)
+    }
+    return db
+}
+
+func gormRepos(db *gorm.DB) repos {
+    d := NewDB(db, Timeouts{Read: 5 * time.Second, Write: 5 * time.Second})
+    return repos{
+        users:   NewGormUserRepo(d),
+        orders:  NewGormOrderRepo(d),
+        reports: NewGormReportRepo(d),
+        audit:   NewGormAuditRepo(d),
+        tx:      NewGormTxManager(d),
+        db:      d,
+    }
+}
+
+// TestRepositoryContract runs the same cases against every backend, so the
+// in-memory repositories keep the semantics of the GORM ones.
+func TestRepositoryContract(t *testing.T) {
+    cases := []struct {
+        name string
+        run  func(t *testing.T, ctx context.Context, r repos)
+    }{
+        {"create applies defaults", testCreateDefaults},
+        {"duplicate email", testDuplicateEmail},
+        {"not found", testNotFound},
+        {"offset pages", testOffsetPages},
+        {"cursor pages", testCursorPages},
+        {"age filters", testAgeFilters},
+        {"soft delete and restore", testSoftDeleteRestore},
+        {"create batch", testCreateBatch},
+        {"times are stored in UTC", testTimesInUTC},
+        {"revenue by period", testRevenueByPeriod},
+        {"stream", testStream},
+        {"stream in a transaction", testStreamInTx},
+    }
+
+    for _, b := range backends() {
+        t.Run(b.name, func(t *testing.T) {
+            for _, c := range cases {
+                t.Run(c.name, func(t *testing.T) {
+                    c.run(t, context.Background(), b.open(t))
+                })
+            }
+        })
+    }
+}
+
+func testCreateDefaults(t *testing.T, ctx context.Context, r repos) {
+    u := createUser(t, ctx, r, "ann@example.com", 30)
+    if u.ID == 0 {
+        t.Fatal("ID not assigned")
+    }
+
+    got, err := r.users.GetByID(ctx, u.ID)
+    if err != nil {
+        t.Fatalf("GetByID: %v", err)
+    }
+    if got.Email != u.Email || got.Role != models.RoleUser || got.Version != 1 || got.CreatedAt.IsZero() {
+        t.Errorf("got %+v, want email %s, role user, version 1 and a creation time", got, u.Email)
+    }
+}
+
+func testDuplicateEmail(t *testing.T, ctx context.Context, r repos) {
+    createUser(t, ctx, r, "ann@example.com", 30)
+
+    err := r.users.Create(ctx, &models.User{Name: "Ann", Email: "ann@example.com", Age: 31})
+    if !errors.Is(err, ErrDuplicateKey) {
+        t.Fatalf("Create with a taken email: got %v, want ErrDuplicateKey", err)
+    }
+
+    other := createUser(t, ctx, r, "bob@example.com", 40)
+    other.Email = "ann@example.com"
+    if err := r.users.Update(ctx, other); !errors.Is(err, ErrDuplicateKey) {
+        t.Fatalf("Update to a taken email: got %v, want ErrDuplicateKey", err)
+    }
+}
+
+func testNotFound(t *testing.T, ctx context.Context, r repos) {
+    u := createUser(t, ctx, r, "ann@example.com", 30)
+
+    if _, err := r.users.GetByID(ctx, 999); !errors.Is(err, ErrNotFound) {
+        t.Errorf("users.GetByID: got %v, want ErrNotFound", err)
+    }
+    if _, err := r.users.FindByEmail(ctx, "nobody@example.com"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("FindByEmail: got %v, want ErrNotFound", err)
+    }
+    if err := r.users.Delete(ctx, 999); !errors.Is(err, ErrNotFound) {
+        t.Errorf("users.Delete: got %v, want ErrNotFound", err)
+    }
+    if _, err := r.users.Restore(ctx, u.ID); !errors.Is(err, ErrNotFound) {
+        t.Errorf("Restore of a live user: got %v, want ErrNotFound", err)
+    }
+    if _, err := r.orders.GetByID(ctx, 999); !errors.Is(err, ErrNotFound) {
+        t.Errorf("orders.GetByID: got %v, want ErrNotFound", err)
+    }
+}
+
+func testOffsetPages(t *testing.T, ctx context.Context, r repos) {
+    var ids []uint
+    for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+        ids = append(ids, createUser(t, ctx, r, email, 30).ID)
+    }
+
+    users, info, err := r.users.List(ctx, query.Spec{Page: 2, Limit: 2})
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    assertIDs(t, users, ids[2:4])
+    if info.Total == nil || *info.Total != 5 || !info.HasNext || !info.HasPrev {
+        t.Errorf("page 2 of 3: got %+v (total %v)", info, info.Total)
+    }
+
+    users, info, err = r.users.List(ctx, query.Spec{Page: 3, Limit: 2, SkipCount: true})
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    assertIDs(t, users, ids[4:])
+    if info.Total != nil || info.HasNext || !info.HasPrev {
+        t.Errorf("last page without count: got %+v", info)
+    }
+}
+
+func testCursorPages(t *testing.T, ctx context.Context, r repos) {
+    // Ties on age are broken by id
+    ages := []int{30, 50, 40, 50, 20}
+    var users []*models.User
+    for i, age := range ages {
+        users = append(users, createUser(t, ctx, r, string(rune('a'+i))+"@example.com", age))
+    }
+    want := []uint{users[1].ID, users[3].ID, users[2].ID, users[0].ID, users[4].ID}
+
+    spec := query.Spec{Sort: []query.Sort{{Field: "age", Desc: true}}, Page: 1, Limit: 2}
+    var got []uint
+    for page := 0; ; page++ {
+        items, info, err := r.users.List(ctx, spec)
+        if err != nil {
+            t.Fatalf("List: %v", err)
+        }
+        for _, u := range items {
+            got = append(got, u.ID)
+        }
+        if info.HasPrev != (page > 0) {
+            t.Errorf("page %d: HasPrev = %v", page, info.HasPrev)
+        }
+        if !info.HasNext {
+            break
+        }
+        if page > len(ages) {
+            t.Fatal("pages do not end")
+        }
+        cur, err := query.CursorAt(items[len(items)-1], spec, models.UserQuerySchema)
+        if err != nil {
+            t.Fatalf("CursorAt: %v", err)
+        }
+        spec.Cursor = &cur
+    }
+    if !equalIDs(got, want) {
+        t.Fatalf("forward pages: got %v, want %v", got, want)
+    }
+
+    // The page before the fourth user is the two preceding it, in list order
+    cur, err := query.CursorAt(users[0], spec, models.UserQuerySchema)
+    if err != nil {
+        t.Fatalf("CursorAt: %v", err)
+    }
+    cur.Before = true
+    spec.Cursor = &cur
+    items, info, err := r.users.List(ctx, spec)
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    assertIDs(t, items, want[1:3])
+    if !info.HasNext || !info.HasPrev {
+        t.Errorf("backward page: got %+v", info)
+    }
+}
+
+func testAgeFilters(t *testing.T, ctx context.Context, r repos) {
+    var ids []uint
+    for i, age := range []int{17, 18, 30, 65, 66} {
+        ids = append(ids, createUser(t, ctx, r, string(rune('a'+i))+"@example.com", age).ID)
+    }
+
+    cases := []struct {
+        filters []query.Filter
+        want    []uint
+    }{
+        {[]query.Filter{{Field: "age", Op: query.Eq, Value: 30.0}}, ids[2:3]},
+        {[]query.Filter{{Field: "age", Op: query.Gte, Value: 18.0}}, ids[1:]},
+        {[]query.Filter{{Field: "age", Op: query.Gt, Value: 18.0}, {Field: "age", Op: query.Lte, Value: 65.0}}, ids[2:4]},
+        {[]query.Filter{{Field: "age", Op: query.Lt, Value: 18.0}}, ids[:1]},
+        {[]query.Filter{{Field: "age", Op: query.Gt, Value: 100.0}}, nil},
+    }
+    for _, c := range cases {
+        users, info, err := r.users.List(ctx, query.Spec{Filters: c.filters, Page: 1, Limit: 10})
+        if err != nil {
+            t.Fatalf("List %+v: %v", c.filters, err)
+        }
+        assertIDs(t, users, c.want)
+        if info.Total == nil || *info.Total != len(c.want) {
+            t.Errorf("List %+v: total %v, want %d", c.filters, info.Total, len(c.want))
+        }
+    }
+}
+
+func testSoftDeleteRestore(t *testing.T, ctx context.Context, r repos) {
+    ann := createUser(t, ctx, r, "ann@example.com", 30)
+    bob := createUser(t, ctx, r, "bob@example.com", 40)
+    kept := createOrder(t, ctx, r, ann.ID, "book")
+    cancelled := createOrder(t, ctx, r, ann.ID, "pen")
+    if err := r.orders.Delete(ctx, cancelled.ID); err != nil {
+        t.Fatalf("orders.Delete: %v", err)
+    }
+
+    if err := r.users.Delete(ctx, ann.ID); err != nil {
+        t.Fatalf("Delete: %v", err)
+    }
+    if _, err := r.users.GetByID(ctx, ann.ID); !errors.Is(err, ErrNotFound) {
+        t.Errorf("GetByID of a deleted user: got %v, want ErrNotFound", err)
+    }
+    if _, err := r.orders.GetByID(ctx, kept.ID); !errors.Is(err, ErrNotFound) {
+        t.Errorf("GetByID of an order of a deleted user: got %v, want ErrNotFound", err)
+    }
+    users, _, err := r.users.List(ctx, query.Spec{Page: 1, Limit: 10})
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    assertIDs(t, users, []uint{bob.ID})
+    users, _, err = r.users.List(ctx, query.Spec{Page: 1, Limit: 10, IncludeDeleted: true})
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    assertIDs(t, users, []uint{ann.ID, bob.ID})
+    if !users[0].DeletedAt.Valid {
+        t.Error("deleted user listed without deleted_at")
+    }
+
+    // The email stays reserved until the user is purged
+    if taken, err := r.users.EmailTaken(ctx, ann.Email); err != nil || !taken {
+        t.Errorf("EmailTaken of a deleted user: got %v, %v", taken, err)
+    }
+    if err := r.users.Create(ctx, &models.User{Name: "Ann", Email: ann.Email}); !errors.Is(err, ErrDuplicateKey) {
+        t.Errorf("Create with the email of a deleted user: got %v, want ErrDuplicateKey", err)
+    }
+
+    restored, err := r.users.Restore(ctx, ann.ID)
+    if err != nil {
+        t.Fatalf("Restore: %v", err)
+    }
+    if restored.ID != ann.ID || restored.DeletedAt.Valid {
+        t.Errorf("Restore: got %+v", restored)
+    }
+    if _, err := r.users.GetByID(ctx, ann.ID); err != nil {
+        t.Errorf("GetByID of a restored user: %v", err)
+    }
+    // Only the orders deleted along with the user come back
+    if _, err := r.orders.GetByID(ctx, kept.ID); err != nil {
+        t.Errorf("GetByID of a restored order: %v", err)
+    }
+    if _, err := r.orders.GetByID(ctx, cancelled.ID); !errors.Is(err, ErrNotFound) {
+        t.Errorf("GetByID of an order deleted before the user: got %v, want ErrNotFound", err)
+    }
+
+    // Purging frees the email
+    if err := r.users.Delete(ctx, ann.ID); err != nil {
+        t.Fatalf("Delete: %v", err)
+    }
+    ids, err := r.users.PurgeDeleted(ctx, time.Now().UTC().Add(time.Minute))
+    if err != nil {
+        t.Fatalf("PurgeDeleted: %v", err)
+    }
+    if !equalIDs(ids, []uint{ann.ID}) {
+        t.Errorf("PurgeDeleted: got %v, want [%d]", ids, ann.ID)
+    }
+    if _, err := r.users.Restore(ctx, ann.ID); !errors.Is(err, ErrNotFound) {
+        t.Errorf("Restore of a purged user: got %v, want ErrNotFound", err)
+    }
+    createUser(t, ctx, r, ann.Email, 30)
+}
+
+func testCreateBatch(t *testing.T, ctx context.Context, r repos) {
+    createUser(t, ctx, r, "ann@example.com", 30)
+
+    // A conflict anywhere in the batch stores none of it
+    batch := []*models.User{
+        {Name: "Bob", Email: "bob@example.com", Age: 40},
+        {Name: "Ann", Email: "ann@example.com", Age: 31},
+    }
+    if err := r.users.CreateBatch(ctx, batch); !errors.Is(err, ErrDuplicateKey) {
+        t.Fatalf("CreateBatch with a taken email: got %v, want ErrDuplicateKey", err)
+    }
+    if taken, err := r.users.EmailTaken(ctx, "bob@example.com"); err != nil || taken {
+        t.Fatalf("user of a failed batch stored: %v, %v", taken, err)
+    }
+
+    batch = []*models.User{
+        {Name: "Bob", Email: "bob@example.com", Age: 40},
+        {Name: "Cid", Email: "cid@example.com", Age: 50},
+    }
+    if err := r.users.CreateBatch(ctx, batch); err != nil {
+        t.Fatalf("CreateBatch: %v", err)
+    }
+    for _, u := range batch {
+        if got, err := r.users.GetByID(ctx, u.ID); err != nil || got.Email != u.Email {
+            t.Errorf("GetByID(%d) after CreateBatch: got %+v, %v", u.ID, got, err)
+        }
+    }
+
+    orders := []*models.Order{
+        {UserID: batch[0].ID, Product: "book", Quantity: 1, Price: 10},
+        {UserID: batch[0].ID, Product: "pen", Quantity: 2, Price: 1.5},
+    }
+    if err := r.orders.CreateBatch(ctx, orders); err != nil {
+        t.Fatalf("orders.CreateBatch: %v", err)
+    }
+    if orders[0].ID == 0 || orders[0].ID == orders[1].ID {
+        t.Fatalf("orders.CreateBatch assigned IDs %d and %d", orders[0].ID, orders[1].ID)
+    }
+    for _, o := range orders {
+        got, err := r.orders.GetByID(ctx, o.ID)
+        if err != nil || got.Product != o.Product || got.Status != models.OrderStatusPending {
+            t.Errorf("orders.GetByID(%d) after CreateBatch: got %+v, %v", o.ID, got, err)
+        }
+    }
+}
+
+func testTimesInUTC(t *testing.T, ctx context.Context, r repos) {
+    // SQLite compares the stored text, which breaks if the server's zone leaks in
+    local := time.Local
+    time.Local = time.FixedZone("UTC+3", 3*60*60)
+    t.Cleanup(func() { time.Local = local })
+
+    u := createUser(t, ctx, r, "ann@example.com", 30)
+    now := time.Now().UTC()
+    users, _, err := r.users.List(ctx, query.Spec{
+        Filters: []query.Filter{
+            {Field: "created_at", Op: query.Gte, Value: now.Add(-time.Minute)},
+            {Field: "created_at", Op: query.Lte, Value: now.Add(time.Minute)},
+        },
+        Page:  1,
+        Limit: 10,
+    })
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    assertIDs(t, users, []uint{u.ID})
+}
+
+func testRevenueByPeriod(t *testing.T, ctx context.Context, r repos) {
+    u := createUser(t, ctx, r, "ann@example.com", 30)
+    at := func(day, hour int) time.Time { return time.Date(2025, time.March, day, hour, 0, 0, 0, time.UTC) }
+    orders := []*models.Order{
+        // Monday and Wednesday of one week, Sunday closes it
+        {UserID: u.ID, Product: "book", Quantity: 1, Price: 10, CreatedAt: at(3, 9)},
+        {UserID: u.ID, Product: "book", Quantity: 2, Price: 10, CreatedAt: at(5, 23)},
+        {UserID: u.ID, Product: "pen", Quantity: 1, Price: 5, CreatedAt: at(9, 12)},
+        {UserID: u.ID, Product: "pen", Quantity: 1, Price: 5, CreatedAt: at(10, 0)},
+        {UserID: u.ID, Product: "pen", Quantity: 1, Price: 7, CreatedAt: at(31, 23)},
+        {UserID: u.ID, Product: "lamp", Quantity: 1, Price: 100, CreatedAt: at(5, 10), Status: models.OrderStatusCancelled},
+    }
+    if err := r.orders.CreateBatch(ctx, orders); err != nil {
+        t.Fatalf("orders.CreateBatch: %v", err)
+    }
+    day := func(d int) time.Time { return at(d, 0) }
+
+    cases := []struct {
+        period string
+        want   []models.RevenuePoint
+    }{
+        {models.PeriodDay, []models.RevenuePoint{
+            {Period: day(3), Orders: 1, Revenue: 10}, {Period: day(5), Orders: 1, Revenue: 20},
+            {Period: day(9), Orders: 1, Revenue: 5}, {Period: day(10), Orders: 1, Revenue: 5},
+            {Period: day(31), Orders: 1, Revenue: 7},
+        }},
+        {models.PeriodWeek, []models.RevenuePoint{
+            {Period: day(3), Orders: 3, Revenue: 35}, {Period: day(10), Orders: 1, Revenue: 5},
+            {Period: day(31), Orders: 1, Revenue: 7},
+        }},
+        {models.PeriodMonth, []models.RevenuePoint{
+            {Period: day(1), Orders: 5, Revenue: 47},
+        }},
+    }
+    for _, c := range cases {
+        points, err := r.reports.RevenueByPeriod(ctx, c.period, models.ReportRange{})
+        if err != nil {
+            t.Fatalf("RevenueByPeriod(%s): %v", c.period, err)
+        }
+        if !equalPoints(points, c.want) {
+            t.Errorf("RevenueByPeriod(%s): got %+v, want %+v", c.period, points, c.want)
+        }
+    }
+
+    // The range is half-open
+    from, to := day(5), day(10)
+    points, err := r.reports.RevenueByPeriod(ctx, models.PeriodMonth, models.ReportRange{From: &from, To: &to})
+    if err != nil {
+        t.Fatalf("RevenueByPeriod with a range: %v", err)
+    }
+    if want := []models.RevenuePoint{{Period: day(1), Orders: 2, Revenue: 25}}; !equalPoints(points, want) {
+        t.Errorf("RevenueByPeriod with a range: got %+v, want %+v", points, want)
+    }
+}
+
+func testStream(t *testing.T, ctx context.Context, r repos) {
+    var want []uint
+    for i, age := range []int{40, 17, 30, 50} {
+        u := createUser(t, ctx, r, string(rune('a'+i))+"@example.com", age)
+        createOrder(t, ctx, r, u.ID, "book")
+        if age >= 18 {
+            want = append(want, u.ID)
+        }
+    }
+    want = []uint{want[2], want[0], want[1]}
+
+    spec := query.Spec{
+        Filters: []query.Filter{{Field: "age", Op: query.Gte, Value: 18.0}},
+        Sort:    []query.Sort{{Field: "age", Desc: true}},
+        // Paging is ignored
+        Page:  2,
+        Limit: 1,
+    }
+    var got []uint
+    if err := r.users.Stream(ctx, spec, func(u models.User) error {
+        got = append(got, u.ID)
+        return nil
+    }); err != nil {
+        t.Fatalf("Stream: %v", err)
+    }
+    if !equalIDs(got, want) {
+        t.Errorf("Stream: got %v, want %v", got, want)
+    }
+
+    // An error from fn ends the stream
+    stop := errors.New("stop")
+    n := 0
+    err := r.reports.StreamOrders(ctx, query.Spec{Page: 1, Limit: 1}, func(o models.AdminOrder) error {
+        n++
+        if o.UserEmail == "" {
+            t.Errorf("StreamOrders: order %d without its owner", o.ID)
+        }
+        return stop
+    })
+    if !errors.Is(err, stop) || n != 1 {
+        t.Errorf("StreamOrders stopped by fn: got %v after %d orders", err, n)
+    }
+}
+
+func testStreamInTx(t *testing.T, ctx context.Context, r repos) {
+    createUser(t, ctx, r, "ann@example.com", 30)
+
+    err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+        bob := createUser(t, ctx, r, "bob@example.com", 40)
+        var got []uint
+        if err := r.users.Stream(ctx, query.Spec{}, func(u models.User) error {
+            got = append(got, u.ID)
+            return nil
+        }); err != nil {
+            return err
+        }
+        // The stream sees the transaction's own writes, and the transaction
+        // goes on after it
+        if len(got) != 2 || got[1] != bob.ID {
+            t.Errorf("Stream in a transaction: got %v, want 2 users ending with %d", got, bob.ID)
+        }
+        _, err := r.users.GetByID(ctx, bob.ID)
+        return err
+    })
+    if err != nil {
+        t.Fatalf("WithinTx: %v", err)
+    }
+}
+
+func createUser(t *testing.T, ctx context.Context, r repos, email string, age int) *models.User {
+    t.Helper()
+    u := &models.User{Name: "User " + email, Email: email, Age: age}
+    if err := r.users.Create(ctx, u); err != nil {
+        t.Fatalf("Create %s: %v", email, err)
+    }
+    return u
+}
+
+func createOrder(t *testing.T, ctx context.Context, r repos, userID uint, product string) *models.Order {
+    t.Helper()
+    o := &models.Order{UserID: userID, Product: product, Quantity: 1, Price: 10}
+    if err := r.orders.Create(ctx, o); err != nil {
+        t.Fatalf("orders.Create: %v", err)
+    }
+    return o
+}
+
+func assertIDs(t *testing.T, users []models.User, want []uint) {
+    t.Helper()
+    got := make([]uint, len(users))
+    for i, u := range users {
+        got[i] = u.ID
+    }
+    if !equalIDs(got, want) {
+        t.Errorf("got users %v, want %v", got, want)
+    }
+}
+
+func equalIDs(a, b []uint) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func equalPoints(a, b []models.RevenuePoint) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if !a[i].Period.Equal(b[i].Period) || a[i].Orders != b[i].Orders || a[i].Revenue != b[i].Revenue {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "sync/atomic"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+var (
+    // ErrCanceled is returned when the caller's context was cancelled
+    // (typically the client went away) while a query was running.
+    ErrCanceled = errors.New("database operation canceled")
+    // ErrTimeout is returned when a query ran past its deadline.
+    ErrTimeout = errors.New("database operation timed out")
+)
+
+// Timeouts are the default deadlines of repository operations. They apply
+// when the caller's context has no earlier deadline; zero disables them.
+type Timeouts struct {
+    Read  time.Duration
+    Write time.Duration
+}
+
+// Dialect names as reported by the GORM dialectors.
+const (
+    dialectPostgres = "postgres"
+    dialectSQLite   = "sqlite"
+)
+
+// DB is the database handle shared by the GORM repositories: the primary,
+// which takes all writes, and optional read replicas, see readConn.
+type DB struct {
+    root     *gorm.DB
+    replicas []*replica
+    // next rotates reads over the replicas
+    next     atomic.Uint64
+    timeouts Timeouts
+}
+
+// NewDB wraps an open GORM connection to the primary.
+func NewDB(db *gorm.DB, timeouts Timeouts) *DB {
+    return &DB{root: db, timeouts: timeouts}
+}
+
+// AddReplica registers an open GORM connection to a read replica of the
+// primary; name identifies it in logs. Replicas must be added before d is
+// used, and serve reads once MonitorReplicas has found them healthy.
+func (d *DB) AddReplica(name string, db *gorm.DB) {
+    d.replicas = append(d.replicas, &replica{db: db, name: name})
+}
+
+// dialect returns the name of the SQL dialect of the database.
+func (d *DB) dialect() string {
+    return d.root.Dialector.Name()
+}
+
+// read starts a read operation: it applies the read timeout to ctx and returns
+// a function to defer with the operation's error, which releases the timeout
+// and turns context errors into ErrCanceled or ErrTimeout.
+func (d *DB) read(ctx context.Context) (context.Context, func(*error)) {
+    return d.operation(ctx, d.timeouts.Read)
+}
+
+// write is read with the write timeout.
+func (d *DB) write(ctx context.Context) (context.Context, func(*error)) {
+    return d.operation(ctx, d.timeouts.Write)
+}
+
+func (d *DB) operation(ctx context.Context, timeout time.Duration) (context.Context, func(*error)) {
+    cancel := context.CancelFunc(func() {})
+    if timeout > 0 {
+        ctx, cancel = context.WithTimeout(ctx, timeout)
+    }
+    return ctx, func(err *error) {
+        *err = contextError(ctx, *err)
+        cancel()
+    }
+}
+
+// contextError reports err as ErrCanceled or ErrTimeout if ctx ended; the
+// driver's own error (e.g. "canceling statement due to user request") is kept
+// in the message.
+func contextError(ctx context.Context, err error) error {
+    if err == nil || ctx.Err() == nil || errors.Is(err, ErrCanceled) || errors.Is(err, ErrTimeout) {
+        return err
+    }
+    kind := ErrCanceled
+    if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+        kind = ErrTimeout
+    }
+    return &ctxError{kind: kind, ctxErr: ctx.Err(), cause: err}
+}
+
+type ctxError struct {
+    kind   error
+    ctxErr error
+    cause  error
+}
+
+func (e *ctxError) Error() string   { return e.kind.Error() + ": " + e.cause.Error() }
+func (e *ctxError) Unwrap() []error { return []error{e.kind, e.ctxErr} }
+
+// conn returns a *gorm.DB whose statements run with ctx: the transaction
+// carried by ctx if there is one, the connection pool otherwise. Cancelling
+// ctx makes the driver cancel the running statement on the server.
+func (d *DB) conn(ctx context.Context) *gorm.DB {
+    if state, ok := txFromContext(ctx); ok {
+        return state.tx.WithContext(ctx)
+    }
+    return d.root.WithContext(ctx)
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+// slowQueries run for seconds unless they are cancelled.
+var slowQueries = map[string]string{
+    dialectPostgres: "SELECT pg_sleep(5)",
+    dialectSQLite: "WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 1000000000) " +
+        "SELECT count(*) FROM n",
+}
+
+// slowRead runs the slow query of the dialect as a read operation of d.
+func slowRead(ctx context.Context, d *DB) (err error) {
+    ctx, done := d.read(ctx)
+    defer done(&err)
+
+    var n int64
+    return d.readConn(ctx).Raw(slowQueries[d.dialect()]).Scan(&n).Error
+}
+
+// TestReadAborted checks that an ended context stops the running statement
+// and is reported as ErrCanceled or ErrTimeout.
+func TestReadAborted(t *testing.T) {
+    const abortAfter = 50 * time.Millisecond
+
+    for _, b := range sqlBackends() {
+        t.Run(b.name, func(t *testing.T) {
+            root := b.open(t).db.root
+
+            t.Run("canceled", func(t *testing.T) {
+                ctx, cancel := context.WithCancel(context.Background())
+                defer cancel()
+                time.AfterFunc(abortAfter, cancel)
+
+                err := assertAborted(t, ctx, NewDB(root, Timeouts{}))
+                if !errors.Is(err, ErrCanceled) || errors.Is(err, ErrTimeout) {
+                    t.Errorf("got %v, want ErrCanceled", err)
+                }
+            })
+
+            t.Run("read timeout", func(t *testing.T) {
+                err := assertAborted(t, context.Background(), NewDB(root, Timeouts{Read: abortAfter}))
+                if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+                    t.Errorf("got %v, want ErrTimeout", err)
+                }
+            })
+        })
+    }
+}
+
+// assertAborted runs the slow read and checks that it returned soon.
+func assertAborted(t *testing.T, ctx context.Context, d *DB) error {
+    t.Helper()
+    start := time.Now()
+    err := slowRead(ctx, d)
+    if elapsed := time.Since(start); elapsed > 2*time.Second {
+        t.Errorf("query ran for %v after the context ended", elapsed)
+    }
+    return err
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+    "errors"
+
+    "gorm.io/gorm"
+)
+
+// ErrStaleVersion is returned when an optimistic update finds that the row
+// was changed by someone else since it was read.
+var ErrStaleVersion = errors.New("stale version")
+
+// ErrNotFound is returned when the requested record does not exist.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrDuplicateKey is returned when a write violates a unique constraint,
+// such as a second user with the same email.
+var ErrDuplicateKey = gorm.ErrDuplicatedKey
@@ -0,0 +1,53 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "gorm.io/gorm"
+)
+
+// Ping checks that the primary database answers within the read timeout.
+func (d *DB) Ping(ctx context.Context) error {
+    return d.ping(ctx, d.root)
+}
+
+// CheckSchema reports an error if the table of one of models, or a column of
+// it, is missing from the primary: the migrations did not run or were undone.
+func (d *DB) CheckSchema(ctx context.Context, models ...interface{}) (err error) {
+    ctx, done := d.read(ctx)
+    defer done(&err)
+
+    db := d.root.WithContext(ctx)
+    for _, model := range models {
+        stmt := &gorm.Statement{DB: db}
+        if err := stmt.Parse(model); err != nil {
+            return err
+        }
+        table := stmt.Schema.Table
+
+        if !db.Migrator().HasTable(table) {
+            return fmt.Errorf("table %s is missing", table)
+        }
+        columns, err := db.Migrator().ColumnTypes(table)
+        if err != nil {
+            return fmt.Errorf("failed to read the columns of %s: %w", table, err)
+        }
+        have := make(map[string]bool, len(columns))
+        for _, c := range columns {
+            have[c.Name()] = true
+        }
+
+        var missing []string
+        for _, f := range stmt.Schema.Fields {
+            if f.DBName != "" && !f.IgnoreMigration && !have[f.DBName] {
+                missing = append(missing, f.DBName)
+            }
+        }
+        if len(missing) > 0 {
+            return fmt.Errorf("table %s lacks columns %s", table, strings.Join(missing, ", "))
+        }
+    }
+    return nil
+}
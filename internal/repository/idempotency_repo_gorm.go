@@ -0,0 +1,103 @@
+package repository
+
+import (
+    "context"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// IdempotencyRepository stores idempotency keys.
+type IdempotencyRepository interface {
+    // Acquire inserts key as in progress. It reports false if a live record
+    // with the same user and key already exists. An expired record, or an
+    // in-progress one not touched since staleBefore, is taken over.
+    Acquire(ctx context.Context, key *models.IdempotencyKey, staleBefore time.Time) (bool, error)
+    Get(ctx context.Context, userID uint, key string) (*models.IdempotencyKey, error)
+    Complete(ctx context.Context, key *models.IdempotencyKey) error
+    Release(ctx context.Context, userID uint, key string) error
+    DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type gormIdempotencyRepo struct {
+    db *DB
+}
+
+// NewGormIdempotencyRepo creates a GORM implementation.
+func NewGormIdempotencyRepo(db *DB) IdempotencyRepository {
+    return &gormIdempotencyRepo{db: db}
+}
+
+func (r *gormIdempotencyRepo) Acquire(ctx context.Context, key *models.IdempotencyKey, staleBefore time.Time) (_ bool, err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    // The primary key makes concurrent requests with the same key race on this insert
+    res := r.db.conn(ctx).Exec("INSERT INTO idempotency_keys "+
+        "(user_id, key, request_hash, status, created_at, updated_at, expires_at) "+
+        "VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+        key.UserID, key.Key, key.RequestHash, models.IdempotencyInProgress,
+        key.CreatedAt, key.CreatedAt, key.ExpiresAt)
+    if res.Error != nil {
+        return false, res.Error
+    }
+    if res.RowsAffected == 1 {
+        return true, nil
+    }
+
+    res = r.db.conn(ctx).Model(&models.IdempotencyKey{}).
+        Where("user_id = ? AND key = ?", key.UserID, key.Key).
+        Where("expires_at < ? OR (status = ? AND updated_at < ?)",
+            key.CreatedAt, models.IdempotencyInProgress, staleBefore).
+        Updates(map[string]interface{}{
+            "request_hash":  key.RequestHash,
+            "status":        models.IdempotencyInProgress,
+            "response_code": 0,
+            "response_body": "",
+            "created_at":    key.CreatedAt,
+            "updated_at":    key.CreatedAt,
+            "expires_at":    key.ExpiresAt,
+        })
+    return res.RowsAffected == 1, res.Error
+}
+
+func (r *gormIdempotencyRepo) Get(ctx context.Context, userID uint, key string) (_ *models.IdempotencyKey, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var rec models.IdempotencyKey
+    if err := r.db.conn(ctx).Where("user_id = ? AND key = ?", userID, key).First(&rec).Error; err != nil {
+        return nil, err
+    }
+    return &rec, nil
+}
+
+func (r *gormIdempotencyRepo) Complete(ctx context.Context, key *models.IdempotencyKey) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return r.db.conn(ctx).Model(&models.IdempotencyKey{}).
+        Where("user_id = ? AND key = ?", key.UserID, key.Key).
+        Updates(map[string]interface{}{
+            "status":        models.IdempotencyCompleted,
+            "response_code": key.ResponseCode,
+            "response_body": key.ResponseBody,
+            "updated_at":    time.Now().UTC(),
+        }).Error
+}
+
+func (r *gormIdempotencyRepo) Release(ctx context.Context, userID uint, key string) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return r.db.conn(ctx).Where("user_id = ? AND key = ? AND status = ?", userID, key, models.IdempotencyInProgress).
+        Delete(&models.IdempotencyKey{}).Error
+}
+
+func (r *gormIdempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (_ int64, err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    res := r.db.conn(ctx).Where("expires_at < ?", now).Delete(&models.IdempotencyKey{})
+    return res.RowsAffected, res.Error
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+    "context"
+    "time"
+
+    "gorm.io/gorm"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+type memoryIdempotencyRepo struct {
+    s *MemoryStore
+}
+
+// NewMemoryIdempotencyRepo creates an in-memory implementation.
+func NewMemoryIdempotencyRepo(s *MemoryStore) IdempotencyRepository {
+    return &memoryIdempotencyRepo{s: s}
+}
+
+func (r *memoryIdempotencyRepo) Acquire(ctx context.Context, key *models.IdempotencyKey, staleBefore time.Time) (bool, error) {
+    acquired := false
+    err := r.s.update(ctx, func(undo func(func())) error {
+        id := idempotencyID{userID: key.UserID, key: key.Key}
+        old, exists := r.s.keys[id]
+        if exists && !old.ExpiresAt.Before(key.CreatedAt) &&
+            !(old.Status == models.IdempotencyInProgress && old.UpdatedAt.Before(staleBefore)) {
+            return nil
+        }
+
+        rec := *key
+        rec.Status = models.IdempotencyInProgress
+        rec.ResponseCode = 0
+        rec.ResponseBody = ""
+        rec.UpdatedAt = key.CreatedAt
+        r.s.keys[id] = rec
+        undo(func() {
+            if exists {
+                r.s.keys[id] = old
+            } else {
+                delete(r.s.keys, id)
+            }
+        })
+        acquired = true
+        return nil
+    })
+    return acquired, err
+}
+
+func (r *memoryIdempotencyRepo) Get(ctx context.Context, userID uint, key string) (*models.IdempotencyKey, error) {
+    var rec models.IdempotencyKey
+    err := r.s.view(ctx, func() error {
+        k, ok := r.s.keys[idempotencyID{userID: userID, key: key}]
+        if !ok {
+            return gorm.ErrRecordNotFound
+        }
+        rec = k
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &rec, nil
+}
+
+func (r *memoryIdempotencyRepo) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        id := idempotencyID{userID: key.UserID, key: key.Key}
+        old, ok := r.s.keys[id]
+        if !ok {
+            return nil
+        }
+        rec := old
+        rec.Status = models.IdempotencyCompleted
+        rec.ResponseCode = key.ResponseCode
+        rec.ResponseBody = key.ResponseBody
+        rec.UpdatedAt = time.Now().UTC()
+        r.s.keys[id] = rec
+        undo(func() { r.s.keys[id] = old })
+        return nil
+    })
+}
+
+func (r *memoryIdempotencyRepo) Release(ctx context.Context, userID uint, key string) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        id := idempotencyID{userID: userID, key: key}
+        if old, ok := r.s.keys[id]; ok && old.Status == models.IdempotencyInProgress {
+            delete(r.s.keys, id)
+            undo(func() { r.s.keys[id] = old })
+        }
+        return nil
+    })
+}
+
+func (r *memoryIdempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+    var n int64
+    err := r.s.update(ctx, func(undo func(func())) error {
+        for id, rec := range r.s.keys {
+            if rec.ExpiresAt.Before(now) {
+                delete(r.s.keys, id)
+                undo(func() { r.s.keys[id] = rec })
+                n++
+            }
+        }
+        return nil
+    })
+    return n, err
+}
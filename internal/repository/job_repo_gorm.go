@@ -0,0 +1,75 @@
+package repository
+
+import (
+    "context"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// JobRepository defines DB operations for background jobs.
+type JobRepository interface {
+    Create(ctx context.Context, job *models.Job) error
+    GetByID(ctx context.Context, id string) (*models.Job, error)
+    Update(ctx context.Context, job *models.Job) error
+    // List returns the jobs matching the filter, oldest first.
+    List(ctx context.Context, f models.JobFilter) ([]models.Job, error)
+}
+
+type gormJobRepo struct {
+    db *DB
+}
+
+// NewGormJobRepo creates a GORM implementation.
+func NewGormJobRepo(db *DB) JobRepository {
+    return &gormJobRepo{db: db}
+}
+
+func (r *gormJobRepo) Create(ctx context.Context, job *models.Job) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return r.db.conn(ctx).Create(job).Error
+}
+
+func (r *gormJobRepo) GetByID(ctx context.Context, id string) (_ *models.Job, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var job models.Job
+    if err := r.db.conn(ctx).Where("id = ?", id).First(&job).Error; err != nil {
+        return nil, err
+    }
+    return &job, nil
+}
+
+func (r *gormJobRepo) Update(ctx context.Context, job *models.Job) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return r.db.conn(ctx).Save(job).Error
+}
+
+func (r *gormJobRepo) List(ctx context.Context, f models.JobFilter) (_ []models.Job, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    q := r.db.conn(ctx).Model(&models.Job{})
+    if f.Type != "" {
+        q = q.Where("type = ?", f.Type)
+    }
+    if f.SubjectID > 0 {
+        q = q.Where("subject_id = ?", f.SubjectID)
+    }
+    if f.FinishedBefore != nil {
+        q = q.Where("finished_at < ?", *f.FinishedBefore)
+    }
+    if f.HasResult {
+        q = q.Where("result_path <> ''")
+    }
+
+    var jobs []models.Job
+    if err := q.Order("created_at, id").Find(&jobs).Error; err != nil {
+        return nil, err
+    }
+    return jobs, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+    "context"
+    "sort"
+    "time"
+
+    "gorm.io/gorm"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+type memoryJobRepo struct {
+    s *MemoryStore
+}
+
+// NewMemoryJobRepo creates an in-memory implementation.
+func NewMemoryJobRepo(s *MemoryStore) JobRepository {
+    return &memoryJobRepo{s: s}
+}
+
+func (r *memoryJobRepo) Create(ctx context.Context, job *models.Job) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        if _, ok := r.s.jobs[job.ID]; ok {
+            return ErrDuplicateKey
+        }
+        now := time.Now()
+        if job.CreatedAt.IsZero() {
+            job.CreatedAt = now
+        }
+        if job.UpdatedAt.IsZero() {
+            job.UpdatedAt = now
+        }
+        r.s.jobs[job.ID] = *job
+        undo(func() { delete(r.s.jobs, job.ID) })
+        return nil
+    })
+}
+
+func (r *memoryJobRepo) GetByID(ctx context.Context, id string) (*models.Job, error) {
+    var job models.Job
+    err := r.s.view(ctx, func() error {
+        j, ok := r.s.jobs[id]
+        if !ok {
+            return gorm.ErrRecordNotFound
+        }
+        job = j
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &job, nil
+}
+
+// Update saves all fields of the job, inserting it if it does not exist.
+func (r *memoryJobRepo) Update(ctx context.Context, job *models.Job) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        old, existed := r.s.jobs[job.ID]
+        job.UpdatedAt = time.Now()
+        r.s.jobs[job.ID] = *job
+        undo(func() {
+            if existed {
+                r.s.jobs[old.ID] = old
+            } else {
+                delete(r.s.jobs, old.ID)
+            }
+        })
+        return nil
+    })
+}
+
+func (r *memoryJobRepo) List(ctx context.Context, f models.JobFilter) ([]models.Job, error) {
+    var jobs []models.Job
+    err := r.s.view(ctx, func() error {
+        for _, j := range r.s.jobs {
+            if jobMatches(j, f) {
+                jobs = append(jobs, j)
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    sort.Slice(jobs, func(i, k int) bool {
+        if !jobs[i].CreatedAt.Equal(jobs[k].CreatedAt) {
+            return jobs[i].CreatedAt.Before(jobs[k].CreatedAt)
+        }
+        return jobs[i].ID < jobs[k].ID
+    })
+    return jobs, nil
+}
+
+func jobMatches(j models.Job, f models.JobFilter) bool {
+    switch {
+    case f.Type != "" && j.Type != f.Type:
+        return false
+    case f.SubjectID > 0 && j.SubjectID != f.SubjectID:
+        return false
+    case f.FinishedBefore != nil && (j.FinishedAt == nil || !j.FinishedAt.Before(*f.FinishedBefore)):
+        return false
+    case f.HasResult && j.ResultPath == "":
+        return false
+    }
+    return true
+}
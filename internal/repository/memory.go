@@ -0,0 +1,178 @@
+package repository
+
+import (
+    "context"
+    "sync"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// MemoryStore holds the tables of the in-memory repositories. They keep the
+// semantics of the GORM repositories (soft deletes, unique emails, not-found
+// errors, paging) without a database, for tests and for running the server
+// locally. Nothing is persisted.
+type MemoryStore struct {
+    mu sync.RWMutex
+    // txMu serialises transactions, see memoryTxManager.
+    txMu sync.Mutex
+
+    users  map[uint]models.User
+    orders map[uint]models.Order
+    audit  []models.AuditEntry
+    jobs   map[string]models.Job
+    keys   map[idempotencyID]models.IdempotencyKey
+
+    lastUserID  uint
+    lastOrderID uint
+}
+
+type idempotencyID struct {
+    userID uint
+    key    string
+}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{
+        users:  make(map[uint]models.User),
+        orders: make(map[uint]models.Order),
+        jobs:   make(map[string]models.Job),
+        keys:   make(map[idempotencyID]models.IdempotencyKey),
+    }
+}
+
+// view runs fn with the store locked for reading.
+func (s *MemoryStore) view(ctx context.Context, fn func() error) error {
+    if err := ctx.Err(); err != nil {
+        return contextError(ctx, err)
+    }
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return fn()
+}
+
+// update runs fn with the store locked for writing. fn registers how to undo
+// each change it makes: if fn fails its changes are undone at once, otherwise
+// they are kept for a rollback of the transaction carried by ctx, if any.
+func (s *MemoryStore) update(ctx context.Context, fn func(undo func(step func())) error) error {
+    if err := ctx.Err(); err != nil {
+        return contextError(ctx, err)
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var steps []func()
+    if err := fn(func(step func()) { steps = append(steps, step) }); err != nil {
+        undoAll(steps)
+        return err
+    }
+    if tx := memoryTxFromContext(ctx); tx != nil {
+        tx.undo = append(tx.undo, steps...)
+    }
+    return nil
+}
+
+func undoAll(steps []func()) {
+    for i := len(steps) - 1; i >= 0; i-- {
+        steps[i]()
+    }
+}
+
+type memoryTxKey struct{}
+
+// memoryTx collects the undo steps of the writes made in a transaction.
+type memoryTx struct {
+    undo  []func()
+    hooks *commitHooks
+}
+
+func memoryTxFromContext(ctx context.Context) *memoryTx {
+    tx, _ := ctx.Value(memoryTxKey{}).(*memoryTx)
+    return tx
+}
+
+type memoryTxManager struct {
+    s *MemoryStore
+}
+
+// NewMemoryTxManager creates a TxManager for the in-memory repositories.
+// Transactions are serialised and rolled back by undoing their writes; other
+// callers may see their changes before they commit.
+func NewMemoryTxManager(s *MemoryStore) TxManager {
+    return &memoryTxManager{s: s}
+}
+
+func (m *memoryTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+    defer func() { err = contextError(ctx, err) }()
+
+    outer := memoryTxFromContext(ctx)
+    if outer == nil {
+        m.s.txMu.Lock()
+        defer m.s.txMu.Unlock()
+    }
+
+    // A nested call behaves like a savepoint: on failure only its own
+    // writes are undone, on success they join the outer transaction
+    tx := &memoryTx{hooks: &commitHooks{}}
+    if outer != nil {
+        tx.hooks = outer.hooks
+    }
+    ctx = context.WithValue(ctx, memoryTxKey{}, tx)
+    defer func() {
+        if p := recover(); p != nil {
+            m.rollback(tx)
+            panic(p)
+        }
+    }()
+
+    if err := fn(ctx); err != nil {
+        m.rollback(tx)
+        return err
+    }
+    if outer != nil {
+        outer.undo = append(outer.undo, tx.undo...)
+        return nil
+    }
+    tx.hooks.run()
+    return nil
+}
+
+func (m *memoryTxManager) rollback(tx *memoryTx) {
+    m.s.mu.Lock()
+    defer m.s.mu.Unlock()
+    undoAll(tx.undo)
+    tx.undo = nil
+}
+
+// memoryPage returns one page of rows, see gormUserRepo.List.
+func memoryPage[T any](rows []T, spec query.Spec, schema query.Schema) ([]T, query.PageInfo, error) {
+    var info query.PageInfo
+
+    total := 0
+    if !spec.SkipCount {
+        matched, err := query.FilterSlice(rows, spec, schema)
+        if err != nil {
+            return nil, info, err
+        }
+        total = len(matched)
+    }
+
+    rows, err := query.ApplySlice(rows, spec, schema)
+    if err != nil {
+        return nil, info, err
+    }
+    if spec.Cursor == nil {
+        offset := min(max((spec.Page-1)*spec.Limit, 0), len(rows))
+        rows = rows[offset:]
+    }
+    if len(rows) > spec.Limit+1 {
+        rows = rows[:spec.Limit+1]
+    }
+
+    rows, info = query.Window(rows, spec)
+    if !spec.SkipCount {
+        info.Total = &total
+    }
+    return rows, info, nil
+}
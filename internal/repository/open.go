@@ -0,0 +1,62 @@
+package repository
+
+import (
+    "time"
+
+    "gorm.io/gorm"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// Open connects GORM through dialector the way the repositories expect. A
+// replica is not pinged: if it is down it serves no reads until it comes up,
+// see DB.MonitorReplicas.
+func Open(dialector gorm.Dialector, replica bool) (*gorm.DB, error) {
+    cfg := &gorm.Config{
+        // Single-statement writes are atomic anyway; multi-statement work
+        // runs in explicit transactions
+        SkipDefaultTransaction: true,
+        // Report unique violations as gorm.ErrDuplicatedKey (ErrDuplicateKey)
+        TranslateError:       true,
+        DisableAutomaticPing: replica,
+    }
+    if dialector.Name() == dialectSQLite {
+        // SQLite compares timestamps as text, which only works in a single time zone
+        cfg.NowFunc = func() time.Time { return time.Now().UTC() }
+    }
+    return gorm.Open(dialector, cfg)
+}
+
+// auditGuards make audit_logs append-only, as migration 0003 does on
+// Postgres. SQLite has no rules, so triggers reject the change instead.
+var auditGuards = map[string][]string{
+    dialectPostgres: {
+        "CREATE OR REPLACE RULE audit_logs_no_update AS ON UPDATE TO audit_logs DO INSTEAD NOTHING",
+        "CREATE OR REPLACE RULE audit_logs_no_delete AS ON DELETE TO audit_logs DO INSTEAD NOTHING",
+    },
+    dialectSQLite: {
+        "CREATE TRIGGER IF NOT EXISTS audit_logs_no_update BEFORE UPDATE ON audit_logs " +
+            "BEGIN SELECT RAISE(ABORT, 'audit_logs is append-only'); END",
+        "CREATE TRIGGER IF NOT EXISTS audit_logs_no_delete BEFORE DELETE ON audit_logs " +
+            "BEGIN SELECT RAISE(ABORT, 'audit_logs is append-only'); END",
+    },
+}
+
+// Migrate creates or updates the tables of schema with AutoMigrate, then adds
+// what AutoMigrate cannot: the guards keeping audit_logs append-only. The SQL
+// migrations are written for Postgres; a SQLite database only gets its
+// schema from here.
+func Migrate(db *gorm.DB, schema ...interface{}) error {
+    if err := db.AutoMigrate(schema...); err != nil {
+        return err
+    }
+    if !db.Migrator().HasTable(&models.AuditEntry{}) {
+        return nil
+    }
+    for _, stmt := range auditGuards[db.Dialector.Name()] {
+        if err := db.Exec(stmt).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// TestAuditLogAppendOnly checks that Migrate guards audit_logs on every
+// database, not only where the SQL migrations ran.
+func TestAuditLogAppendOnly(t *testing.T) {
+    for _, b := range sqlBackends() {
+        t.Run(b.name, func(t *testing.T) {
+            r := b.open(t)
+            ctx := context.Background()
+
+            entry := &models.AuditEntry{
+                Action:       models.AuditActionCreate,
+                ResourceType: models.AuditResourceUser,
+                ResourceID:   1,
+                Diff:         `{"role":{"from":null,"to":"user"}}`,
+                CreatedAt:    time.Now().UTC().Truncate(time.Microsecond),
+            }
+            if err := r.audit.Append(ctx, entry); err != nil {
+                t.Fatalf("Append: %v", err)
+            }
+
+            // Postgres rules drop the change silently, SQLite triggers fail it
+            root := r.db.root
+            res := root.Exec("UPDATE audit_logs SET diff = ? WHERE id = ?", "{}", entry.ID)
+            if res.Error == nil && res.RowsAffected != 0 {
+                t.Errorf("UPDATE changed %d audit entries", res.RowsAffected)
+            }
+            res = root.Exec("DELETE FROM audit_logs WHERE id = ?", entry.ID)
+            if res.Error == nil && res.RowsAffected != 0 {
+                t.Errorf("DELETE removed %d audit entries", res.RowsAffected)
+            }
+
+            entries, err := r.audit.ListAfter(ctx, 0, 10)
+            if err != nil {
+                t.Fatalf("ListAfter: %v", err)
+            }
+            if len(entries) != 1 || entries[0].Diff != entry.Diff || entries[0].Hash != entries[0].ComputeHash() {
+                t.Errorf("audit log was rewritten: %+v", entries)
+            }
+
+            // Migrating again keeps the guards in place
+            if err := Migrate(root, &models.AuditEntry{}); err != nil {
+                t.Fatalf("Migrate again: %v", err)
+            }
+        })
+    }
+}
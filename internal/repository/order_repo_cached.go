@@ -0,0 +1,128 @@
+package repository
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "slices"
+    "strings"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/cache"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// cachedOrderRepo serves the order listings of a user from a cache in front
+// of another OrderRepository, keyed by the user's generation like
+// cachedUserRepo. Any write to an order of the user drops them.
+type cachedOrderRepo struct {
+    OrderRepository
+    cache *cache.Cache
+}
+
+// NewCachedOrderRepo wraps next with a cache-aside layer for ListByUser and
+// SummarizeByUser.
+func NewCachedOrderRepo(next OrderRepository, c *cache.Cache) OrderRepository {
+    return &cachedOrderRepo{OrderRepository: next, cache: c}
+}
+
+// orderPage is a cached result of ListByUser.
+type orderPage struct {
+    Orders []models.Order
+    Info   query.PageInfo
+}
+
+func (r *cachedOrderRepo) ListByUser(ctx context.Context, userID uint, spec query.Spec) ([]models.Order, query.PageInfo, error) {
+    if !cacheable(ctx) {
+        return r.OrderRepository.ListByUser(ctx, userID, spec)
+    }
+    key := fmt.Sprintf("%s:orders:%s", userGeneration(ctx, r.cache, userID), specKey(spec))
+    page, err := cache.Fetch(ctx, r.cache, key, func(ctx context.Context) (orderPage, error) {
+        orders, info, err := r.OrderRepository.ListByUser(ctx, userID, spec)
+        return orderPage{Orders: orders, Info: info}, err
+    })
+    if err != nil {
+        return nil, query.PageInfo{}, err
+    }
+    if page.Orders == nil {
+        // An empty page is encoded as nil; lists are rendered as []
+        page.Orders = []models.Order{}
+    }
+    return page.Orders, page.Info, nil
+}
+
+func (r *cachedOrderRepo) SummarizeByUser(ctx context.Context, userID uint, spec query.Spec) (models.OrderSummary, error) {
+    if !cacheable(ctx) {
+        return r.OrderRepository.SummarizeByUser(ctx, userID, spec)
+    }
+    key := fmt.Sprintf("%s:summary:%s", userGeneration(ctx, r.cache, userID), specKey(spec))
+    return cache.Fetch(ctx, r.cache, key, func(ctx context.Context) (models.OrderSummary, error) {
+        return r.OrderRepository.SummarizeByUser(ctx, userID, spec)
+    })
+}
+
+func (r *cachedOrderRepo) Create(ctx context.Context, order *models.Order) error {
+    if err := r.OrderRepository.Create(ctx, order); err != nil {
+        return err
+    }
+    invalidateUsers(ctx, r.cache, order.UserID)
+    return nil
+}
+
+func (r *cachedOrderRepo) CreateBatch(ctx context.Context, orders []*models.Order) error {
+    if err := r.OrderRepository.CreateBatch(ctx, orders); err != nil {
+        return err
+    }
+    var ids []uint
+    for _, o := range orders {
+        if !slices.Contains(ids, o.UserID) {
+            ids = append(ids, o.UserID)
+        }
+    }
+    invalidateUsers(ctx, r.cache, ids...)
+    return nil
+}
+
+func (r *cachedOrderRepo) Update(ctx context.Context, order *models.Order, expectedStatus string) error {
+    if err := r.OrderRepository.Update(ctx, order, expectedStatus); err != nil {
+        return err
+    }
+    invalidateUsers(ctx, r.cache, order.UserID)
+    return nil
+}
+
+// Delete looks the order up first to learn whose listings to drop.
+func (r *cachedOrderRepo) Delete(ctx context.Context, id uint) error {
+    order, err := r.OrderRepository.GetByID(PinPrimary(ctx), id)
+    if err != nil {
+        // Nothing to delete; the wrapped repository decides what to report
+        return r.OrderRepository.Delete(ctx, id)
+    }
+    if err := r.OrderRepository.Delete(ctx, id); err != nil {
+        return err
+    }
+    invalidateUsers(ctx, r.cache, order.UserID)
+    return nil
+}
+
+// specKey identifies the result of spec. Filters come in no particular order
+// from the query string, so they are sorted first.
+func specKey(spec query.Spec) string {
+    spec.Filters = slices.Clone(spec.Filters)
+    slices.SortFunc(spec.Filters, func(a, b query.Filter) int {
+        if c := strings.Compare(a.Field, b.Field); c != 0 {
+            return c
+        }
+        return strings.Compare(string(a.Op), string(b.Op))
+    })
+    // Cursor.Before is not part of its JSON form
+    data, _ := json.Marshal(struct {
+        Spec   query.Spec
+        Before bool
+    }{spec, spec.Cursor != nil && spec.Cursor.Before})
+
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:16])
+}
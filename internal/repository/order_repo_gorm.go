@@ -3,34 +3,139 @@ package repository
 import (
     "context"
 
-    "github.com/jinzhu/gorm"
+    "gorm.io/gorm"
     "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
 )
 
 // OrderRepository defines DB operations for orders.
 type OrderRepository interface {
     Create(ctx context.Context, order *models.Order) error
-    ListByUser(ctx context.Context, userID uint) ([]models.Order, error)
+    CreateBatch(ctx context.Context, orders []*models.Order) error
+    ListByUser(ctx context.Context, userID uint, spec query.Spec) ([]models.Order, query.PageInfo, error)
+    SummarizeByUser(ctx context.Context, userID uint, spec query.Spec) (models.OrderSummary, error)
+    GetByID(ctx context.Context, id uint) (*models.Order, error)
+    Update(ctx context.Context, order *models.Order, expectedStatus string) error
+    Delete(ctx context.Context, id uint) error
 }
 
 type gormOrderRepo struct {
-    db *gorm.DB
+    db *DB
 }
 
 // NewGormOrderRepo creates a GORM implementation.
-func NewGormOrderRepo(db *gorm.DB) OrderRepository {
+func NewGormOrderRepo(db *DB) OrderRepository {
     return &gormOrderRepo{db: db}
 }
 
-func (r *gormOrderRepo) Create(ctx context.Context, order *models.Order) error {
-    return r.db.Create(order).Error
+func (r *gormOrderRepo) Create(ctx context.Context, order *models.Order) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return r.db.conn(ctx).Create(order).Error
+}
+
+// CreateBatch inserts all orders with a single multi-row INSERT, so either all
+// of them are stored or none. IDs are filled in from RETURNING.
+func (r *gormOrderRepo) CreateBatch(ctx context.Context, orders []*models.Order) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    if len(orders) == 0 {
+        return nil
+    }
+    return r.db.conn(ctx).Create(&orders).Error
 }
 
-func (r *gormOrderRepo) ListByUser(ctx context.Context, userID uint) ([]models.Order, error) {
+// ListByUser returns one page of the user's orders, see gormUserRepo.List.
+func (r *gormOrderRepo) ListByUser(ctx context.Context, userID uint, spec query.Spec) (_ []models.Order, _ query.PageInfo, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
     var orders []models.Order
-    if err := r.db.Where("user_id = ?", userID).Find(&orders).Error; err != nil {
+    var info query.PageInfo
+
+    // Session lets q be reused for both the count and the page
+    q := r.db.readConn(ctx).Model(&models.Order{}).Where("user_id = ?", userID).Session(&gorm.Session{})
+
+    var total int64
+    if !spec.SkipCount {
+        if err := query.ApplyFilters(q, spec, models.OrderQuerySchema).Count(&total).Error; err != nil {
+            return nil, info, err
+        }
+    }
+
+    q = query.Apply(q, spec, models.OrderQuerySchema)
+    if spec.Cursor == nil {
+        q = q.Offset((spec.Page - 1) * spec.Limit)
+    }
+    if err := q.Limit(spec.Limit + 1).Find(&orders).Error; err != nil {
+        return nil, info, err
+    }
+
+    orders, info = query.Window(orders, spec)
+    if !spec.SkipCount {
+        n := int(total)
+        info.Total = &n
+    }
+    return orders, info, nil
+}
+
+// SummarizeByUser aggregates all of the user's orders matching the spec filters
+// (paging and cursors are ignored) in a single SQL query.
+func (r *gormOrderRepo) SummarizeByUser(ctx context.Context, userID uint, spec query.Spec) (_ models.OrderSummary, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var summary models.OrderSummary
+
+    q := r.db.readConn(ctx).Model(&models.Order{}).Where("user_id = ?", userID)
+    q = query.ApplyFilters(q, spec, models.OrderQuerySchema)
+    row := q.Select("COUNT(*), COALESCE(SUM(price * quantity), 0)").Row()
+    if err := row.Scan(&summary.Count, &summary.TotalAmount); err != nil {
+        return summary, err
+    }
+    return summary, nil
+}
+
+func (r *gormOrderRepo) GetByID(ctx context.Context, id uint) (_ *models.Order, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var order models.Order
+    if err := r.db.readConn(ctx).First(&order, id).Error; err != nil {
         return nil, err
     }
-    return orders, nil
+    return &order, nil
 }
 
+// Update writes the editable fields only if the order still has expectedStatus,
+// so a concurrent status change is not silently overwritten (ErrStaleVersion).
+func (r *gormOrderRepo) Update(ctx context.Context, order *models.Order, expectedStatus string) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    res := r.db.conn(ctx).Model(&models.Order{}).
+        Where("id = ? AND status = ?", order.ID, expectedStatus).
+        UpdateColumns(map[string]interface{}{
+            "product":  order.Product,
+            "quantity": order.Quantity,
+            "price":    order.Price,
+            "status":   order.Status,
+        })
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrStaleVersion
+    }
+    return nil
+}
+
+// Delete soft-deletes the order.
+func (r *gormOrderRepo) Delete(ctx context.Context, id uint) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return r.db.conn(ctx).Delete(&models.Order{ID: id}).Error
+}
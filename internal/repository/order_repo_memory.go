@@ -0,0 +1,156 @@
+package repository
+
+import (
+    "context"
+    "sort"
+    "time"
+
+    "gorm.io/gorm"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+type memoryOrderRepo struct {
+    s *MemoryStore
+}
+
+// NewMemoryOrderRepo creates an in-memory implementation.
+func NewMemoryOrderRepo(s *MemoryStore) OrderRepository {
+    return &memoryOrderRepo{s: s}
+}
+
+func (r *memoryOrderRepo) Create(ctx context.Context, order *models.Order) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        r.s.insertOrder(order, time.Now(), undo)
+        return nil
+    })
+}
+
+// CreateBatch inserts all orders at once, see gormOrderRepo.CreateBatch.
+func (r *memoryOrderRepo) CreateBatch(ctx context.Context, orders []*models.Order) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        now := time.Now()
+        for _, order := range orders {
+            r.s.insertOrder(order, now, undo)
+        }
+        return nil
+    })
+}
+
+// insertOrder applies the column defaults and stores the order. The store
+// must be locked for writing.
+func (s *MemoryStore) insertOrder(order *models.Order, now time.Time, undo func(func())) {
+    s.lastOrderID++
+    order.ID = s.lastOrderID
+    if order.Status == "" {
+        order.Status = models.OrderStatusPending
+    }
+    if order.CreatedAt.IsZero() {
+        order.CreatedAt = now
+    }
+    s.orders[order.ID] = *order
+
+    id := order.ID
+    undo(func() { delete(s.orders, id) })
+}
+
+func (r *memoryOrderRepo) ListByUser(ctx context.Context, userID uint, spec query.Spec) ([]models.Order, query.PageInfo, error) {
+    var orders []models.Order
+    if err := r.s.view(ctx, func() error {
+        orders = r.s.userOrders(userID)
+        return nil
+    }); err != nil {
+        return nil, query.PageInfo{}, err
+    }
+    return memoryPage(orders, spec, models.OrderQuerySchema)
+}
+
+func (r *memoryOrderRepo) SummarizeByUser(ctx context.Context, userID uint, spec query.Spec) (models.OrderSummary, error) {
+    var summary models.OrderSummary
+    var orders []models.Order
+    if err := r.s.view(ctx, func() error {
+        orders = r.s.userOrders(userID)
+        return nil
+    }); err != nil {
+        return summary, err
+    }
+
+    orders, err := query.FilterSlice(orders, spec, models.OrderQuerySchema)
+    if err != nil {
+        return summary, err
+    }
+    for _, o := range orders {
+        summary.Count++
+        summary.TotalAmount += o.Price * float64(o.Quantity)
+    }
+    return summary, nil
+}
+
+// userOrders returns the live orders of the user in id order. The store must be locked.
+func (s *MemoryStore) userOrders(userID uint) []models.Order {
+    var orders []models.Order
+    for _, o := range s.orders {
+        if o.UserID == userID && !o.DeletedAt.Valid {
+            orders = append(orders, o)
+        }
+    }
+    sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+    return orders
+}
+
+func (r *memoryOrderRepo) GetByID(ctx context.Context, id uint) (*models.Order, error) {
+    var order models.Order
+    err := r.s.view(ctx, func() error {
+        o, ok := r.s.orders[id]
+        if !ok || o.DeletedAt.Valid {
+            return gorm.ErrRecordNotFound
+        }
+        order = o
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &order, nil
+}
+
+// Update writes the editable fields only if the order still has expectedStatus,
+// see gormOrderRepo.Update.
+func (r *memoryOrderRepo) Update(ctx context.Context, order *models.Order, expectedStatus string) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        old, ok := r.s.orders[order.ID]
+        if !ok || old.DeletedAt.Valid || old.Status != expectedStatus {
+            return ErrStaleVersion
+        }
+
+        updated := old
+        updated.Product = order.Product
+        updated.Quantity = order.Quantity
+        updated.Price = order.Price
+        updated.Status = order.Status
+        r.s.orders[order.ID] = updated
+        undo(func() { r.s.orders[old.ID] = old })
+        return nil
+    })
+}
+
+// Delete soft-deletes the order. Like the GORM implementation it does not
+// report a missing order.
+func (r *memoryOrderRepo) Delete(ctx context.Context, id uint) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        if o, ok := r.s.orders[id]; ok && !o.DeletedAt.Valid {
+            r.s.setOrderDeleted(o, gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}, undo)
+        }
+        return nil
+    })
+}
+
+// setOrderDeleted stores the order with the given deleted_at. The store must
+// be locked for writing.
+func (s *MemoryStore) setOrderDeleted(o models.Order, deletedAt gorm.DeletedAt, undo func(func())) {
+    updated := o
+    updated.DeletedAt = deletedAt
+    s.orders[o.ID] = updated
+    undo(func() { s.orders[o.ID] = o })
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+    "context"
+    "log"
+    "sync/atomic"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+// replica is a read-only copy of the primary database. It only serves reads
+// while the last health check succeeded.
+type replica struct {
+    db      *gorm.DB
+    name    string
+    healthy atomic.Bool
+}
+
+type primaryKey struct{}
+
+// PinPrimary returns a context whose reads go to the primary, like its writes,
+// so they see the writes already made and are fresh enough to base new writes
+// on. Without it a read may go to a replica and lag behind the primary.
+func PinPrimary(ctx context.Context) context.Context {
+    return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func pinnedToPrimary(ctx context.Context) bool {
+    pinned, _ := ctx.Value(primaryKey{}).(bool)
+    return pinned
+}
+
+// readConn is conn for read-only statements: outside a transaction and
+// unless ctx is pinned to the primary, they run on a healthy replica.
+func (d *DB) readConn(ctx context.Context) *gorm.DB {
+    if _, ok := txFromContext(ctx); ok {
+        return d.conn(ctx)
+    }
+    return d.readRoot(ctx).WithContext(ctx)
+}
+
+// readRoot picks the database for a read made with ctx: the next healthy
+// replica in turn, or the primary if ctx is pinned to it or no replica is healthy.
+func (d *DB) readRoot(ctx context.Context) *gorm.DB {
+    if len(d.replicas) == 0 || pinnedToPrimary(ctx) {
+        return d.root
+    }
+    start := d.next.Add(1)
+    for i := range d.replicas {
+        r := d.replicas[(start+uint64(i))%uint64(len(d.replicas))]
+        if r.healthy.Load() {
+            return r.db
+        }
+    }
+    return d.root
+}
+
+// MonitorReplicas checks the replicas now and then every interval until ctx
+// is done. Replicas serve no reads before their first successful check.
+func (d *DB) MonitorReplicas(ctx context.Context, interval time.Duration) {
+    if len(d.replicas) == 0 {
+        return
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        d.checkReplicas(ctx)
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// checkReplicas pings every replica within the read timeout and records
+// whether it answered.
+func (d *DB) checkReplicas(ctx context.Context) {
+    for _, r := range d.replicas {
+        err := d.ping(ctx, r.db)
+        healthy := err == nil
+        if r.healthy.Swap(healthy) == healthy {
+            continue
+        }
+        if healthy {
+            log.Printf("database replica %s is up", r.name)
+        } else {
+            log.Printf("database replica %s is down, reads fall back to other replicas or the primary: %v", r.name, err)
+        }
+    }
+}
+
+func (d *DB) ping(ctx context.Context, db *gorm.DB) (err error) {
+    ctx, done := d.read(ctx)
+    defer done(&err)
+
+    sqlDB, err := db.DB()
+    if err != nil {
+        return err
+    }
+    return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "gorm.io/driver/sqlite"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// TestReplicaRouting uses two unrelated SQLite files as the primary and its
+// replica, so a read shows where it went by whether it finds the primary's rows.
+func TestReplicaRouting(t *testing.T) {
+    ctx := context.Background()
+    primary := openDB(t, sqlite.Open(sqliteDSN(t.TempDir())))
+    replica := openDB(t, sqlite.Open(sqliteDSN(t.TempDir())))
+
+    d := NewDB(primary, Timeouts{Read: time.Second, Write: time.Second})
+    d.AddReplica("replica1", replica)
+    users := NewGormUserRepo(d)
+
+    user := &models.User{Name: "Ann", Email: "ann@example.com", Age: 30}
+    if err := users.Create(ctx, user); err != nil {
+        t.Fatalf("Create: %v", err)
+    }
+
+    // Replicas serve no reads before their first check
+    if d.readRoot(ctx) != primary {
+        t.Error("unchecked replica picked for a read")
+    }
+
+    d.checkReplicas(ctx)
+    if d.readRoot(ctx) != replica {
+        t.Fatal("healthy replica not picked for a read")
+    }
+    if _, err := users.GetByID(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+        t.Errorf("GetByID on the replica: got %v, want ErrNotFound", err)
+    }
+
+    // A pinned context reads its own write, and so does a transaction
+    if _, err := users.GetByID(PinPrimary(ctx), user.ID); err != nil {
+        t.Errorf("GetByID pinned to the primary: %v", err)
+    }
+    err := NewGormTxManager(d).WithinTx(ctx, func(ctx context.Context) error {
+        _, err := users.GetByID(ctx, user.ID)
+        return err
+    })
+    if err != nil {
+        t.Errorf("GetByID in a transaction: %v", err)
+    }
+
+    // A replica that stops answering is skipped until it answers again
+    sqlDB, err := replica.DB()
+    if err != nil {
+        t.Fatalf("replica pool: %v", err)
+    }
+    sqlDB.Close()
+    d.checkReplicas(ctx)
+    if d.readRoot(ctx) != primary {
+        t.Fatal("unhealthy replica picked for a read")
+    }
+    if _, err := users.GetByID(ctx, user.ID); err != nil {
+        t.Errorf("GetByID falling back to the primary: %v", err)
+    }
+}
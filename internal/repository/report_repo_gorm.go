@@ -0,0 +1,213 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+
+    "gorm.io/gorm"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+// ReportRepository defines read-only aggregate queries over orders of all users.
+// Cancelled and deleted orders never count towards revenue.
+type ReportRepository interface {
+    SearchOrders(ctx context.Context, spec query.Spec) ([]models.AdminOrder, query.PageInfo, error)
+    StreamOrders(ctx context.Context, spec query.Spec, fn func(models.AdminOrder) error) error
+    RevenueByPeriod(ctx context.Context, period string, rng models.ReportRange) ([]models.RevenuePoint, error)
+    TopProducts(ctx context.Context, limit int, rng models.ReportRange) ([]models.ProductStat, error)
+    TopCustomers(ctx context.Context, limit int, rng models.ReportRange) ([]models.CustomerStat, error)
+    OrderValue(ctx context.Context, rng models.ReportRange) (models.OrderValueStats, error)
+}
+
+type gormReportRepo struct {
+    db *DB
+}
+
+// NewGormReportRepo creates a GORM implementation.
+func NewGormReportRepo(db *DB) ReportRepository {
+    return &gormReportRepo{db: db}
+}
+
+// SearchOrders pages through orders of all users joined with their owner.
+func (r *gormReportRepo) SearchOrders(ctx context.Context, spec query.Spec) (_ []models.AdminOrder, _ query.PageInfo, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var rows []models.AdminOrder
+    var info query.PageInfo
+
+    base := r.ordersBase(ctx)
+
+    var total int64
+    if !spec.SkipCount {
+        if err := query.ApplyFilters(base, spec, models.AdminOrderQuerySchema).Count(&total).Error; err != nil {
+            return nil, info, err
+        }
+    }
+
+    // Rows are projected by the handler; the select list below is fixed
+    spec.Fields = nil
+    q := query.Apply(base, spec, models.AdminOrderQuerySchema).Select(adminOrderColumns)
+    if spec.Cursor == nil {
+        q = q.Offset((spec.Page - 1) * spec.Limit)
+    }
+    if err := q.Limit(spec.Limit + 1).Scan(&rows).Error; err != nil {
+        return nil, info, err
+    }
+
+    rows, info = query.Window(rows, spec)
+    if !spec.SkipCount {
+        n := int(total)
+        info.Total = &n
+    }
+    return rows, info, nil
+}
+
+// StreamOrders calls fn for every order matching the filters of spec, in spec order.
+// Paging parameters of spec are ignored.
+func (r *gormReportRepo) StreamOrders(ctx context.Context, spec query.Spec, fn func(models.AdminOrder) error) (err error) {
+    defer func() { err = contextError(ctx, err) }()
+
+    spec.Fields = nil
+    spec.Cursor = nil
+    q := query.Apply(r.ordersBase(ctx), spec, models.AdminOrderQuerySchema).Select(adminOrderColumns)
+
+    return streamQuery(ctx, r.db, q, func(tx *gorm.DB, rows *sql.Rows) error {
+        var order models.AdminOrder
+        if err := tx.ScanRows(rows, &order); err != nil {
+            return err
+        }
+        return fn(order)
+    })
+}
+
+// adminOrderColumns is the select list of models.AdminOrder rows.
+const adminOrderColumns = "orders.id, orders.user_id, users.email AS user_email, orders.product, " +
+    "orders.quantity, orders.price, orders.price * orders.quantity AS amount, " +
+    "orders.status, orders.created_at"
+
+// ordersBase selects live orders joined with their owner. The query can be
+// reused, e.g. for a count and a page.
+func (r *gormReportRepo) ordersBase(ctx context.Context) *gorm.DB {
+    return r.db.readConn(ctx).Table("orders").
+        Joins("JOIN users ON users.id = orders.user_id").
+        Where("orders.deleted_at IS NULL").
+        Session(&gorm.Session{})
+}
+
+func (r *gormReportRepo) RevenueByPeriod(ctx context.Context, period string, rng models.ReportRange) (_ []models.RevenuePoint, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    if r.db.dialect() == dialectSQLite {
+        return r.revenueByPeriodSQLite(ctx, period, rng)
+    }
+
+    var points []models.RevenuePoint
+    err = r.revenueBase(ctx, rng).
+        Select("date_trunc(?, orders.created_at) AS period, COUNT(*) AS orders, "+
+            "SUM(orders.price * orders.quantity) AS revenue", period).
+        Group("period").
+        Order("period").
+        Scan(&points).Error
+    return points, err
+}
+
+// sqlitePeriods truncate orders.created_at like date_trunc, with weeks
+// starting on Monday. SQLite date functions return text (YYYY-MM-DD).
+var sqlitePeriods = map[string]string{
+    models.PeriodDay:   "date(orders.created_at)",
+    models.PeriodWeek:  "date(orders.created_at, 'weekday 0', '-6 days')",
+    models.PeriodMonth: "date(orders.created_at, 'start of month')",
+}
+
+func (r *gormReportRepo) revenueByPeriodSQLite(ctx context.Context, period string, rng models.ReportRange) ([]models.RevenuePoint, error) {
+    expr, ok := sqlitePeriods[period]
+    if !ok {
+        return nil, fmt.Errorf("unknown period %q", period)
+    }
+
+    var rows []struct {
+        Period  string
+        Orders  int
+        Revenue float64
+    }
+    err := r.revenueBase(ctx, rng).
+        Select(expr + " AS period, COUNT(*) AS orders, SUM(orders.price * orders.quantity) AS revenue").
+        Group("period").
+        Order("period").
+        Scan(&rows).Error
+    if err != nil {
+        return nil, err
+    }
+
+    points := make([]models.RevenuePoint, len(rows))
+    for i, row := range rows {
+        start, err := time.Parse(time.DateOnly, row.Period)
+        if err != nil {
+            return nil, err
+        }
+        points[i] = models.RevenuePoint{Period: start, Orders: row.Orders, Revenue: row.Revenue}
+    }
+    return points, nil
+}
+
+func (r *gormReportRepo) TopProducts(ctx context.Context, limit int, rng models.ReportRange) (_ []models.ProductStat, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var stats []models.ProductStat
+    err = r.revenueBase(ctx, rng).
+        Select("orders.product, COUNT(*) AS orders, SUM(orders.quantity) AS quantity, " +
+            "SUM(orders.price * orders.quantity) AS revenue").
+        Group("orders.product").
+        Order("revenue DESC, orders.product").
+        Limit(limit).
+        Scan(&stats).Error
+    return stats, err
+}
+
+func (r *gormReportRepo) TopCustomers(ctx context.Context, limit int, rng models.ReportRange) (_ []models.CustomerStat, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var stats []models.CustomerStat
+    err = r.revenueBase(ctx, rng).
+        Joins("JOIN users ON users.id = orders.user_id").
+        Select("users.id AS user_id, users.email, users.name, COUNT(*) AS orders, " +
+            "SUM(orders.price * orders.quantity) AS revenue").
+        Group("users.id, users.email, users.name").
+        Order("revenue DESC, users.id").
+        Limit(limit).
+        Scan(&stats).Error
+    return stats, err
+}
+
+func (r *gormReportRepo) OrderValue(ctx context.Context, rng models.ReportRange) (_ models.OrderValueStats, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var stats models.OrderValueStats
+    row := r.revenueBase(ctx, rng).
+        Select("COUNT(*), COALESCE(SUM(orders.price * orders.quantity), 0), " +
+            "COALESCE(AVG(orders.price * orders.quantity), 0)").
+        Row()
+    err = row.Scan(&stats.Orders, &stats.Revenue, &stats.AverageOrderValue)
+    return stats, err
+}
+
+// revenueBase selects live, non-cancelled orders inside the range.
+func (r *gormReportRepo) revenueBase(ctx context.Context, rng models.ReportRange) *gorm.DB {
+    q := r.db.readConn(ctx).Table("orders").
+        Where("orders.deleted_at IS NULL AND orders.status <> ?", models.OrderStatusCancelled)
+    if rng.From != nil {
+        q = q.Where("orders.created_at >= ?", *rng.From)
+    }
+    if rng.To != nil {
+        q = q.Where("orders.created_at < ?", *rng.To)
+    }
+    return q
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+type memoryReportRepo struct {
+    s *MemoryStore
+}
+
+// NewMemoryReportRepo creates an in-memory implementation.
+func NewMemoryReportRepo(s *MemoryStore) ReportRepository {
+    return &memoryReportRepo{s: s}
+}
+
+func (r *memoryReportRepo) SearchOrders(ctx context.Context, spec query.Spec) ([]models.AdminOrder, query.PageInfo, error) {
+    rows, err := r.adminOrders(ctx)
+    if err != nil {
+        return nil, query.PageInfo{}, err
+    }
+    return memoryPage(rows, spec, models.AdminOrderQuerySchema)
+}
+
+// StreamOrders calls fn for every order matching the filters of spec, in spec
+// order, from a snapshot taken when the stream starts.
+func (r *memoryReportRepo) StreamOrders(ctx context.Context, spec query.Spec, fn func(models.AdminOrder) error) error {
+    rows, err := r.adminOrders(ctx)
+    if err != nil {
+        return err
+    }
+
+    spec.Cursor = nil
+    rows, err = query.ApplySlice(rows, spec, models.AdminOrderQuerySchema)
+    if err != nil {
+        return err
+    }
+    for _, row := range rows {
+        if err := ctx.Err(); err != nil {
+            return contextError(ctx, err)
+        }
+        if err := fn(row); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// adminOrders returns live orders joined with their owner, in id order.
+func (r *memoryReportRepo) adminOrders(ctx context.Context) ([]models.AdminOrder, error) {
+    var rows []models.AdminOrder
+    err := r.s.view(ctx, func() error {
+        for _, o := range r.s.orders {
+            u, ok := r.s.users[o.UserID]
+            if !ok || o.DeletedAt.Valid {
+                continue
+            }
+            rows = append(rows, models.AdminOrder{
+                ID:        o.ID,
+                UserID:    o.UserID,
+                UserEmail: u.Email,
+                Product:   o.Product,
+                Quantity:  o.Quantity,
+                Price:     o.Price,
+                Amount:    o.Price * float64(o.Quantity),
+                Status:    o.Status,
+                CreatedAt: o.CreatedAt,
+            })
+        }
+        return nil
+    })
+    sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+    return rows, err
+}
+
+// revenueOrders returns live, non-cancelled orders inside the range.
+func (r *memoryReportRepo) revenueOrders(ctx context.Context, rng models.ReportRange) ([]models.Order, error) {
+    var orders []models.Order
+    err := r.s.view(ctx, func() error {
+        for _, o := range r.s.orders {
+            switch {
+            case o.DeletedAt.Valid, o.Status == models.OrderStatusCancelled:
+            case rng.From != nil && o.CreatedAt.Before(*rng.From):
+            case rng.To != nil && !o.CreatedAt.Before(*rng.To):
+            default:
+                orders = append(orders, o)
+            }
+        }
+        return nil
+    })
+    return orders, err
+}
+
+func (r *memoryReportRepo) RevenueByPeriod(ctx context.Context, period string, rng models.ReportRange) ([]models.RevenuePoint, error) {
+    orders, err := r.revenueOrders(ctx, rng)
+    if err != nil {
+        return nil, err
+    }
+
+    byPeriod := make(map[time.Time]*models.RevenuePoint)
+    for _, o := range orders {
+        start, err := truncatePeriod(o.CreatedAt, period)
+        if err != nil {
+            return nil, err
+        }
+        p, ok := byPeriod[start]
+        if !ok {
+            p = &models.RevenuePoint{Period: start}
+            byPeriod[start] = p
+        }
+        p.Orders++
+        p.Revenue += o.Price * float64(o.Quantity)
+    }
+
+    points := make([]models.RevenuePoint, 0, len(byPeriod))
+    for _, p := range byPeriod {
+        points = append(points, *p)
+    }
+    sort.Slice(points, func(i, j int) bool { return points[i].Period.Before(points[j].Period) })
+    return points, nil
+}
+
+// truncatePeriod is Postgres date_trunc in UTC, the session time zone of the
+// GORM connection.
+func truncatePeriod(t time.Time, period string) (time.Time, error) {
+    y, m, d := t.UTC().Date()
+    switch period {
+    case models.PeriodDay:
+        return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+    case models.PeriodWeek:
+        day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+        // Weeks start on Monday
+        return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)), nil
+    case models.PeriodMonth:
+        return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC), nil
+    }
+    return time.Time{}, fmt.Errorf("unknown period %q", period)
+}
+
+func (r *memoryReportRepo) TopProducts(ctx context.Context, limit int, rng models.ReportRange) ([]models.ProductStat, error) {
+    orders, err := r.revenueOrders(ctx, rng)
+    if err != nil {
+        return nil, err
+    }
+
+    byProduct := make(map[string]*models.ProductStat)
+    for _, o := range orders {
+        st, ok := byProduct[o.Product]
+        if !ok {
+            st = &models.ProductStat{Product: o.Product}
+            byProduct[o.Product] = st
+        }
+        st.Orders++
+        st.Quantity += o.Quantity
+        st.Revenue += o.Price * float64(o.Quantity)
+    }
+
+    stats := make([]models.ProductStat, 0, len(byProduct))
+    for _, st := range byProduct {
+        stats = append(stats, *st)
+    }
+    sort.Slice(stats, func(i, j int) bool {
+        if stats[i].Revenue != stats[j].Revenue {
+            return stats[i].Revenue > stats[j].Revenue
+        }
+        return stats[i].Product < stats[j].Product
+    })
+    return stats[:min(limit, len(stats))], nil
+}
+
+func (r *memoryReportRepo) TopCustomers(ctx context.Context, limit int, rng models.ReportRange) ([]models.CustomerStat, error) {
+    orders, err := r.revenueOrders(ctx, rng)
+    if err != nil {
+        return nil, err
+    }
+
+    byUser := make(map[uint]*models.CustomerStat)
+    err = r.s.view(ctx, func() error {
+        for _, o := range orders {
+            u, ok := r.s.users[o.UserID]
+            if !ok {
+                continue
+            }
+            st, ok := byUser[u.ID]
+            if !ok {
+                st = &models.CustomerStat{UserID: u.ID, Email: u.Email, Name: u.Name}
+                byUser[u.ID] = st
+            }
+            st.Orders++
+            st.Revenue += o.Price * float64(o.Quantity)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    stats := make([]models.CustomerStat, 0, len(byUser))
+    for _, st := range byUser {
+        stats = append(stats, *st)
+    }
+    sort.Slice(stats, func(i, j int) bool {
+        if stats[i].Revenue != stats[j].Revenue {
+            return stats[i].Revenue > stats[j].Revenue
+        }
+        return stats[i].UserID < stats[j].UserID
+    })
+    return stats[:min(limit, len(stats))], nil
+}
+
+func (r *memoryReportRepo) OrderValue(ctx context.Context, rng models.ReportRange) (models.OrderValueStats, error) {
+    var stats models.OrderValueStats
+    orders, err := r.revenueOrders(ctx, rng)
+    if err != nil {
+        return stats, err
+    }
+    for _, o := range orders {
+        stats.Orders++
+        stats.Revenue += o.Price * float64(o.Quantity)
+    }
+    if stats.Orders > 0 {
+        stats.AverageOrderValue = stats.Revenue / float64(stats.Orders)
+    }
+    return stats, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+
+    "gorm.io/gorm"
+)
+
+// streamBatchSize is the number of rows fetched from a server-side cursor at a time.
+const streamBatchSize = 1000
+
+// streamQuery runs q through a Postgres server-side cursor inside a read-only
+// transaction and calls each for every row, so memory use does not depend on
+// the size of the result. An error from each stops the stream and closes the cursor.
+// Inside a caller's transaction the cursor runs in a savepoint of it instead.
+// SQLite has no cursors but steps through a result lazily, so q is simply run.
+// Outside a transaction the stream reads from the database picked by readRoot,
+// q must be built on readConn to match.
+func streamQuery(ctx context.Context, db *DB, q *gorm.DB, each func(tx *gorm.DB, rows *sql.Rows) error) error {
+    if db.dialect() != dialectPostgres {
+        rows, err := q.Rows()
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+
+        tx := db.conn(ctx)
+        for rows.Next() {
+            if err := each(tx, rows); err != nil {
+                return err
+            }
+        }
+        return rows.Err()
+    }
+
+    _, nested := txFromContext(ctx)
+    return withinTxOn(ctx, db, db.readRoot(ctx), func(ctx context.Context, tx *gorm.DB) error {
+        if !nested {
+            if err := tx.Exec("SET TRANSACTION READ ONLY").Error; err != nil {
+                return err
+            }
+        }
+        if err := tx.Exec("DECLARE stream_cursor NO SCROLL CURSOR FOR ?", q).Error; err != nil {
+            return err
+        }
+        fetch := fmt.Sprintf("FETCH %d FROM stream_cursor", streamBatchSize)
+        for {
+            n, err := fetchBatch(tx, fetch, each)
+            if err != nil {
+                return err
+            }
+            if n < streamBatchSize {
+                break
+            }
+        }
+        return tx.Exec("CLOSE stream_cursor").Error
+    })
+}
+
+func fetchBatch(tx *gorm.DB, fetch string, each func(tx *gorm.DB, rows *sql.Rows) error) (int, error) {
+    rows, err := tx.Raw(fetch).Rows()
+    if err != nil {
+        return 0, err
+    }
+    defer rows.Close()
+
+    n := 0
+    for rows.Next() {
+        n++
+        if err := each(tx, rows); err != nil {
+            return n, err
+        }
+    }
+    return n, rows.Err()
+}
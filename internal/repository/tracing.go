@@ -0,0 +1,77 @@
+package repository
+
+import (
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    "gorm.io/gorm"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/tracing"
+)
+
+const spanKey = "kvant:span"
+
+// TraceQueries adds GORM callbacks running every statement of db in a client
+// span, a child of the span in the statement's context. name tells the
+// primary and the replicas apart. The SQL is recorded with placeholders, never
+// with the values.
+func TraceQueries(db *gorm.DB, name string) error {
+    cb := db.Callback()
+    hooks := []struct {
+        op     string
+        before func(string, func(*gorm.DB)) error
+        after  func(string, func(*gorm.DB)) error
+    }{
+        {"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+        {"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+        {"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+        {"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+        {"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+        {"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+    }
+
+    system := db.Dialector.Name()
+    for _, h := range hooks {
+        op := h.op
+        start := func(tx *gorm.DB) {
+            ctx := tx.Statement.Context
+            if ctx == nil || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+                // Only statements of a traced request or task
+                return
+            }
+            _, span := tracing.Start(ctx, "db."+op,
+                trace.WithSpanKind(trace.SpanKindClient),
+                trace.WithAttributes(
+                    attribute.String("db.system", system),
+                    attribute.String("db.instance", name),
+                    attribute.String("db.operation", op),
+                    attribute.String("db.table", tx.Statement.Table),
+                ),
+            )
+            tx.InstanceSet(spanKey, span)
+        }
+        end := func(tx *gorm.DB) {
+            v, ok := tx.InstanceGet(spanKey)
+            if !ok {
+                return
+            }
+            span := v.(trace.Span)
+            span.SetAttributes(
+                attribute.String("db.statement", tx.Statement.SQL.String()),
+                attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+            )
+            err := tx.Error
+            if err == gorm.ErrRecordNotFound {
+                // An expected outcome, not a failed query
+                err = nil
+            }
+            tracing.End(span, err)
+        }
+        if err := h.before("kvant:trace_before_"+op, start); err != nil {
+            return err
+        }
+        if err := h.after("kvant:trace_after_"+op, end); err != nil {
+            return err
+        }
+    }
+    return nil
+}
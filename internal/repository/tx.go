@@ -0,0 +1,174 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "sync"
+
+    "gorm.io/gorm"
+)
+
+// TxManager runs a callback inside a database transaction. The transaction
+// travels in the context passed to the callback, and every GORM repository
+// called with that context joins it, so a service can make several repository
+// calls atomic without knowing about *gorm.DB.
+type TxManager interface {
+    // WithinTx commits if fn returns nil and rolls back if it returns an error
+    // or panics. A nested call runs in a savepoint of the outer transaction:
+    // its failure only undoes its own work, and the outer caller decides.
+    WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// txState is the transaction stored in a context.
+type txState struct {
+    tx    *gorm.DB
+    depth int
+    hooks *commitHooks
+}
+
+// commitHooks are the functions to run once the outermost transaction commits,
+// and the locks it releases when it ends.
+type commitHooks struct {
+    fns []func()
+    // locks are the mutexes held until the transaction ends, see lockUntilEnd
+    locks []*sync.Mutex
+}
+
+func (h *commitHooks) run() {
+    for _, fn := range h.fns {
+        fn()
+    }
+}
+
+// lockUntilEnd locks mu for the rest of the transaction: it is unlocked by
+// release, whether the transaction commits or rolls back. Locking a mutex
+// the transaction already holds does nothing.
+func (h *commitHooks) lockUntilEnd(mu *sync.Mutex) {
+    for _, held := range h.locks {
+        if held == mu {
+            return
+        }
+    }
+    mu.Lock()
+    h.locks = append(h.locks, mu)
+}
+
+// release unlocks the mutexes held by the transaction once it has ended.
+func (h *commitHooks) release() {
+    for _, mu := range h.locks {
+        mu.Unlock()
+    }
+    h.locks = nil
+}
+
+// AfterCommit runs fn once the transaction carried by ctx has committed, or at
+// once if ctx carries none. fn does not run if the transaction rolls back; it
+// does if only a savepoint it was registered in rolled back.
+func AfterCommit(ctx context.Context, fn func()) {
+    if state, ok := txFromContext(ctx); ok {
+        state.hooks.fns = append(state.hooks.fns, fn)
+        return
+    }
+    if tx := memoryTxFromContext(ctx); tx != nil {
+        tx.hooks.fns = append(tx.hooks.fns, fn)
+        return
+    }
+    fn()
+}
+
+// inTx reports whether ctx carries a transaction of either kind.
+func inTx(ctx context.Context) bool {
+    _, ok := txFromContext(ctx)
+    return ok || memoryTxFromContext(ctx) != nil
+}
+
+type gormTxManager struct {
+    db *DB
+}
+
+// NewGormTxManager creates a TxManager for db.
+func NewGormTxManager(db *DB) TxManager {
+    return &gormTxManager{db: db}
+}
+
+func (m *gormTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+    // The transaction is bound to ctx: cancelling it rolls the transaction back
+    defer func() { err = contextError(ctx, err) }()
+    return withinTx(ctx, m.db, func(ctx context.Context, _ *gorm.DB) error {
+        return fn(ctx)
+    })
+}
+
+// withinTx runs fn in the transaction of ctx, or in a new one on the primary.
+// tx is bound to the context passed to fn.
+func withinTx(ctx context.Context, db *DB, fn func(ctx context.Context, tx *gorm.DB) error) error {
+    return withinTxOn(ctx, db, db.root, fn)
+}
+
+// withinTxOn is withinTx with a new transaction started on root, which may be
+// a replica for read-only work.
+func withinTxOn(ctx context.Context, db *DB, root *gorm.DB, fn func(ctx context.Context, tx *gorm.DB) error) error {
+    if outer, ok := txFromContext(ctx); ok {
+        return withinSavepoint(ctx, db, outer, fn)
+    }
+
+    // Transaction rolls back on error and on panic
+    hooks := &commitHooks{}
+    defer hooks.release()
+    err := root.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        ctx := context.WithValue(ctx, txKey{}, &txState{tx: tx, hooks: hooks})
+        return fn(ctx, db.conn(ctx))
+    })
+    if err != nil {
+        return err
+    }
+    // Hooks may start transactions of their own that take the same locks
+    hooks.release()
+    hooks.run()
+    return nil
+}
+
+func withinSavepoint(ctx context.Context, db *DB, outer *txState, fn func(ctx context.Context, tx *gorm.DB) error) error {
+    state := &txState{tx: outer.tx, depth: outer.depth + 1, hooks: outer.hooks}
+    ctx = context.WithValue(ctx, txKey{}, state)
+    tx := db.conn(ctx)
+
+    name := fmt.Sprintf("sp_%d", state.depth)
+    if err := tx.Exec("SAVEPOINT " + name).Error; err != nil {
+        return err
+    }
+    defer func() {
+        if p := recover(); p != nil {
+            tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+            panic(p)
+        }
+    }()
+
+    if err := fn(ctx, tx); err != nil {
+        if rbErr := tx.Exec("ROLLBACK TO SAVEPOINT " + name).Error; rbErr != nil {
+            return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+        }
+        return err
+    }
+    return tx.Exec("RELEASE SAVEPOINT " + name).Error
+}
+
+// DetachTx returns a context with the values of ctx but without its transaction,
+// for work that outlives the transaction, such as background jobs.
+func DetachTx(ctx context.Context) context.Context {
+    if _, ok := txFromContext(ctx); ok {
+        ctx = context.WithValue(ctx, txKey{}, (*txState)(nil))
+    }
+    if memoryTxFromContext(ctx) != nil {
+        ctx = context.WithValue(ctx, memoryTxKey{}, (*memoryTx)(nil))
+    }
+    return ctx
+}
+
+func txFromContext(ctx context.Context) (*txState, bool) {
+    state, ok := ctx.Value(txKey{}).(*txState)
+    return state, ok && state != nil
+}
+
@@ -0,0 +1,97 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/cache"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+)
+
+// cachedUserRepo serves GetByID from a cache in front of another
+// UserRepository. Entries of a user are keyed by its generation, which every
+// write of the user or of its orders drops once committed, see userGeneration.
+type cachedUserRepo struct {
+    UserRepository
+    cache *cache.Cache
+}
+
+// NewCachedUserRepo wraps next with a cache-aside layer for GetByID.
+func NewCachedUserRepo(next UserRepository, c *cache.Cache) UserRepository {
+    return &cachedUserRepo{UserRepository: next, cache: c}
+}
+
+func (r *cachedUserRepo) GetByID(ctx context.Context, id uint) (*models.User, error) {
+    if !cacheable(ctx) {
+        return r.UserRepository.GetByID(ctx, id)
+    }
+    key := fmt.Sprintf("%s:user", userGeneration(ctx, r.cache, id))
+    return cache.Fetch(ctx, r.cache, key, func(ctx context.Context) (*models.User, error) {
+        return r.UserRepository.GetByID(ctx, id)
+    })
+}
+
+func (r *cachedUserRepo) Update(ctx context.Context, user *models.User) error {
+    if err := r.UserRepository.Update(ctx, user); err != nil {
+        return err
+    }
+    invalidateUsers(ctx, r.cache, user.ID)
+    return nil
+}
+
+func (r *cachedUserRepo) Delete(ctx context.Context, id uint) error {
+    if err := r.UserRepository.Delete(ctx, id); err != nil {
+        return err
+    }
+    invalidateUsers(ctx, r.cache, id)
+    return nil
+}
+
+func (r *cachedUserRepo) Restore(ctx context.Context, id uint) (*models.User, error) {
+    user, err := r.UserRepository.Restore(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    invalidateUsers(ctx, r.cache, id)
+    return user, nil
+}
+
+func (r *cachedUserRepo) PurgeDeleted(ctx context.Context, before time.Time) ([]uint, error) {
+    ids, err := r.UserRepository.PurgeDeleted(ctx, before)
+    if err != nil {
+        return nil, err
+    }
+    invalidateUsers(ctx, r.cache, ids...)
+    return ids, nil
+}
+
+// cacheable reports whether a read made with ctx may be served from a cache:
+// not inside a transaction, which has to see its own writes, and not when
+// pinned to the primary, which asks for current data.
+func cacheable(ctx context.Context) bool {
+    return !inTx(ctx) && !pinnedToPrimary(ctx)
+}
+
+// userGeneration returns the key prefix of the cache entries of a user and
+// its orders for the user's current generation.
+func userGeneration(ctx context.Context, c *cache.Cache, id uint) string {
+    return fmt.Sprintf("user:%d:%s", id, c.Generation(ctx, userGenerationKey(id)))
+}
+
+func userGenerationKey(id uint) string {
+    return fmt.Sprintf("user:%d:gen", id)
+}
+
+// invalidateUsers drops the cache entries of the users and their orders once
+// the transaction of ctx, if any, has committed.
+func invalidateUsers(ctx context.Context, c *cache.Cache, ids ...uint) {
+    if len(ids) == 0 {
+        return
+    }
+    keys := make([]string, len(ids))
+    for i, id := range ids {
+        keys[i] = userGenerationKey(id)
+    }
+    AfterCommit(ctx, func() { c.Invalidate(ctx, keys...) })
+}
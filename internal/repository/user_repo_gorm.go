@@ -2,77 +2,253 @@ package repository
 
 import (
     "context"
+    "database/sql"
+    "time"
 
-    "github.com/jinzhu/gorm"
+    "gorm.io/gorm"
     "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
 )
 
 type UserRepository interface {
     Create(ctx context.Context, user *models.User) error
+    CreateBatch(ctx context.Context, users []*models.User) error
     FindByEmail(ctx context.Context, email string) (*models.User, error)
-    List(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int, error)
+    // EmailTaken reports whether a user, soft-deleted ones included, has the
+    // email: it stays unique until the user is purged.
+    EmailTaken(ctx context.Context, email string) (bool, error)
+    List(ctx context.Context, spec query.Spec) ([]models.User, query.PageInfo, error)
+    Stream(ctx context.Context, spec query.Spec, fn func(models.User) error) error
     GetByID(ctx context.Context, id uint) (*models.User, error)
     Update(ctx context.Context, user *models.User) error
     Delete(ctx context.Context, id uint) error
+    Restore(ctx context.Context, id uint) (*models.User, error)
+    PurgeDeleted(ctx context.Context, before time.Time) ([]uint, error)
 }
 
 type gormUserRepo struct {
-    db *gorm.DB
+    db *DB
 }
 
-func NewGormUserRepo(db *gorm.DB) UserRepository {
+func NewGormUserRepo(db *DB) UserRepository {
     return &gormUserRepo{db: db}
 }
 
-func (r *gormUserRepo) Create(ctx context.Context, user *models.User) error {
-    return r.db.Create(user).Error
+func (r *gormUserRepo) Create(ctx context.Context, user *models.User) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return r.db.conn(ctx).Create(user).Error
+}
+
+// CreateBatch inserts all users in one transaction: either every row is stored or none.
+func (r *gormUserRepo) CreateBatch(ctx context.Context, users []*models.User) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    return withinTx(ctx, r.db, func(ctx context.Context, tx *gorm.DB) error {
+        for _, user := range users {
+            if err := tx.Create(user).Error; err != nil {
+                return err
+            }
+        }
+        return nil
+    })
 }
 
-func (r *gormUserRepo) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+func (r *gormUserRepo) FindByEmail(ctx context.Context, email string) (_ *models.User, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
     var user models.User
-    if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+    if err := r.db.conn(ctx).Where("email = ?", email).First(&user).Error; err != nil {
         return nil, err
     }
     return &user, nil
 }
 
-func (r *gormUserRepo) List(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int, error) {
+func (r *gormUserRepo) EmailTaken(ctx context.Context, email string) (_ bool, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
+    var count int64
+    if err := r.db.conn(ctx).Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+        return false, err
+    }
+    return count > 0, nil
+}
+
+// List returns one page of users. Offset paging is used unless the spec has a
+// cursor; one extra row is fetched to tell whether another page exists.
+func (r *gormUserRepo) List(ctx context.Context, spec query.Spec) (_ []models.User, _ query.PageInfo, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
     var users []models.User
-    var total int
+    var info query.PageInfo
 
-    q := r.db.Model(&models.User{})
-    if minAge > 0 {
-        q = q.Where("age >= ?", minAge)
+    q := r.db.readConn(ctx).Model(&models.User{})
+    if spec.IncludeDeleted {
+        q = q.Unscoped()
     }
-    if maxAge > 0 {
-        q = q.Where("age <= ?", maxAge)
+    // Session lets q be reused for both the count and the page
+    q = q.Session(&gorm.Session{})
+
+    var total int64
+    if !spec.SkipCount {
+        if err := query.ApplyFilters(q, spec, models.UserQuerySchema).Count(&total).Error; err != nil {
+            return nil, info, err
+        }
     }
 
-    if err := q.Count(&total).Error; err != nil {
-        return nil, 0, err
+    q = query.Apply(q, spec, models.UserQuerySchema)
+    if spec.Cursor == nil {
+        q = q.Offset((spec.Page - 1) * spec.Limit)
+    }
+    if err := q.Limit(spec.Limit + 1).Find(&users).Error; err != nil {
+        return nil, info, err
     }
 
-    offset := (page - 1) * limit
-    if err := q.Limit(limit).Offset(offset).Find(&users).Error; err != nil {
-        return nil, 0, err
+    users, info = query.Window(users, spec)
+    if !spec.SkipCount {
+        n := int(total)
+        info.Total = &n
     }
+    return users, info, nil
+}
+
+// Stream calls fn for every user matching the filters of spec, in spec order.
+// Paging parameters of spec are ignored.
+// There is no timeout: the stream lasts as long as the caller's context.
+func (r *gormUserRepo) Stream(ctx context.Context, spec query.Spec, fn func(models.User) error) (err error) {
+    defer func() { err = contextError(ctx, err) }()
 
-    return users, total, nil
+    q := r.db.readConn(ctx).Model(&models.User{})
+    if spec.IncludeDeleted {
+        q = q.Unscoped()
+    }
+    spec.Cursor = nil
+    q = query.Apply(q, spec, models.UserQuerySchema)
+
+    return streamQuery(ctx, r.db, q, func(tx *gorm.DB, rows *sql.Rows) error {
+        var user models.User
+        if err := tx.ScanRows(rows, &user); err != nil {
+            return err
+        }
+        return fn(user)
+    })
 }
 
-func (r *gormUserRepo) GetByID(ctx context.Context, id uint) (*models.User, error) {
+func (r *gormUserRepo) GetByID(ctx context.Context, id uint) (_ *models.User, err error) {
+    ctx, done := r.db.read(ctx)
+    defer done(&err)
+
     var user models.User
-    if err := r.db.First(&user, id).Error; err != nil {
+    if err := r.db.readConn(ctx).First(&user, id).Error; err != nil {
         return nil, err
     }
     return &user, nil
 }
 
-func (r *gormUserRepo) Update(ctx context.Context, user *models.User) error {
-    return r.db.Save(user).Error
+// Update writes the user only if its version is still the one that was read,
+// bumping the version on success. Otherwise ErrStaleVersion is returned.
+func (r *gormUserRepo) Update(ctx context.Context, user *models.User) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    res := r.db.conn(ctx).Model(&models.User{}).
+        Where("id = ? AND version = ?", user.ID, user.Version).
+        UpdateColumns(map[string]interface{}{
+            "name":          user.Name,
+            "email":         user.Email,
+            "age":           user.Age,
+            "role":          user.Role,
+            "password_hash": user.PasswordHash,
+            "version":       gorm.Expr("version + 1"),
+        })
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrStaleVersion
+    }
+    user.Version++
+    return nil
 }
 
-func (r *gormUserRepo) Delete(ctx context.Context, id uint) error {
-    return r.db.Delete(&models.User{}, id).Error
+// Delete soft-deletes the user together with its live orders.
+// Both get the same deleted_at, which lets Restore bring back exactly those orders.
+func (r *gormUserRepo) Delete(ctx context.Context, id uint) (err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    now := time.Now().UTC()
+    return withinTx(ctx, r.db, func(ctx context.Context, tx *gorm.DB) error {
+        if err := tx.Model(&models.Order{}).Where("user_id = ?", id).
+            UpdateColumn("deleted_at", now).Error; err != nil {
+            return err
+        }
+        res := tx.Model(&models.User{}).Where("id = ?", id).UpdateColumn("deleted_at", now)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 0 {
+            return gorm.ErrRecordNotFound
+        }
+        return nil
+    })
 }
 
+// Restore undoes a soft delete of the user and the orders deleted along with it.
+func (r *gormUserRepo) Restore(ctx context.Context, id uint) (_ *models.User, err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    var user models.User
+    err = withinTx(ctx, r.db, func(ctx context.Context, tx *gorm.DB) error {
+        if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&user, id).Error; err != nil {
+            return err
+        }
+        if err := tx.Unscoped().Model(&models.Order{}).
+            Where("user_id = ? AND deleted_at = ?", id, user.DeletedAt.Time).
+            UpdateColumn("deleted_at", nil).Error; err != nil {
+            return err
+        }
+        if err := tx.Unscoped().Model(&user).UpdateColumn("deleted_at", nil).Error; err != nil {
+            return err
+        }
+        user.DeletedAt = gorm.DeletedAt{}
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+// PurgeDeleted permanently removes users soft-deleted before the given time,
+// along with all of their orders, and returns the removed user IDs.
+func (r *gormUserRepo) PurgeDeleted(ctx context.Context, before time.Time) (_ []uint, err error) {
+    ctx, done := r.db.write(ctx)
+    defer done(&err)
+
+    var ids []uint
+    err = withinTx(ctx, r.db, func(ctx context.Context, tx *gorm.DB) error {
+        if err := tx.Unscoped().Model(&models.User{}).
+            Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+            Pluck("id", &ids).Error; err != nil {
+            return err
+        }
+        if len(ids) == 0 {
+            return nil
+        }
+        if err := tx.Unscoped().Where("user_id IN (?)", ids).Delete(&models.Order{}).Error; err != nil {
+            return err
+        }
+        return tx.Unscoped().Where("id IN (?)", ids).Delete(&models.User{}).Error
+    })
+    if err != nil {
+        return nil, err
+    }
+    return ids, nil
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+    "context"
+    "sort"
+    "time"
+
+    "gorm.io/gorm"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+)
+
+type memoryUserRepo struct {
+    s *MemoryStore
+}
+
+// NewMemoryUserRepo creates an in-memory implementation.
+func NewMemoryUserRepo(s *MemoryStore) UserRepository {
+    return &memoryUserRepo{s: s}
+}
+
+func (r *memoryUserRepo) Create(ctx context.Context, user *models.User) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        return r.s.insertUser(user, undo)
+    })
+}
+
+// CreateBatch inserts all users or, on the first error, none of them.
+func (r *memoryUserRepo) CreateBatch(ctx context.Context, users []*models.User) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        for _, user := range users {
+            if err := r.s.insertUser(user, undo); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+// insertUser applies the column defaults and the unique email constraint,
+// which also covers soft-deleted users. The store must be locked for writing.
+func (s *MemoryStore) insertUser(user *models.User, undo func(func())) error {
+    for _, u := range s.users {
+        if u.Email == user.Email {
+            return ErrDuplicateKey
+        }
+    }
+
+    s.lastUserID++
+    user.ID = s.lastUserID
+    if user.Role == "" {
+        user.Role = models.RoleUser
+    }
+    if user.Version == 0 {
+        user.Version = 1
+    }
+    if user.CreatedAt.IsZero() {
+        user.CreatedAt = time.Now()
+    }
+    s.users[user.ID] = *user
+
+    id := user.ID
+    undo(func() { delete(s.users, id) })
+    return nil
+}
+
+func (r *memoryUserRepo) EmailTaken(ctx context.Context, email string) (bool, error) {
+    var taken bool
+    err := r.s.view(ctx, func() error {
+        for _, u := range r.s.users {
+            if u.Email == email {
+                taken = true
+                break
+            }
+        }
+        return nil
+    })
+    return taken, err
+}
+
+func (r *memoryUserRepo) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+    var found *models.User
+    err := r.s.view(ctx, func() error {
+        for _, u := range r.s.users {
+            if u.Email == email && !u.DeletedAt.Valid {
+                found = &u
+                return nil
+            }
+        }
+        return gorm.ErrRecordNotFound
+    })
+    return found, err
+}
+
+func (r *memoryUserRepo) List(ctx context.Context, spec query.Spec) (_ []models.User, _ query.PageInfo, err error) {
+    var users []models.User
+    if err := r.s.view(ctx, func() error {
+        users = r.s.userRows(spec.IncludeDeleted)
+        return nil
+    }); err != nil {
+        return nil, query.PageInfo{}, err
+    }
+    return memoryPage(users, spec, models.UserQuerySchema)
+}
+
+// Stream calls fn for every user matching the filters of spec, in spec order,
+// from a snapshot taken when the stream starts.
+func (r *memoryUserRepo) Stream(ctx context.Context, spec query.Spec, fn func(models.User) error) error {
+    var users []models.User
+    if err := r.s.view(ctx, func() error {
+        users = r.s.userRows(spec.IncludeDeleted)
+        return nil
+    }); err != nil {
+        return err
+    }
+
+    spec.Cursor = nil
+    users, err := query.ApplySlice(users, spec, models.UserQuerySchema)
+    if err != nil {
+        return err
+    }
+    for _, u := range users {
+        if err := ctx.Err(); err != nil {
+            return contextError(ctx, err)
+        }
+        if err := fn(u); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// userRows returns the users in id order. The store must be locked.
+func (s *MemoryStore) userRows(includeDeleted bool) []models.User {
+    users := make([]models.User, 0, len(s.users))
+    for _, u := range s.users {
+        if includeDeleted || !u.DeletedAt.Valid {
+            users = append(users, u)
+        }
+    }
+    sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+    return users
+}
+
+func (r *memoryUserRepo) GetByID(ctx context.Context, id uint) (*models.User, error) {
+    var user models.User
+    err := r.s.view(ctx, func() error {
+        u, ok := r.s.users[id]
+        if !ok || u.DeletedAt.Valid {
+            return gorm.ErrRecordNotFound
+        }
+        user = u
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+// Update writes the user only if its version is still the one that was read,
+// see gormUserRepo.Update.
+func (r *memoryUserRepo) Update(ctx context.Context, user *models.User) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        old, ok := r.s.users[user.ID]
+        if !ok || old.DeletedAt.Valid || old.Version != user.Version {
+            return ErrStaleVersion
+        }
+        for _, u := range r.s.users {
+            if u.ID != user.ID && u.Email == user.Email {
+                return ErrDuplicateKey
+            }
+        }
+
+        updated := old
+        updated.Name = user.Name
+        updated.Email = user.Email
+        updated.Age = user.Age
+        updated.Role = user.Role
+        updated.PasswordHash = user.PasswordHash
+        updated.Version++
+        r.s.users[user.ID] = updated
+        undo(func() { r.s.users[old.ID] = old })
+
+        user.Version++
+        return nil
+    })
+}
+
+// Delete soft-deletes the user together with its live orders, see gormUserRepo.Delete.
+func (r *memoryUserRepo) Delete(ctx context.Context, id uint) error {
+    return r.s.update(ctx, func(undo func(func())) error {
+        user, ok := r.s.users[id]
+        if !ok || user.DeletedAt.Valid {
+            return gorm.ErrRecordNotFound
+        }
+
+        now := gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}
+        for _, o := range r.s.orders {
+            if o.UserID == id && !o.DeletedAt.Valid {
+                r.s.setOrderDeleted(o, now, undo)
+            }
+        }
+        r.s.setUserDeleted(user, now, undo)
+        return nil
+    })
+}
+
+// Restore undoes a soft delete of the user and the orders deleted along with it.
+func (r *memoryUserRepo) Restore(ctx context.Context, id uint) (*models.User, error) {
+    var user models.User
+    err := r.s.update(ctx, func(undo func(func())) error {
+        u, ok := r.s.users[id]
+        if !ok || !u.DeletedAt.Valid {
+            return gorm.ErrRecordNotFound
+        }
+        for _, o := range r.s.orders {
+            if o.UserID == id && o.DeletedAt.Valid && o.DeletedAt.Time.Equal(u.DeletedAt.Time) {
+                r.s.setOrderDeleted(o, gorm.DeletedAt{}, undo)
+            }
+        }
+        r.s.setUserDeleted(u, gorm.DeletedAt{}, undo)
+        user = r.s.users[id]
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+// PurgeDeleted permanently removes users soft-deleted before the given time,
+// along with all of their orders, and returns the removed user IDs.
+func (r *memoryUserRepo) PurgeDeleted(ctx context.Context, before time.Time) ([]uint, error) {
+    var ids []uint
+    err := r.s.update(ctx, func(undo func(func())) error {
+        for _, u := range r.s.users {
+            if u.DeletedAt.Valid && u.DeletedAt.Time.Before(before) {
+                ids = append(ids, u.ID)
+            }
+        }
+        sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+        for _, id := range ids {
+            for _, o := range r.s.orders {
+                if o.UserID == id {
+                    delete(r.s.orders, o.ID)
+                    undo(func() { r.s.orders[o.ID] = o })
+                }
+            }
+            u := r.s.users[id]
+            delete(r.s.users, id)
+            undo(func() { r.s.users[u.ID] = u })
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return ids, nil
+}
+
+// setUserDeleted stores the user with the given deleted_at. The store must be
+// locked for writing.
+func (s *MemoryStore) setUserDeleted(u models.User, deletedAt gorm.DeletedAt, undo func(func())) {
+    updated := u
+    updated.DeletedAt = deletedAt
+    s.users[u.ID] = updated
+    undo(func() { s.users[u.ID] = u })
+}
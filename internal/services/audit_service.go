@@ -0,0 +1,195 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "strings"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// auditVerifyBatch is how many entries are loaded at once while verifying the chain.
+const auditVerifyBatch = 500
+
+// auditRedacted replaces the values of fields tagged audit:"redact" in diffs.
+const auditRedacted = "[redacted]"
+
+// AuditService records and queries the audit log.
+type AuditService interface {
+    Record(ctx context.Context, action, resourceType string, resourceID uint, before, after interface{}) error
+    List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, int, error)
+    Verify(ctx context.Context) (models.AuditVerifyResult, error)
+}
+
+type auditService struct {
+    repo repository.AuditRepository
+}
+
+// NewAuditService constructs AuditService.
+func NewAuditService(r repository.AuditRepository) AuditService {
+    return &auditService{repo: r}
+}
+
+// Record stores who did what to which resource. Actor, request ID and IP
+// are taken from the context; before/after are diffed field by field.
+func (s *auditService) Record(ctx context.Context, action, resourceType string, resourceID uint, before, after interface{}) error {
+    diff, err := auditDiff(before, after)
+    if err != nil {
+        return fmt.Errorf("failed to build audit diff: %w", err)
+    }
+
+    entry := &models.AuditEntry{
+        Action:       action,
+        ResourceType: resourceType,
+        ResourceID:   resourceID,
+        Diff:         diff,
+        RequestID:    utils.RequestIDFromContext(ctx),
+        IP:           utils.ClientIPFromContext(ctx),
+        // Postgres keeps microseconds, so the hash must not depend on more
+        CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+    }
+    if actor, ok := utils.ActorFromContext(ctx); ok {
+        id := actor.ID
+        entry.ActorID = &id
+    }
+
+    if err := s.repo.Append(ctx, entry); err != nil {
+        return fmt.Errorf("failed to write audit log: %w", err)
+    }
+    return nil
+}
+
+func (s *auditService) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, int, error) {
+    return s.repo.List(ctx, filter)
+}
+
+// Verify walks the whole chain and reports the first entry that does not match.
+func (s *auditService) Verify(ctx context.Context) (models.AuditVerifyResult, error) {
+    res := models.AuditVerifyResult{Valid: true}
+    prevHash := ""
+    var lastID uint
+
+    for {
+        entries, err := s.repo.ListAfter(ctx, lastID, auditVerifyBatch)
+        if err != nil {
+            return res, err
+        }
+        for i := range entries {
+            e := &entries[i]
+            if e.PrevHash != prevHash || e.Hash != e.ComputeHash() {
+                id := e.ID
+                res.Valid = false
+                res.BrokenAt = &id
+                return res, nil
+            }
+            prevHash = e.Hash
+            lastID = e.ID
+            res.Checked++
+        }
+        if len(entries) < auditVerifyBatch {
+            return res, nil
+        }
+    }
+}
+
+// auditDiff returns a JSON object of changed fields: {"field": {"from": x, "to": y}}.
+// A nil before (create) or after (delete) reports every field. Values of
+// fields tagged audit:"redact" are replaced with auditRedacted, so the log
+// holds no personal data that erasure could not reach.
+func auditDiff(before, after interface{}) (string, error) {
+    from, err := toFieldMap(before)
+    if err != nil {
+        return "", err
+    }
+    to, err := toFieldMap(after)
+    if err != nil {
+        return "", err
+    }
+
+    type change struct {
+        From interface{} `json:"from"`
+        To   interface{} `json:"to"`
+    }
+    changes := make(map[string]change)
+    for k, v := range from {
+        if nv, ok := to[k]; !ok || !reflect.DeepEqual(v, nv) {
+            changes[k] = change{From: v, To: to[k]}
+        }
+    }
+    for k, v := range to {
+        if _, ok := from[k]; !ok {
+            changes[k] = change{To: v}
+        }
+    }
+    for k := range redactedFields(before, after) {
+        if c, ok := changes[k]; ok {
+            changes[k] = change{From: redact(c.From), To: redact(c.To)}
+        }
+    }
+
+    b, err := json.Marshal(changes)
+    if err != nil {
+        return "", err
+    }
+    return string(b), nil
+}
+
+// redactedFields returns the JSON names of the fields tagged audit:"redact"
+// in the types of values.
+func redactedFields(values ...interface{}) map[string]bool {
+    names := make(map[string]bool)
+    for _, m := range values {
+        if m == nil {
+            continue
+        }
+        t := reflect.TypeOf(m)
+        for t.Kind() == reflect.Ptr {
+            t = t.Elem()
+        }
+        if t.Kind() != reflect.Struct {
+            continue
+        }
+        for i := 0; i < t.NumField(); i++ {
+            f := t.Field(i)
+            if f.Tag.Get("audit") != "redact" {
+                continue
+            }
+            name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+            if name == "" {
+                name = f.Name
+            }
+            names[name] = true
+        }
+    }
+    return names
+}
+
+// redact hides a value, keeping nil (no value) as is.
+func redact(v interface{}) interface{} {
+    if v == nil {
+        return nil
+    }
+    return auditRedacted
+}
+
+// toFieldMap converts a model into its JSON field map, so fields hidden
+// from the API (like password hashes) never end up in the log.
+func toFieldMap(v interface{}) (map[string]interface{}, error) {
+    if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+        return map[string]interface{}{}, nil
+    }
+    b, err := json.Marshal(v)
+    if err != nil {
+        return nil, err
+    }
+    m := make(map[string]interface{})
+    if err := json.Unmarshal(b, &m); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
@@ -3,14 +3,15 @@ package services
 import (
     "context"
     "errors"
-    "os"
     "time"
 
     "github.com/golang-jwt/jwt/v5"
     "golang.org/x/crypto/bcrypt"
 
+    "github.com/PhosFactum/kvant-backend-practicum/internal/metrics"
     "github.com/PhosFactum/kvant-backend-practicum/internal/models"
     "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/tracing"
 )
 
 var (
@@ -30,34 +31,37 @@ func IsAuthError(err error) bool {
 // authService is AuthService implementation.
 type authService struct {
     userRepo repository.UserRepository
+    secret   string
 }
 
-// NewAuthService constructs AuthService.
-func NewAuthService(userRepo repository.UserRepository) AuthService {
-    return &authService{userRepo: userRepo}
+// NewAuthService constructs AuthService. Tokens are signed with secret.
+func NewAuthService(userRepo repository.UserRepository, secret string) AuthService {
+    return &authService{userRepo: userRepo, secret: secret}
 }
 
 // Login implements password check and JWT creation.
 func (s *authService) Login(ctx context.Context, input models.LoginInput) (string, error) {
     user, err := s.userRepo.FindByEmail(ctx, input.Email)
     if err != nil {
+        metrics.LoginAttempted(false)
         return "", ErrAuthInvalidCredentials
     }
-    if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)) != nil {
+    _, span := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+    err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password))
+    span.End()
+    if err != nil {
+        metrics.LoginAttempted(false)
         return "", ErrAuthInvalidCredentials
     }
-
-    secret := os.Getenv("JWT_SECRET")
-    if secret == "" {
-        secret = "supersecret"
-    }
+    metrics.LoginAttempted(true)
 
     claims := jwt.MapClaims{
         "user_id": user.ID,
+        "role":    user.Role,
         "exp":     time.Now().Add(24 * time.Hour).Unix(),
     }
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    signed, err := token.SignedString([]byte(secret))
+    signed, err := token.SignedString([]byte(s.secret))
     if err != nil {
         return "", err
     }
@@ -0,0 +1,35 @@
+package services
+
+import (
+    "errors"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+)
+
+// Errors of database operations cut short by the caller's context (the client
+// went away) or by their deadline. They are passed through unchanged so that
+// handlers can tell them apart from not-found and internal errors.
+var (
+    ErrCanceled = repository.ErrCanceled
+    ErrTimeout  = repository.ErrTimeout
+)
+
+// IsCanceled reports whether the operation was abandoned by the caller.
+func IsCanceled(err error) bool {
+    return errors.Is(err, ErrCanceled)
+}
+
+// IsTimeout reports whether the operation ran past its deadline.
+func IsTimeout(err error) bool {
+    return errors.Is(err, ErrTimeout)
+}
+
+// lookupError turns a lookup that found no record into notFound. Other
+// errors (the database is down, cancellation, timeouts) are passed through,
+// as they must not look like a missing record.
+func lookupError(err, notFound error) error {
+    if errors.Is(err, repository.ErrNotFound) {
+        return notFound
+    }
+    return err
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+)
+
+var (
+    ErrIdempotencyKeyReused  = errors.New("idempotency key was already used for a different request")
+    ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// idempotencyLockTimeout is how long an in-progress key blocks retries; after
+// that the first request is assumed lost (e.g. the process died) and a retry may run.
+const idempotencyLockTimeout = time.Minute
+
+// IdempotencyService makes retried requests safe: the first request with a key
+// runs and its response is stored, retries get that response back.
+type IdempotencyService interface {
+    // Begin reserves key for a request with the given hash. It returns the stored
+    // record if the request already completed and must be replayed, or nil if the
+    // caller should run the request and then call Complete or Abort.
+    Begin(ctx context.Context, userID uint, key, requestHash string) (*models.IdempotencyKey, error)
+    Complete(ctx context.Context, userID uint, key string, status int, body []byte) error
+    Abort(ctx context.Context, userID uint, key string) error
+    PurgeExpired(ctx context.Context) (int64, error)
+}
+
+type idempotencyService struct {
+    repo repository.IdempotencyRepository
+    ttl  time.Duration
+}
+
+// NewIdempotencyService constructs IdempotencyService. Keys expire ttl after first use.
+func NewIdempotencyService(r repository.IdempotencyRepository, ttl time.Duration) IdempotencyService {
+    return &idempotencyService{repo: r, ttl: ttl}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, userID uint, key, requestHash string) (*models.IdempotencyKey, error) {
+    now := time.Now().UTC()
+    rec := &models.IdempotencyKey{
+        UserID:      userID,
+        Key:         key,
+        RequestHash: requestHash,
+        Status:      models.IdempotencyInProgress,
+        CreatedAt:   now,
+        ExpiresAt:   now.Add(s.ttl),
+    }
+    acquired, err := s.repo.Acquire(ctx, rec, now.Add(-idempotencyLockTimeout))
+    if err != nil {
+        return nil, err
+    }
+    if acquired {
+        return nil, nil
+    }
+
+    existing, err := s.repo.Get(ctx, userID, key)
+    if err != nil {
+        return nil, err
+    }
+    switch {
+    case existing.RequestHash != requestHash:
+        return nil, ErrIdempotencyKeyReused
+    case existing.Status != models.IdempotencyCompleted:
+        return nil, ErrIdempotencyInProgress
+    }
+    return existing, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, userID uint, key string, status int, body []byte) error {
+    return s.repo.Complete(ctx, &models.IdempotencyKey{
+        UserID:       userID,
+        Key:          key,
+        ResponseCode: status,
+        ResponseBody: string(body),
+    })
+}
+
+// Abort frees the key so that a retry runs the request again.
+func (s *idempotencyService) Abort(ctx context.Context, userID uint, key string) error {
+    return s.repo.Release(ctx, userID, key)
+}
+
+// PurgeExpired deletes keys older than the configured window.
+func (s *idempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+    return s.repo.DeleteExpired(ctx, time.Now().UTC())
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+    "bytes"
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin/binding"
+    "github.com/go-playground/validator/v10"
+    "golang.org/x/crypto/bcrypt"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/metrics"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// Supported import file formats
+const (
+    ImportFormatCSV  = "csv"
+    ImportFormatXLSX = "xlsx"
+)
+
+// maxImportRows caps the number of data rows in one import file.
+const maxImportRows = 10000
+
+// importColumns are the required header columns of an import file.
+var importColumns = []string{"name", "email", "age", "password"}
+
+// ErrInvalidImportFile is returned when an upload cannot be parsed as an import file.
+var ErrInvalidImportFile = errors.New("invalid import file")
+
+// IsInvalidImport helps handlers map unreadable import files.
+func IsInvalidImport(err error) bool {
+    return errors.Is(err, ErrInvalidImportFile)
+}
+
+// ImportOptions controls how a bulk import is applied.
+type ImportOptions struct {
+    Mode   string // models.ImportModeAtomic (default) or models.ImportModeBestEffort
+    DryRun bool   // validate only, create nothing
+}
+
+// ImportService runs bulk imports of users in the background.
+type ImportService interface {
+    StartUserImport(ctx context.Context, format string, data []byte, opts ImportOptions) (*models.Job, error)
+    GetImport(ctx context.Context, id string) (*models.ImportJob, error)
+}
+
+type importService struct {
+    userRepo repository.UserRepository
+    jobs     *jobRunner
+    audit    AuditService
+    tx       repository.TxManager
+}
+
+// NewImportService constructs ImportService.
+func NewImportService(u repository.UserRepository, j repository.JobRepository, audit AuditService, tx repository.TxManager) ImportService {
+    return &importService{userRepo: u, jobs: &jobRunner{repo: j}, audit: audit, tx: tx}
+}
+
+// importRow is a data row of an import file, kept as text until validation.
+type importRow struct {
+    line     int
+    name     string
+    email    string
+    age      string
+    password string
+}
+
+// StartUserImport parses the file right away, so malformed uploads are rejected
+// synchronously, and validates and stores the rows in a background job.
+func (s *importService) StartUserImport(ctx context.Context, format string, data []byte, opts ImportOptions) (*models.Job, error) {
+    switch opts.Mode {
+    case "":
+        opts.Mode = models.ImportModeAtomic
+    case models.ImportModeAtomic, models.ImportModeBestEffort:
+    default:
+        return nil, fmt.Errorf("%w: unknown import mode %q", ErrInvalidRequest, opts.Mode)
+    }
+
+    rows, err := parseImportFile(format, data)
+    if err != nil {
+        return nil, err
+    }
+
+    return s.jobs.start(ctx, models.JobTypeUserImport, 0, func(ctx context.Context, job *models.Job) error {
+        return s.runImport(ctx, job, rows, opts)
+    })
+}
+
+func (s *importService) GetImport(ctx context.Context, id string) (*models.ImportJob, error) {
+    job, err := s.jobs.get(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if job.Type != models.JobTypeUserImport {
+        return nil, ErrJobNotFound
+    }
+    result := &models.ImportJob{Job: *job}
+    if job.Report != "" {
+        var report models.ImportReport
+        if err := json.Unmarshal([]byte(job.Report), &report); err != nil {
+            return nil, fmt.Errorf("failed to decode import report: %w", err)
+        }
+        result.Report = &report
+    }
+    return result, nil
+}
+
+// runImport validates every row and creates the users according to the mode.
+// The report is attached to the job whatever the outcome.
+func (s *importService) runImport(ctx context.Context, job *models.Job, rows []importRow, opts ImportOptions) error {
+    report := models.ImportReport{
+        Mode:   opts.Mode,
+        DryRun: opts.DryRun,
+        Total:  len(rows),
+        Errors: []models.ImportRowError{},
+    }
+    defer func() {
+        encoded, _ := json.Marshal(report)
+        job.Report = string(encoded)
+    }()
+
+    // 1) Проверка всех строк
+    type validRow struct {
+        line  int
+        input models.CreateUserInput
+    }
+    valid := make([]validRow, 0, len(rows))
+    seen := make(map[string]int, len(rows))
+    for _, row := range rows {
+        input, err := s.validateRow(ctx, row)
+        if err == nil {
+            key := strings.ToLower(input.Email)
+            if first, dup := seen[key]; dup {
+                err = fmt.Errorf("duplicate email, first seen on line %d", first)
+            } else {
+                seen[key] = row.line
+            }
+        }
+        if err != nil {
+            report.Errors = append(report.Errors, models.ImportRowError{Line: row.line, Email: row.email, Error: err.Error()})
+            continue
+        }
+        valid = append(valid, validRow{line: row.line, input: input})
+    }
+    report.Valid = len(valid)
+
+    if opts.DryRun {
+        return nil
+    }
+    if opts.Mode == models.ImportModeAtomic && len(report.Errors) > 0 {
+        return fmt.Errorf("%d of %d rows are invalid, nothing was imported", len(report.Errors), report.Total)
+    }
+
+    // 2) Подготовка пользователей
+    users := make([]*models.User, 0, len(valid))
+    for _, v := range valid {
+        pwHash, err := bcrypt.GenerateFromPassword([]byte(v.input.Password), bcrypt.DefaultCost)
+        if err != nil {
+            return err
+        }
+        users = append(users, &models.User{
+            Name:         v.input.Name,
+            Email:        v.input.Email,
+            Age:          v.input.Age,
+            Version:      1,
+            PasswordHash: string(pwHash),
+        })
+    }
+
+    // 3) Сохранение
+    if opts.Mode == models.ImportModeAtomic {
+        // The users and their audit entries are stored together or not at all
+        err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+            if err := s.userRepo.CreateBatch(ctx, users); err != nil {
+                return fmt.Errorf("failed to import users: %w", err)
+            }
+            return s.recordCreated(ctx, users)
+        })
+        if err != nil {
+            return err
+        }
+        report.Created = len(users)
+        return nil
+    }
+
+    // In best-effort mode each row is its own transaction, so a failed row
+    // leaves the others stored
+    for i, user := range users {
+        err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+            if err := s.userRepo.Create(ctx, user); err != nil {
+                return err
+            }
+            return s.recordCreated(ctx, []*models.User{user})
+        })
+        if err != nil {
+            report.Errors = append(report.Errors, models.ImportRowError{Line: valid[i].line, Email: user.Email, Error: err.Error()})
+            continue
+        }
+        report.Created++
+    }
+    return nil
+}
+
+// recordCreated writes the audit entries of the imported users in the
+// transaction of ctx and counts them once it commits.
+func (s *importService) recordCreated(ctx context.Context, users []*models.User) error {
+    for _, user := range users {
+        if err := s.audit.Record(ctx, models.AuditActionCreate, models.AuditResourceUser, user.ID, nil, user); err != nil {
+            return err
+        }
+    }
+    repository.AfterCommit(ctx, func() { metrics.UsersCreated(len(users)) })
+    return nil
+}
+
+// validateRow applies the same rules as user registration (CreateUserInput)
+// and checks the email is not taken yet.
+func (s *importService) validateRow(ctx context.Context, row importRow) (models.CreateUserInput, error) {
+    input := models.CreateUserInput{
+        Name:     row.name,
+        Email:    row.email,
+        Password: row.password,
+    }
+    if row.age != "" {
+        age, err := strconv.Atoi(row.age)
+        if err != nil {
+            return input, errors.New("age must be an integer")
+        }
+        input.Age = age
+    }
+
+    if err := validateInput(&input); err != nil {
+        return input, err
+    }
+
+    taken, err := s.userRepo.EmailTaken(ctx, input.Email)
+    if err != nil {
+        return input, err
+    }
+    if taken {
+        return input, ErrEmailTaken
+    }
+    return input, nil
+}
+
+// validateInput checks the binding tags of a request struct, as gin does when
+// binding JSON, and returns a short readable message.
+func validateInput(v interface{}) error {
+    err := binding.Validator.ValidateStruct(v)
+    var verrs validator.ValidationErrors
+    if err == nil || !errors.As(err, &verrs) {
+        return err
+    }
+    msgs := make([]string, 0, len(verrs))
+    for _, fe := range verrs {
+        msgs = append(msgs, fieldErrorMessage(fe))
+    }
+    return errors.New(strings.Join(msgs, "; "))
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+    field := strings.ToLower(fe.Field())
+    switch fe.Tag() {
+    case "required":
+        return field + " is required"
+    case "email":
+        return field + " must be a valid email"
+    case "min":
+        return field + " must be at least " + fe.Param()
+    case "max":
+        return field + " must be at most " + fe.Param()
+    default:
+        return fmt.Sprintf("%s failed the %q rule", field, fe.Tag())
+    }
+}
+
+// parseImportFile reads the header and data rows of a CSV or XLSX file.
+func parseImportFile(format string, data []byte) ([]importRow, error) {
+    var table []utils.Row
+    switch format {
+    case ImportFormatCSV:
+        rows, err := readCSV(data)
+        if err != nil {
+            return nil, err
+        }
+        table = rows
+    case ImportFormatXLSX:
+        rows, err := utils.ReadXLSX(bytes.NewReader(data), int64(len(data)))
+        if err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+        }
+        table = rows
+    default:
+        return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImportFile, format)
+    }
+
+    if len(table) == 0 {
+        return nil, fmt.Errorf("%w: file is empty", ErrInvalidImportFile)
+    }
+
+    // Колонки сопоставляются по заголовку, порядок не важен
+    index := make(map[string]int)
+    for i, name := range table[0].Cells {
+        name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+        index[name] = i
+    }
+    for _, col := range importColumns {
+        if _, ok := index[col]; !ok {
+            return nil, fmt.Errorf("%w: missing column %q", ErrInvalidImportFile, col)
+        }
+    }
+
+    cell := func(r utils.Row, col string) string {
+        i := index[col]
+        if i >= len(r.Cells) {
+            return ""
+        }
+        return strings.TrimSpace(r.Cells[i])
+    }
+
+    rows := make([]importRow, 0, len(table)-1)
+    for _, r := range table[1:] {
+        row := importRow{
+            line:     r.Line,
+            name:     cell(r, "name"),
+            email:    cell(r, "email"),
+            age:      cell(r, "age"),
+            password: cell(r, "password"),
+        }
+        if row.name == "" && row.email == "" && row.age == "" && row.password == "" {
+            continue
+        }
+        rows = append(rows, row)
+    }
+    if len(rows) == 0 {
+        return nil, fmt.Errorf("%w: file has no data rows", ErrInvalidImportFile)
+    }
+    if len(rows) > maxImportRows {
+        return nil, fmt.Errorf("%w: at most %d rows per file", ErrInvalidImportFile, maxImportRows)
+    }
+    return rows, nil
+}
+
+func readCSV(data []byte) ([]utils.Row, error) {
+    r := csv.NewReader(bytes.NewReader(data))
+    r.FieldsPerRecord = -1
+    var rows []utils.Row
+    for {
+        record, err := r.Read()
+        if err == io.EOF {
+            return rows, nil
+        }
+        if err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+        }
+        line, _ := r.FieldPos(0)
+        rows = append(rows, utils.Row{Line: line, Cells: record})
+    }
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// jobFunc does the work of a background job. It may fill in job fields
+// (result path, report); returning an error marks the job failed.
+type jobFunc func(ctx context.Context, job *models.Job) error
+
+// jobRunner stores jobs and executes them in the background.
+type jobRunner struct {
+    repo repository.JobRepository
+}
+
+// start stores a pending job and runs it in the background.
+func (r *jobRunner) start(ctx context.Context, jobType string, subjectID uint, run jobFunc) (*models.Job, error) {
+    job := &models.Job{
+        ID:        utils.NewID(),
+        Type:      jobType,
+        Status:    models.JobStatusPending,
+        SubjectID: subjectID,
+    }
+    if actor, ok := utils.ActorFromContext(ctx); ok {
+        id := actor.ID
+        job.RequestedBy = &id
+    }
+    if err := r.repo.Create(ctx, job); err != nil {
+        return nil, fmt.Errorf("failed to create job: %w", err)
+    }
+
+    // The request context is cancelled once the response is sent,
+    // but the job must keep its values (actor, request ID) for auditing.
+    queued := *job
+    utils.Async(repository.DetachTx(ctx), "job_"+jobType, func(ctx context.Context) error {
+        return r.execute(ctx, &queued, run)
+    })
+    return job, nil
+}
+
+// execute runs the job and stores its outcome. It returns the error of run.
+func (r *jobRunner) execute(ctx context.Context, job *models.Job, run jobFunc) error {
+    job.Status = models.JobStatusRunning
+    if err := r.repo.Update(ctx, job); err != nil {
+        log.Printf("job %s: failed to mark running: %v", job.ID, err)
+    }
+
+    err := run(ctx, job)
+
+    now := time.Now().UTC()
+    job.FinishedAt = &now
+    job.Status = models.JobStatusDone
+    if err != nil {
+        job.Status = models.JobStatusFailed
+        job.Error = err.Error()
+    }
+    if err := r.repo.Update(ctx, job); err != nil {
+        log.Printf("job %s: failed to store result: %v", job.ID, err)
+    }
+    return err
+}
+
+func (r *jobRunner) get(ctx context.Context, id string) (*models.Job, error) {
+    job, err := r.repo.GetByID(ctx, id)
+    if err != nil {
+        return nil, lookupError(err, ErrJobNotFound)
+    }
+    return job, nil
+}
@@ -5,25 +5,33 @@ import (
     "errors"
     "fmt"
 
+    "github.com/PhosFactum/kvant-backend-practicum/internal/metrics"
     "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
     "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
 )
 
 var (
-    ErrUserNotFound   = errors.New("user not found")
-    ErrInvalidRequest = errors.New("invalid request data")
+    ErrUserNotFound     = errors.New("user not found")
+    ErrOrderNotFound    = errors.New("order not found")
+    ErrOrderNotEditable = errors.New("order can no longer be changed")
+    ErrInvalidRequest   = errors.New("invalid request data")
 )
 
 // OrderService describes use-cases around orders.
 type OrderService interface {
     Create(ctx context.Context, userID uint, req models.OrderRequest) (models.Order, error)
-    ListByUser(ctx context.Context, userID uint) ([]models.Order, error)
+    CreateBatch(ctx context.Context, userID uint, req models.OrderBatchRequest) (models.OrderBatchResult, error)
+    ListByUser(ctx context.Context, userID uint, spec query.Spec) ([]models.Order, query.PageInfo, *models.OrderSummary, error)
+    GetByID(ctx context.Context, userID, orderID uint) (*models.Order, error)
+    Update(ctx context.Context, userID, orderID uint, input models.UpdateOrderInput) (*models.Order, error)
+    Delete(ctx context.Context, userID, orderID uint) error
     NotifyOrderCreated(ctx context.Context, order *models.Order) error
 }
 
 // IsNotFound helps handler map not-found errors.
 func IsNotFound(err error) bool {
-    return errors.Is(err, ErrUserNotFound)
+    return errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrOrderNotFound)
 }
 
 // IsValidation helps handler map validation errors.
@@ -34,46 +42,220 @@ func IsValidation(err error) bool {
 type orderService struct {
     userRepo  repository.UserRepository
     orderRepo repository.OrderRepository
+    audit     AuditService
+    tx        repository.TxManager
 }
 
 // NewOrderService constructs OrderService.
-func NewOrderService(u repository.UserRepository, o repository.OrderRepository) OrderService {
-    return &orderService{userRepo: u, orderRepo: o}
+func NewOrderService(u repository.UserRepository, o repository.OrderRepository, audit AuditService, tx repository.TxManager) OrderService {
+    return &orderService{userRepo: u, orderRepo: o, audit: audit, tx: tx}
 }
 
+// Create stores the order and its audit entry in one transaction, so the user
+// check and the insert see the same state.
 func (s *orderService) Create(ctx context.Context, userID uint, req models.OrderRequest) (models.Order, error) {
-    // 1) Проверка, что пользователь существует
-    _, err := s.userRepo.GetByID(ctx, userID)
+    var order models.Order
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        // 1) Проверка, что пользователь существует
+        if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+            return lookupError(err, ErrUserNotFound)
+        }
+
+        // 2) Простейшая валидация полей
+        if req.Quantity < 1 || req.Price < 0 {
+            return ErrInvalidRequest
+        }
+
+        // 3) Создание и сохранение
+        order = models.Order{
+            UserID:   userID,
+            Product:  req.Product,
+            Quantity: req.Quantity,
+            Price:    req.Price,
+            Status:   models.OrderStatusPending,
+        }
+        if err := s.orderRepo.Create(ctx, &order); err != nil {
+            return fmt.Errorf("failed to create order: %w", err)
+        }
+        return s.audit.Record(ctx, models.AuditActionCreate, models.AuditResourceOrder, order.ID, nil, &order)
+    })
     if err != nil {
-        return models.Order{}, ErrUserNotFound
+        return models.Order{}, err
+    }
+    repository.AfterCommit(ctx, func() { metrics.OrderCreated(order.Price * float64(order.Quantity)) })
+    return order, nil
+}
+
+// CreateBatch validates every item and stores the valid ones with one insert.
+// In atomic mode (the default) nothing is stored if any item is invalid.
+func (s *orderService) CreateBatch(ctx context.Context, userID uint, req models.OrderBatchRequest) (models.OrderBatchResult, error) {
+    result := models.OrderBatchResult{Atomic: req.Atomic == nil || *req.Atomic}
+    if len(req.Orders) > models.MaxOrderBatchSize {
+        return result, fmt.Errorf("%w: at most %d orders per batch", ErrInvalidRequest, models.MaxOrderBatchSize)
     }
 
-    // 2) Простейшая валидация полей
-    if req.Quantity < 1 || req.Price < 0 {
-        return models.Order{}, ErrInvalidRequest
+    // 1) Валидация каждой позиции
+    result.Items = make([]models.OrderBatchItem, len(req.Orders))
+    var valid []*models.Order
+    var validIdx []int
+    for i, item := range req.Orders {
+        result.Items[i].Index = i
+        if err := validateInput(&item); err != nil {
+            result.Items[i].Error = err.Error()
+            result.Failed++
+            continue
+        }
+        valid = append(valid, &models.Order{
+            UserID:   userID,
+            Product:  item.Product,
+            Quantity: item.Quantity,
+            Price:    item.Price,
+            Status:   models.OrderStatusPending,
+        })
+        validIdx = append(validIdx, i)
     }
+    store := len(valid) > 0 && !(result.Atomic && result.Failed > 0)
 
-    // 3) Создание и сохранение
-    order := models.Order{
-        UserID:   userID,
-        Product:  req.Product,
-        Quantity: req.Quantity,
-        Price:    req.Price,
+    // 2) Одна вставка на все корректные позиции, вместе с записями аудита
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        // The user is checked in the transaction, as in Create
+        if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+            return lookupError(err, ErrUserNotFound)
+        }
+        if !store {
+            return nil
+        }
+        if err := s.orderRepo.CreateBatch(ctx, valid); err != nil {
+            return fmt.Errorf("failed to create orders: %w", err)
+        }
+        for _, order := range valid {
+            if err := s.audit.Record(ctx, models.AuditActionCreate, models.AuditResourceOrder, order.ID, nil, order); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil || !store {
+        return result, err
     }
-    if err := s.orderRepo.Create(ctx, &order); err != nil {
-        return models.Order{}, fmt.Errorf("failed to create order: %w", err)
+    for n, order := range valid {
+        result.Items[validIdx[n]].Order = order
     }
-    return order, nil
+    result.Created = len(valid)
+    repository.AfterCommit(ctx, func() {
+        for _, order := range valid {
+            metrics.OrderCreated(order.Price * float64(order.Quantity))
+        }
+    })
+    return result, nil
 }
 
-func (s *orderService) ListByUser(ctx context.Context, userID uint) ([]models.Order, error) {
+// ListByUser returns a page of the user's orders. Unless counting is skipped it
+// also returns aggregates over all matching orders, whose count serves as the total.
+func (s *orderService) ListByUser(ctx context.Context, userID uint, spec query.Spec) ([]models.Order, query.PageInfo, *models.OrderSummary, error) {
     // 1) Убедиться, что пользователь есть
     _, err := s.userRepo.GetByID(ctx, userID)
     if err != nil {
-        return nil, ErrUserNotFound
+        return nil, query.PageInfo{}, nil, lookupError(err, ErrUserNotFound)
+    }
+
+    // 2) Страница заказов; total берём из агрегатов, чтобы не считать дважды
+    counted := !spec.SkipCount
+    spec.SkipCount = true
+    orders, info, err := s.orderRepo.ListByUser(ctx, userID, spec)
+    if err != nil || !counted {
+        return orders, info, nil, err
+    }
+
+    // 3) Агрегаты по всем подходящим заказам
+    summary, err := s.orderRepo.SummarizeByUser(ctx, userID, spec)
+    if err != nil {
+        return nil, query.PageInfo{}, nil, err
+    }
+    info.Total = &summary.Count
+    return orders, info, &summary, nil
+}
+
+// GetByID returns the order if it belongs to the given user.
+func (s *orderService) GetByID(ctx context.Context, userID, orderID uint) (*models.Order, error) {
+    order, err := s.orderRepo.GetByID(ctx, orderID)
+    if err != nil {
+        return nil, lookupError(err, ErrOrderNotFound)
+    }
+    if order.UserID != userID {
+        return nil, ErrOrderNotFound
+    }
+    return order, nil
+}
+
+// Update changes the provided fields while the order is still pending, in one
+// transaction with the audit entry.
+func (s *orderService) Update(ctx context.Context, userID, orderID uint, input models.UpdateOrderInput) (*models.Order, error) {
+    var updated *models.Order
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        var err error
+        updated, err = s.update(ctx, userID, orderID, input)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return updated, nil
+}
+
+func (s *orderService) update(ctx context.Context, userID, orderID uint, input models.UpdateOrderInput) (*models.Order, error) {
+    order, err := s.GetByID(ctx, userID, orderID)
+    if err != nil {
+        return nil, err
+    }
+    if order.Status != models.OrderStatusPending {
+        return nil, ErrOrderNotEditable
+    }
+    if (input.Product != nil && *input.Product == "") ||
+        (input.Quantity != nil && *input.Quantity < 1) ||
+        (input.Price != nil && *input.Price < 0) {
+        return nil, ErrInvalidRequest
+    }
+    before := *order
+
+    if input.Product != nil {
+        order.Product = *input.Product
+    }
+    if input.Quantity != nil {
+        order.Quantity = *input.Quantity
+    }
+    if input.Price != nil {
+        order.Price = *input.Price
+    }
+    if before == *order {
+        return order, nil
+    }
+
+    if err := s.orderRepo.Update(ctx, order, models.OrderStatusPending); err != nil {
+        if errors.Is(err, repository.ErrStaleVersion) {
+            return nil, ErrOrderNotEditable
+        }
+        return nil, fmt.Errorf("failed to update order: %w", err)
+    }
+    if err := s.audit.Record(ctx, models.AuditActionUpdate, models.AuditResourceOrder, order.ID, &before, order); err != nil {
+        return nil, err
     }
-    // 2) Вернуть заказы
-    return s.orderRepo.ListByUser(ctx, userID)
+    return order, nil
+}
+
+// Delete soft-deletes the order if it belongs to the given user, in one
+// transaction with the audit entry.
+func (s *orderService) Delete(ctx context.Context, userID, orderID uint) error {
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        order, err := s.GetByID(ctx, userID, orderID)
+        if err != nil {
+            return err
+        }
+        if err := s.orderRepo.Delete(ctx, order.ID); err != nil {
+            return fmt.Errorf("failed to delete order: %w", err)
+        }
+        return s.audit.Record(ctx, models.AuditActionDelete, models.AuditResourceOrder, order.ID, order, nil)
+    })
 }
 
 // NotifyOrderCreated simulates sending a notification about the new order.
@@ -0,0 +1,253 @@
+package services
+
+import (
+    "archive/zip"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+)
+
+var (
+    ErrJobNotFound    = errors.New("job not found")
+    ErrJobNotFinished = errors.New("job has not finished yet")
+    ErrExportExpired  = errors.New("export archive is no longer available")
+)
+
+// exportPageSize is the page size used to collect rows for an export.
+const exportPageSize = 500
+
+// PrivacyService handles data-subject requests: personal data export and erasure.
+// Both run in the background; callers poll the returned job.
+type PrivacyService interface {
+    StartExport(ctx context.Context, userID uint) (*models.Job, error)
+    StartErasure(ctx context.Context, userID uint) (*models.Job, error)
+    GetJob(ctx context.Context, id string) (*models.Job, error)
+    ExportFile(ctx context.Context, id string) (string, error)
+    // ExpireExports deletes export archives finished longer than retention ago
+    // and returns how many were deleted.
+    ExpireExports(ctx context.Context, retention time.Duration) (int, error)
+}
+
+// IsJobNotFound helps handlers map unknown job IDs.
+func IsJobNotFound(err error) bool {
+    return errors.Is(err, ErrJobNotFound)
+}
+
+type privacyService struct {
+    userRepo  repository.UserRepository
+    orderRepo repository.OrderRepository
+    jobs      *jobRunner
+    audit     AuditService
+    exportDir string
+}
+
+// NewPrivacyService constructs PrivacyService. Export archives are written to exportDir.
+func NewPrivacyService(
+    u repository.UserRepository,
+    o repository.OrderRepository,
+    j repository.JobRepository,
+    audit AuditService,
+    exportDir string,
+) PrivacyService {
+    return &privacyService{userRepo: u, orderRepo: o, jobs: &jobRunner{repo: j}, audit: audit, exportDir: exportDir}
+}
+
+func (s *privacyService) StartExport(ctx context.Context, userID uint) (*models.Job, error) {
+    return s.start(ctx, models.JobTypeExport, userID, s.runExport)
+}
+
+func (s *privacyService) StartErasure(ctx context.Context, userID uint) (*models.Job, error) {
+    return s.start(ctx, models.JobTypeErasure, userID, s.runErasure)
+}
+
+func (s *privacyService) GetJob(ctx context.Context, id string) (*models.Job, error) {
+    return s.jobs.get(ctx, id)
+}
+
+// ExportFile returns the path of a finished export archive.
+func (s *privacyService) ExportFile(ctx context.Context, id string) (string, error) {
+    job, err := s.GetJob(ctx, id)
+    if err != nil {
+        return "", err
+    }
+    if job.Type != models.JobTypeExport {
+        return "", ErrJobNotFound
+    }
+    if job.Status != models.JobStatusDone {
+        return "", ErrJobNotFinished
+    }
+    if job.ResultPath == "" {
+        return "", ErrExportExpired
+    }
+    return job.ResultPath, nil
+}
+
+func (s *privacyService) ExpireExports(ctx context.Context, retention time.Duration) (int, error) {
+    cutoff := time.Now().UTC().Add(-retention)
+    return s.deleteExports(ctx, models.JobFilter{FinishedBefore: &cutoff})
+}
+
+// deleteExports removes the archives of the export jobs matching f and
+// clears their download paths. It returns how many were deleted.
+func (s *privacyService) deleteExports(ctx context.Context, f models.JobFilter) (int, error) {
+    f.Type = models.JobTypeExport
+    f.HasResult = true
+    jobs, err := s.jobs.repo.List(ctx, f)
+    if err != nil {
+        return 0, err
+    }
+    for i, job := range jobs {
+        if err := os.Remove(job.ResultPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+            return i, err
+        }
+        job.ResultPath = ""
+        if err := s.jobs.repo.Update(ctx, &job); err != nil {
+            return i, err
+        }
+    }
+    return len(jobs), nil
+}
+
+// start checks the subject exists and queues the job.
+func (s *privacyService) start(ctx context.Context, jobType string, userID uint, run jobFunc) (*models.Job, error) {
+    if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+        return nil, lookupError(err, ErrUserNotFound)
+    }
+    return s.jobs.start(ctx, jobType, userID, run)
+}
+
+// runExport writes profile, orders and audit entries of the user as JSON inside a zip.
+// Authentication is stateless (JWT), so there is no session data to include.
+func (s *privacyService) runExport(ctx context.Context, job *models.Job) error {
+    user, err := s.userRepo.GetByID(ctx, job.SubjectID)
+    if err != nil {
+        return lookupError(err, ErrUserNotFound)
+    }
+    orders, err := s.collectOrders(ctx, user.ID)
+    if err != nil {
+        return fmt.Errorf("failed to load orders: %w", err)
+    }
+    entries, err := s.collectAudit(ctx, user.ID)
+    if err != nil {
+        return fmt.Errorf("failed to load audit entries: %w", err)
+    }
+
+    export := models.UserExport{
+        ExportedAt: time.Now().UTC(),
+        Profile:    *user,
+        Orders:     orders,
+        Audit:      entries,
+    }
+
+    if err := os.MkdirAll(s.exportDir, 0o700); err != nil {
+        return err
+    }
+    path := filepath.Join(s.exportDir, job.ID+".zip")
+    if err := writeExportArchive(path, export); err != nil {
+        os.Remove(path)
+        return err
+    }
+    job.ResultPath = path
+    return nil
+}
+
+func (s *privacyService) collectOrders(ctx context.Context, userID uint) ([]models.Order, error) {
+    all := []models.Order{}
+    spec := query.Spec{Page: 1, Limit: exportPageSize, SkipCount: true}
+    for {
+        orders, info, err := s.orderRepo.ListByUser(ctx, userID, spec)
+        if err != nil {
+            return nil, err
+        }
+        all = append(all, orders...)
+        if !info.HasNext {
+            return all, nil
+        }
+        cur, err := query.CursorAt(orders[len(orders)-1], spec, models.OrderQuerySchema)
+        if err != nil {
+            return nil, err
+        }
+        spec.Cursor = &cur
+    }
+}
+
+func (s *privacyService) collectAudit(ctx context.Context, userID uint) ([]models.AuditEntry, error) {
+    var all []models.AuditEntry
+    for page := 1; ; page++ {
+        entries, total, err := s.audit.List(ctx, models.AuditFilter{
+            SubjectID: userID,
+            Page:      page,
+            Limit:     exportPageSize,
+        })
+        if err != nil {
+            return nil, err
+        }
+        all = append(all, entries...)
+        if len(entries) == 0 || len(all) >= total {
+            return all, nil
+        }
+    }
+}
+
+func writeExportArchive(path string, export models.UserExport) error {
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    zw := zip.NewWriter(f)
+    files := map[string]interface{}{
+        "export.json":  export,
+        "profile.json": export.Profile,
+        "orders.json":  export.Orders,
+        "audit.json":   export.Audit,
+    }
+    for name, v := range files {
+        w, err := zw.Create(name)
+        if err != nil {
+            return err
+        }
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(v); err != nil {
+            return err
+        }
+    }
+    if err := zw.Close(); err != nil {
+        return err
+    }
+    return f.Close()
+}
+
+// runErasure anonymises the user's PII while keeping the row and its orders
+// for accounting, and deletes the user's export archives, which hold the same
+// PII. The audit entry deliberately carries no personal data.
+func (s *privacyService) runErasure(ctx context.Context, job *models.Job) error {
+    user, err := s.userRepo.GetByID(ctx, job.SubjectID)
+    if err != nil {
+        return lookupError(err, ErrUserNotFound)
+    }
+    if _, err := s.deleteExports(ctx, models.JobFilter{SubjectID: user.ID}); err != nil {
+        return fmt.Errorf("failed to delete exports: %w", err)
+    }
+
+    user.Name = "Erased user"
+    user.Email = fmt.Sprintf("erased-%d@erased.invalid", user.ID)
+    user.Age = 0
+    // An empty hash never matches in bcrypt, so the account can no longer log in
+    user.PasswordHash = ""
+
+    if err := s.userRepo.Update(ctx, user); err != nil {
+        return fmt.Errorf("failed to anonymise user: %w", err)
+    }
+    return s.audit.Record(ctx, models.AuditActionErase, models.AuditResourceUser, user.ID, nil, nil)
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+)
+
+// maxReportLimit caps top-N reports.
+const maxReportLimit = 100
+
+// ReportService describes admin reporting use-cases over all orders.
+type ReportService interface {
+    SearchOrders(ctx context.Context, spec query.Spec) ([]models.AdminOrder, query.PageInfo, error)
+    ExportOrders(ctx context.Context, spec query.Spec, fn func(models.AdminOrder) error) error
+    Revenue(ctx context.Context, period string, rng models.ReportRange) ([]models.RevenuePoint, error)
+    TopProducts(ctx context.Context, limit int, rng models.ReportRange) ([]models.ProductStat, error)
+    TopCustomers(ctx context.Context, limit int, rng models.ReportRange) ([]models.CustomerStat, error)
+    OrderValue(ctx context.Context, rng models.ReportRange) (models.OrderValueStats, error)
+}
+
+type reportService struct {
+    repo repository.ReportRepository
+}
+
+// NewReportService constructs ReportService.
+func NewReportService(r repository.ReportRepository) ReportService {
+    return &reportService{repo: r}
+}
+
+func (s *reportService) SearchOrders(ctx context.Context, spec query.Spec) ([]models.AdminOrder, query.PageInfo, error) {
+    return s.repo.SearchOrders(ctx, spec)
+}
+
+// ExportOrders streams every order matching spec to fn without paging.
+func (s *reportService) ExportOrders(ctx context.Context, spec query.Spec, fn func(models.AdminOrder) error) error {
+    return s.repo.StreamOrders(ctx, spec, fn)
+}
+
+func (s *reportService) Revenue(ctx context.Context, period string, rng models.ReportRange) ([]models.RevenuePoint, error) {
+    switch period {
+    case models.PeriodDay, models.PeriodWeek, models.PeriodMonth:
+    default:
+        return nil, fmt.Errorf("%w: period must be day, week or month", ErrInvalidRequest)
+    }
+    return s.repo.RevenueByPeriod(ctx, period, rng)
+}
+
+func (s *reportService) TopProducts(ctx context.Context, limit int, rng models.ReportRange) ([]models.ProductStat, error) {
+    if limit < 1 || limit > maxReportLimit {
+        return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRequest, maxReportLimit)
+    }
+    return s.repo.TopProducts(ctx, limit, rng)
+}
+
+func (s *reportService) TopCustomers(ctx context.Context, limit int, rng models.ReportRange) ([]models.CustomerStat, error) {
+    if limit < 1 || limit > maxReportLimit {
+        return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRequest, maxReportLimit)
+    }
+    return s.repo.TopCustomers(ctx, limit, rng)
+}
+
+func (s *reportService) OrderValue(ctx context.Context, rng models.ReportRange) (models.OrderValueStats, error) {
+    return s.repo.OrderValue(ctx, rng)
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+    "context"
+    "time"
+
+    "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/tracing"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
+)
+
+// The traced services run every method of the wrapped service in a span named
+// Service.Method. Calls a service makes to itself are not traced separately.
+
+type tracedUserService struct {
+    next UserService
+}
+
+// NewTracedUserService wraps next with tracing.
+func NewTracedUserService(next UserService) UserService {
+    return &tracedUserService{next: next}
+}
+
+func (s *tracedUserService) Create(ctx context.Context, input models.CreateUserInput) (_ *models.User, err error) {
+    ctx, span := tracing.Start(ctx, "UserService.Create")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Create(ctx, input)
+}
+
+func (s *tracedUserService) List(ctx context.Context, spec query.Spec) (_ []models.User, _ query.PageInfo, err error) {
+    ctx, span := tracing.Start(ctx, "UserService.List")
+    defer func() { tracing.End(span, err) }()
+    return s.next.List(ctx, spec)
+}
+
+func (s *tracedUserService) Export(ctx context.Context, spec query.Spec, fn func(models.User) error) (err error) {
+    ctx, span := tracing.Start(ctx, "UserService.Export")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Export(ctx, spec, fn)
+}
+
+func (s *tracedUserService) GetByID(ctx context.Context, id uint) (_ *models.User, err error) {
+    ctx, span := tracing.Start(ctx, "UserService.GetByID")
+    defer func() { tracing.End(span, err) }()
+    return s.next.GetByID(ctx, id)
+}
+
+func (s *tracedUserService) Update(ctx context.Context, id uint, match utils.IfMatch, input models.UpdateUserInput) (_ *models.User, err error) {
+    ctx, span := tracing.Start(ctx, "UserService.Update")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Update(ctx, id, match, input)
+}
+
+func (s *tracedUserService) Patch(ctx context.Context, id uint, match utils.IfMatch, format string, patch []byte) (_ *models.User, err error) {
+    ctx, span := tracing.Start(ctx, "UserService.Patch")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Patch(ctx, id, match, format, patch)
+}
+
+func (s *tracedUserService) Delete(ctx context.Context, id uint) (err error) {
+    ctx, span := tracing.Start(ctx, "UserService.Delete")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Delete(ctx, id)
+}
+
+func (s *tracedUserService) Restore(ctx context.Context, id uint) (_ *models.User, err error) {
+    ctx, span := tracing.Start(ctx, "UserService.Restore")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Restore(ctx, id)
+}
+
+func (s *tracedUserService) PurgeDeleted(ctx context.Context, retention time.Duration) (_ int, err error) {
+    ctx, span := tracing.Start(ctx, "UserService.PurgeDeleted")
+    defer func() { tracing.End(span, err) }()
+    return s.next.PurgeDeleted(ctx, retention)
+}
+
+func (s *tracedUserService) SendWelcomeEmail(ctx context.Context, user *models.User) (err error) {
+    ctx, span := tracing.Start(ctx, "UserService.SendWelcomeEmail")
+    defer func() { tracing.End(span, err) }()
+    return s.next.SendWelcomeEmail(ctx, user)
+}
+
+type tracedOrderService struct {
+    next OrderService
+}
+
+// NewTracedOrderService wraps next with tracing.
+func NewTracedOrderService(next OrderService) OrderService {
+    return &tracedOrderService{next: next}
+}
+
+func (s *tracedOrderService) Create(ctx context.Context, userID uint, req models.OrderRequest) (_ models.Order, err error) {
+    ctx, span := tracing.Start(ctx, "OrderService.Create")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Create(ctx, userID, req)
+}
+
+func (s *tracedOrderService) CreateBatch(ctx context.Context, userID uint, req models.OrderBatchRequest) (_ models.OrderBatchResult, err error) {
+    ctx, span := tracing.Start(ctx, "OrderService.CreateBatch")
+    defer func() { tracing.End(span, err) }()
+    return s.next.CreateBatch(ctx, userID, req)
+}
+
+func (s *tracedOrderService) ListByUser(ctx context.Context, userID uint, spec query.Spec) (_ []models.Order, _ query.PageInfo, _ *models.OrderSummary, err error) {
+    ctx, span := tracing.Start(ctx, "OrderService.ListByUser")
+    defer func() { tracing.End(span, err) }()
+    return s.next.ListByUser(ctx, userID, spec)
+}
+
+func (s *tracedOrderService) GetByID(ctx context.Context, userID, orderID uint) (_ *models.Order, err error) {
+    ctx, span := tracing.Start(ctx, "OrderService.GetByID")
+    defer func() { tracing.End(span, err) }()
+    return s.next.GetByID(ctx, userID, orderID)
+}
+
+func (s *tracedOrderService) Update(ctx context.Context, userID, orderID uint, input models.UpdateOrderInput) (_ *models.Order, err error) {
+    ctx, span := tracing.Start(ctx, "OrderService.Update")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Update(ctx, userID, orderID, input)
+}
+
+func (s *tracedOrderService) Delete(ctx context.Context, userID, orderID uint) (err error) {
+    ctx, span := tracing.Start(ctx, "OrderService.Delete")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Delete(ctx, userID, orderID)
+}
+
+func (s *tracedOrderService) NotifyOrderCreated(ctx context.Context, order *models.Order) (err error) {
+    ctx, span := tracing.Start(ctx, "OrderService.NotifyOrderCreated")
+    defer func() { tracing.End(span, err) }()
+    return s.next.NotifyOrderCreated(ctx, order)
+}
+
+type tracedAuthService struct {
+    next AuthService
+}
+
+// NewTracedAuthService wraps next with tracing.
+func NewTracedAuthService(next AuthService) AuthService {
+    return &tracedAuthService{next: next}
+}
+
+func (s *tracedAuthService) Login(ctx context.Context, input models.LoginInput) (_ string, err error) {
+    ctx, span := tracing.Start(ctx, "AuthService.Login")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Login(ctx, input)
+}
+
+type tracedAuditService struct {
+    next AuditService
+}
+
+// NewTracedAuditService wraps next with tracing.
+func NewTracedAuditService(next AuditService) AuditService {
+    return &tracedAuditService{next: next}
+}
+
+func (s *tracedAuditService) Record(ctx context.Context, action, resourceType string, resourceID uint, before, after interface{}) (err error) {
+    ctx, span := tracing.Start(ctx, "AuditService.Record")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Record(ctx, action, resourceType, resourceID, before, after)
+}
+
+func (s *tracedAuditService) List(ctx context.Context, filter models.AuditFilter) (_ []models.AuditEntry, _ int, err error) {
+    ctx, span := tracing.Start(ctx, "AuditService.List")
+    defer func() { tracing.End(span, err) }()
+    return s.next.List(ctx, filter)
+}
+
+func (s *tracedAuditService) Verify(ctx context.Context) (_ models.AuditVerifyResult, err error) {
+    ctx, span := tracing.Start(ctx, "AuditService.Verify")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Verify(ctx)
+}
+
+type tracedPrivacyService struct {
+    next PrivacyService
+}
+
+// NewTracedPrivacyService wraps next with tracing.
+func NewTracedPrivacyService(next PrivacyService) PrivacyService {
+    return &tracedPrivacyService{next: next}
+}
+
+func (s *tracedPrivacyService) StartExport(ctx context.Context, userID uint) (_ *models.Job, err error) {
+    ctx, span := tracing.Start(ctx, "PrivacyService.StartExport")
+    defer func() { tracing.End(span, err) }()
+    return s.next.StartExport(ctx, userID)
+}
+
+func (s *tracedPrivacyService) StartErasure(ctx context.Context, userID uint) (_ *models.Job, err error) {
+    ctx, span := tracing.Start(ctx, "PrivacyService.StartErasure")
+    defer func() { tracing.End(span, err) }()
+    return s.next.StartErasure(ctx, userID)
+}
+
+func (s *tracedPrivacyService) GetJob(ctx context.Context, id string) (_ *models.Job, err error) {
+    ctx, span := tracing.Start(ctx, "PrivacyService.GetJob")
+    defer func() { tracing.End(span, err) }()
+    return s.next.GetJob(ctx, id)
+}
+
+func (s *tracedPrivacyService) ExportFile(ctx context.Context, id string) (_ string, err error) {
+    ctx, span := tracing.Start(ctx, "PrivacyService.ExportFile")
+    defer func() { tracing.End(span, err) }()
+    return s.next.ExportFile(ctx, id)
+}
+
+func (s *tracedPrivacyService) ExpireExports(ctx context.Context, retention time.Duration) (_ int, err error) {
+    ctx, span := tracing.Start(ctx, "PrivacyService.ExpireExports")
+    defer func() { tracing.End(span, err) }()
+    return s.next.ExpireExports(ctx, retention)
+}
+
+type tracedImportService struct {
+    next ImportService
+}
+
+// NewTracedImportService wraps next with tracing.
+func NewTracedImportService(next ImportService) ImportService {
+    return &tracedImportService{next: next}
+}
+
+func (s *tracedImportService) StartUserImport(ctx context.Context, format string, data []byte, opts ImportOptions) (_ *models.Job, err error) {
+    ctx, span := tracing.Start(ctx, "ImportService.StartUserImport")
+    defer func() { tracing.End(span, err) }()
+    return s.next.StartUserImport(ctx, format, data, opts)
+}
+
+func (s *tracedImportService) GetImport(ctx context.Context, id string) (_ *models.ImportJob, err error) {
+    ctx, span := tracing.Start(ctx, "ImportService.GetImport")
+    defer func() { tracing.End(span, err) }()
+    return s.next.GetImport(ctx, id)
+}
+
+type tracedReportService struct {
+    next ReportService
+}
+
+// NewTracedReportService wraps next with tracing.
+func NewTracedReportService(next ReportService) ReportService {
+    return &tracedReportService{next: next}
+}
+
+func (s *tracedReportService) SearchOrders(ctx context.Context, spec query.Spec) (_ []models.AdminOrder, _ query.PageInfo, err error) {
+    ctx, span := tracing.Start(ctx, "ReportService.SearchOrders")
+    defer func() { tracing.End(span, err) }()
+    return s.next.SearchOrders(ctx, spec)
+}
+
+func (s *tracedReportService) ExportOrders(ctx context.Context, spec query.Spec, fn func(models.AdminOrder) error) (err error) {
+    ctx, span := tracing.Start(ctx, "ReportService.ExportOrders")
+    defer func() { tracing.End(span, err) }()
+    return s.next.ExportOrders(ctx, spec, fn)
+}
+
+func (s *tracedReportService) Revenue(ctx context.Context, period string, rng models.ReportRange) (_ []models.RevenuePoint, err error) {
+    ctx, span := tracing.Start(ctx, "ReportService.Revenue")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Revenue(ctx, period, rng)
+}
+
+func (s *tracedReportService) TopProducts(ctx context.Context, limit int, rng models.ReportRange) (_ []models.ProductStat, err error) {
+    ctx, span := tracing.Start(ctx, "ReportService.TopProducts")
+    defer func() { tracing.End(span, err) }()
+    return s.next.TopProducts(ctx, limit, rng)
+}
+
+func (s *tracedReportService) TopCustomers(ctx context.Context, limit int, rng models.ReportRange) (_ []models.CustomerStat, err error) {
+    ctx, span := tracing.Start(ctx, "ReportService.TopCustomers")
+    defer func() { tracing.End(span, err) }()
+    return s.next.TopCustomers(ctx, limit, rng)
+}
+
+func (s *tracedReportService) OrderValue(ctx context.Context, rng models.ReportRange) (_ models.OrderValueStats, err error) {
+    ctx, span := tracing.Start(ctx, "ReportService.OrderValue")
+    defer func() { tracing.End(span, err) }()
+    return s.next.OrderValue(ctx, rng)
+}
+
+type tracedIdempotencyService struct {
+    next IdempotencyService
+}
+
+// NewTracedIdempotencyService wraps next with tracing.
+func NewTracedIdempotencyService(next IdempotencyService) IdempotencyService {
+    return &tracedIdempotencyService{next: next}
+}
+
+func (s *tracedIdempotencyService) Begin(ctx context.Context, userID uint, key, requestHash string) (_ *models.IdempotencyKey, err error) {
+    ctx, span := tracing.Start(ctx, "IdempotencyService.Begin")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Begin(ctx, userID, key, requestHash)
+}
+
+func (s *tracedIdempotencyService) Complete(ctx context.Context, userID uint, key string, status int, body []byte) (err error) {
+    ctx, span := tracing.Start(ctx, "IdempotencyService.Complete")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Complete(ctx, userID, key, status, body)
+}
+
+func (s *tracedIdempotencyService) Abort(ctx context.Context, userID uint, key string) (err error) {
+    ctx, span := tracing.Start(ctx, "IdempotencyService.Abort")
+    defer func() { tracing.End(span, err) }()
+    return s.next.Abort(ctx, userID, key)
+}
+
+func (s *tracedIdempotencyService) PurgeExpired(ctx context.Context) (_ int64, err error) {
+    ctx, span := tracing.Start(ctx, "IdempotencyService.PurgeExpired")
+    defer func() { tracing.End(span, err) }()
+    return s.next.PurgeExpired(ctx)
+}
@@ -1,40 +1,82 @@
 package services
 
 import (
+    "bytes"
     "context"
+    "encoding/json"
     "errors"
     "fmt"
+    "net/mail"
+    "strings"
+    "time"
 
     "golang.org/x/crypto/bcrypt"
 
+    "github.com/PhosFactum/kvant-backend-practicum/internal/metrics"
     "github.com/PhosFactum/kvant-backend-practicum/internal/models"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/query"
     "github.com/PhosFactum/kvant-backend-practicum/internal/repository"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/tracing"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/utils"
 )
 
+// Patch formats accepted by UserService.Patch
+const (
+    PatchMerge = "merge"
+    PatchJSON  = "json"
+)
+
+var (
+    ErrVersionConflict = errors.New("user was modified by someone else")
+    ErrEmailTaken      = errors.New("email already exists")
+    ErrPatchTestFailed = errors.New("patch test operation failed")
+)
+
+// IsVersionConflict helps handlers map failed optimistic updates.
+func IsVersionConflict(err error) bool {
+    return errors.Is(err, ErrVersionConflict)
+}
+
+// IsConflict helps handlers map updates that clash with existing data.
+func IsConflict(err error) bool {
+    return errors.Is(err, ErrEmailTaken) || errors.Is(err, ErrPatchTestFailed) ||
+        errors.Is(err, ErrOrderNotEditable)
+}
+
 type UserService interface {
     Create(ctx context.Context, input models.CreateUserInput) (*models.User, error)
-    List(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int, error)
+    List(ctx context.Context, spec query.Spec) ([]models.User, query.PageInfo, error)
+    Export(ctx context.Context, spec query.Spec, fn func(models.User) error) error
     GetByID(ctx context.Context, id uint) (*models.User, error)
-    Update(ctx context.Context, id uint, input models.User) (*models.User, error)
+    Update(ctx context.Context, id uint, match utils.IfMatch, input models.UpdateUserInput) (*models.User, error)
+    Patch(ctx context.Context, id uint, match utils.IfMatch, format string, patch []byte) (*models.User, error)
     Delete(ctx context.Context, id uint) error
+    Restore(ctx context.Context, id uint) (*models.User, error)
+    PurgeDeleted(ctx context.Context, retention time.Duration) (int, error)
     SendWelcomeEmail(ctx context.Context, user *models.User) error
 }
 
 type userService struct {
-    repo repository.UserRepository
+    repo  repository.UserRepository
+    audit AuditService
+    tx    repository.TxManager
 }
 
-func NewUserService(r repository.UserRepository) UserService {
-    return &userService{repo: r}
+func NewUserService(r repository.UserRepository, audit AuditService, tx repository.TxManager) UserService {
+    return &userService{repo: r, audit: audit, tx: tx}
 }
 
 func (s *userService) Create(ctx context.Context, input models.CreateUserInput) (*models.User, error) {
-    exists, _ := s.repo.FindByEmail(ctx, input.Email)
-    if exists != nil {
-        return nil, errors.New("email already exists")
+    // A soft-deleted user keeps the email until purged
+    if taken, err := s.repo.EmailTaken(ctx, input.Email); err != nil {
+        return nil, err
+    } else if taken {
+        return nil, ErrEmailTaken
     }
 
+    _, span := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
     pwHash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+    tracing.End(span, err)
     if err != nil {
         return nil, err
     }
@@ -43,37 +85,256 @@ func (s *userService) Create(ctx context.Context, input models.CreateUserInput)
         Name:         input.Name,
         Email:        input.Email,
         Age:          input.Age,
+        Version:      1,
         PasswordHash: string(pwHash),
     }
 
-    err = s.repo.Create(ctx, user)
-    return user, err
+    // The user and its audit entry are stored together or not at all
+    err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        if err := s.repo.Create(ctx, user); err != nil {
+            if errors.Is(err, repository.ErrDuplicateKey) {
+                // Lost a race with a concurrent signup
+                return ErrEmailTaken
+            }
+            return err
+        }
+        if err := s.audit.Record(ctx, models.AuditActionCreate, models.AuditResourceUser, user.ID, nil, user); err != nil {
+            return err
+        }
+        repository.AfterCommit(ctx, func() { metrics.UsersCreated(1) })
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return user, nil
+}
+
+func (s *userService) List(ctx context.Context, spec query.Spec) ([]models.User, query.PageInfo, error) {
+    return s.repo.List(ctx, spec)
 }
 
-func (s *userService) List(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int, error) {
-    return s.repo.List(ctx, page, limit, minAge, maxAge)
+// Export streams every user matching spec to fn without paging.
+func (s *userService) Export(ctx context.Context, spec query.Spec, fn func(models.User) error) error {
+    return s.repo.Stream(ctx, spec, fn)
 }
 
 func (s *userService) GetByID(ctx context.Context, id uint) (*models.User, error) {
-    return s.repo.GetByID(ctx, id)
+    user, err := s.repo.GetByID(ctx, id)
+    if err != nil {
+        return nil, lookupError(err, ErrUserNotFound)
+    }
+    return user, nil
 }
 
-func (s *userService) Update(ctx context.Context, id uint, input models.User) (*models.User, error) {
+// Update changes the provided fields if the version of the user matches the
+// If-Match precondition. The change and its audit
+// entry are stored in one transaction.
+func (s *userService) Update(ctx context.Context, id uint, match utils.IfMatch, input models.UpdateUserInput) (*models.User, error) {
+    var updated *models.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        user, err := s.repo.GetByID(ctx, id)
+        if err != nil {
+            return lookupError(err, ErrUserNotFound)
+        }
+        if !match.Matches(user.Version) {
+            return ErrVersionConflict
+        }
+        updated, err = s.apply(ctx, user, input)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return updated, nil
+}
+
+// Patch applies an RFC 7396 merge patch or an RFC 6902 JSON patch to the
+// editable fields of the user ({"name", "email", "age"}), in one transaction
+// with its audit entry.
+func (s *userService) Patch(ctx context.Context, id uint, match utils.IfMatch, format string, patch []byte) (*models.User, error) {
+    var updated *models.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        var err error
+        updated, err = s.patch(ctx, id, match, format, patch)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return updated, nil
+}
+
+func (s *userService) patch(ctx context.Context, id uint, match utils.IfMatch, format string, patch []byte) (*models.User, error) {
     user, err := s.repo.GetByID(ctx, id)
     if err != nil {
+        return nil, lookupError(err, ErrUserNotFound)
+    }
+    if !match.Matches(user.Version) {
+        return nil, ErrVersionConflict
+    }
+
+    doc, err := json.Marshal(models.UpdateUserInput{Name: &user.Name, Email: &user.Email, Age: &user.Age})
+    if err != nil {
+        return nil, err
+    }
+
+    var patched []byte
+    switch format {
+    case PatchMerge:
+        patched, err = utils.MergePatch(doc, patch)
+    case PatchJSON:
+        patched, err = utils.ApplyJSONPatch(doc, patch)
+    default:
+        return nil, fmt.Errorf("%w: unsupported patch format %q", ErrInvalidRequest, format)
+    }
+    if errors.Is(err, utils.ErrPatchTestFailed) {
+        return nil, ErrPatchTestFailed
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+    }
+
+    var result models.UpdateUserInput
+    dec := json.NewDecoder(bytes.NewReader(patched))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(&result); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+    }
+    if result.Name == nil || result.Email == nil || result.Age == nil {
+        return nil, fmt.Errorf("%w: name, email and age cannot be removed", ErrInvalidRequest)
+    }
+
+    // Only pass on what actually changed
+    var input models.UpdateUserInput
+    if *result.Name != user.Name {
+        input.Name = result.Name
+    }
+    if *result.Email != user.Email {
+        input.Email = result.Email
+    }
+    if *result.Age != user.Age {
+        input.Age = result.Age
+    }
+    return s.apply(ctx, user, input)
+}
+
+// apply validates the provided fields, writes them and records the change.
+// It must run in a transaction.
+func (s *userService) apply(ctx context.Context, user *models.User, input models.UpdateUserInput) (*models.User, error) {
+    if err := validateUpdateInput(input); err != nil {
         return nil, err
     }
+    before := *user
 
-    user.Name = input.Name
-    user.Email = input.Email
-    user.Age = input.Age
+    if input.Name != nil {
+        user.Name = strings.TrimSpace(*input.Name)
+    }
+    if input.Email != nil && *input.Email != user.Email {
+        if taken, err := s.repo.EmailTaken(ctx, *input.Email); err != nil {
+            return nil, err
+        } else if taken {
+            return nil, ErrEmailTaken
+        }
+        user.Email = *input.Email
+    }
+    if input.Age != nil {
+        user.Age = *input.Age
+    }
+    if before == *user {
+        return user, nil
+    }
 
-    err = s.repo.Update(ctx, user)
-    return user, err
+    if err := s.repo.Update(ctx, user); err != nil {
+        if errors.Is(err, repository.ErrStaleVersion) {
+            return nil, ErrVersionConflict
+        }
+        if errors.Is(err, repository.ErrDuplicateKey) {
+            return nil, ErrEmailTaken
+        }
+        return nil, err
+    }
+    if err := s.audit.Record(ctx, models.AuditActionUpdate, models.AuditResourceUser, user.ID, &before, user); err != nil {
+        return nil, err
+    }
+    return user, nil
 }
 
+// validateUpdateInput mirrors the binding rules of UpdateUserInput, so input
+// produced by a patch is held to the same standard as a bound request body.
+func validateUpdateInput(input models.UpdateUserInput) error {
+    if input.Name != nil {
+        name := strings.TrimSpace(*input.Name)
+        if name == "" || len(name) > 255 {
+            return fmt.Errorf("%w: name must be 1 to 255 characters", ErrInvalidRequest)
+        }
+    }
+    if input.Email != nil {
+        addr, err := mail.ParseAddress(*input.Email)
+        if err != nil || addr.Address != *input.Email {
+            return fmt.Errorf("%w: email is not valid", ErrInvalidRequest)
+        }
+    }
+    if input.Age != nil && (*input.Age < 0 || *input.Age > 150) {
+        return fmt.Errorf("%w: age must be between 0 and 150", ErrInvalidRequest)
+    }
+    return nil
+}
+
+// Delete soft-deletes the user with their orders and records it in the audit log,
+// all in one transaction.
 func (s *userService) Delete(ctx context.Context, id uint) error {
-    return s.repo.Delete(ctx, id)
+    return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        user, err := s.repo.GetByID(ctx, id)
+        if err != nil {
+            return lookupError(err, ErrUserNotFound)
+        }
+        if err := s.repo.Delete(ctx, id); err != nil {
+            return err
+        }
+        return s.audit.Record(ctx, models.AuditActionDelete, models.AuditResourceUser, id, user, nil)
+    })
+}
+
+// Restore brings back a soft-deleted user and the orders deleted with it,
+// in one transaction with the audit entry.
+func (s *userService) Restore(ctx context.Context, id uint) (*models.User, error) {
+    var user *models.User
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        var err error
+        user, err = s.repo.Restore(ctx, id)
+        if err != nil {
+            return lookupError(err, ErrUserNotFound)
+        }
+        return s.audit.Record(ctx, models.AuditActionRestore, models.AuditResourceUser, id, nil, user)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return user, nil
+}
+
+// PurgeDeleted hard-deletes users that were soft-deleted longer than retention
+// ago, in one transaction with the audit entries.
+func (s *userService) PurgeDeleted(ctx context.Context, retention time.Duration) (int, error) {
+    var ids []uint
+    err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+        var err error
+        ids, err = s.repo.PurgeDeleted(ctx, time.Now().UTC().Add(-retention))
+        if err != nil {
+            return err
+        }
+        for _, id := range ids {
+            if err := s.audit.Record(ctx, models.AuditActionPurge, models.AuditResourceUser, id, nil, nil); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return 0, err
+    }
+    return len(ids), nil
 }
 
 // SendWelcomeEmail simulates sending a welcome email to the new user.
@@ -0,0 +1,85 @@
+// internal/tracing/tracing.go
+package tracing
+
+import (
+    "context"
+    "fmt"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+    "go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+    "go.opentelemetry.io/otel/propagation"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// Exporters
+const (
+    ExporterNone   = "none"
+    ExporterStdout = "stdout"
+    ExporterOTLP   = "otlp"
+)
+
+const (
+    instrumentation = "github.com/PhosFactum/kvant-backend-practicum"
+    serviceName     = "kvant-backend"
+)
+
+// Setup installs the global tracer provider with the given exporter and the
+// W3C trace context propagator, and returns a function flushing the spans
+// still buffered. With ExporterNone spans are not recorded, but incoming trace
+// context is still passed on. The OTLP exporter (HTTP) and the sampler are
+// configured with the standard OTEL_* environment variables, such as
+// OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_TRACES_SAMPLER; OTEL_SERVICE_NAME
+// overrides the service name.
+func Setup(ctx context.Context, exporter string) (func(context.Context) error, error) {
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+        propagation.TraceContext{}, propagation.Baggage{},
+    ))
+
+    var exp sdktrace.SpanExporter
+    var err error
+    switch exporter {
+    case ExporterNone:
+        return func(context.Context) error { return nil }, nil
+    case ExporterStdout:
+        exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+    case ExporterOTLP:
+        exp, err = otlptracehttp.New(ctx)
+    default:
+        return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+    }
+
+    res, err := resource.New(ctx,
+        resource.WithAttributes(attribute.String("service.name", serviceName)),
+        resource.WithFromEnv(),
+        resource.WithTelemetrySDK(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to describe the service: %w", err)
+    }
+
+    provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+    otel.SetTracerProvider(provider)
+    return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+    return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
+
+// End ends span, marking it failed if err is not nil.
+func End(span trace.Span, err error) {
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+    span.End()
+}
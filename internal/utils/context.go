@@ -0,0 +1,47 @@
+// internal/utils/context.go
+package utils
+
+import "context"
+
+type ctxKey int
+
+const (
+    requestIDKey ctxKey = iota
+    clientIPKey
+    actorKey
+)
+
+// Actor identifies the authenticated caller of a request.
+type Actor struct {
+    ID   uint
+    Role string
+}
+
+// WithRequestMeta stores the request ID and client IP in the context.
+func WithRequestMeta(ctx context.Context, requestID, ip string) context.Context {
+    ctx = context.WithValue(ctx, requestIDKey, requestID)
+    return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// RequestIDFromContext returns the request ID, if any.
+func RequestIDFromContext(ctx context.Context) string {
+    s, _ := ctx.Value(requestIDKey).(string)
+    return s
+}
+
+// ClientIPFromContext returns the client IP, if any.
+func ClientIPFromContext(ctx context.Context) string {
+    s, _ := ctx.Value(clientIPKey).(string)
+    return s
+}
+
+// WithActor stores the authenticated caller in the context.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+    return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the authenticated caller, if any.
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+    a, ok := ctx.Value(actorKey).(Actor)
+    return a, ok
+}
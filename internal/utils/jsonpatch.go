@@ -0,0 +1,300 @@
+// internal/utils/jsonpatch.go
+package utils
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+var (
+    // ErrInvalidPatch is returned for malformed patch documents or operations
+    // that cannot be applied to the target document.
+    ErrInvalidPatch = errors.New("invalid patch")
+    // ErrPatchTestFailed is returned when a JSON Patch "test" operation does not match.
+    ErrPatchTestFailed = errors.New("patch test operation failed")
+)
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+    var target, p interface{}
+    if err := json.Unmarshal(doc, &target); err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(patch, &p); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+    }
+    return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+    p, ok := patch.(map[string]interface{})
+    if !ok {
+        return patch
+    }
+    t, ok := target.(map[string]interface{})
+    if !ok {
+        t = make(map[string]interface{})
+    }
+    for k, v := range p {
+        if v == nil {
+            delete(t, k)
+            continue
+        }
+        t[k] = mergeValue(t[k], v)
+    }
+    return t
+}
+
+// patchOp is a single RFC 6902 operation.
+type patchOp struct {
+    Op    string           `json:"op"`
+    Path  *string          `json:"path"`
+    From  *string          `json:"from"`
+    Value *json.RawMessage `json:"value"`
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch to doc. Operations are applied
+// in order and the whole patch fails if any of them fails.
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+    var ops []patchOp
+    if err := json.Unmarshal(patch, &ops); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+    }
+    var target interface{}
+    if err := json.Unmarshal(doc, &target); err != nil {
+        return nil, err
+    }
+
+    for i, op := range ops {
+        var err error
+        target, err = applyOp(target, op)
+        if err != nil {
+            return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+        }
+    }
+    return json.Marshal(target)
+}
+
+func applyOp(doc interface{}, op patchOp) (interface{}, error) {
+    if op.Path == nil {
+        return nil, fmt.Errorf("%w: missing path", ErrInvalidPatch)
+    }
+    path, err := parsePointer(*op.Path)
+    if err != nil {
+        return nil, err
+    }
+
+    switch op.Op {
+    case "add", "replace", "test":
+        if op.Value == nil {
+            return nil, fmt.Errorf("%w: missing value", ErrInvalidPatch)
+        }
+        var value interface{}
+        if err := json.Unmarshal(*op.Value, &value); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+        }
+        switch op.Op {
+        case "add":
+            return pointerAdd(doc, path, value)
+        case "replace":
+            if _, err := pointerGet(doc, path); err != nil {
+                return nil, err
+            }
+            if doc, err = pointerRemove(doc, path); err != nil {
+                return nil, err
+            }
+            return pointerAdd(doc, path, value)
+        default:
+            current, err := pointerGet(doc, path)
+            if err != nil {
+                return nil, err
+            }
+            if !jsonEqual(current, value) {
+                return nil, ErrPatchTestFailed
+            }
+            return doc, nil
+        }
+    case "remove":
+        return pointerRemove(doc, path)
+    case "move", "copy":
+        if op.From == nil {
+            return nil, fmt.Errorf("%w: missing from", ErrInvalidPatch)
+        }
+        from, err := parsePointer(*op.From)
+        if err != nil {
+            return nil, err
+        }
+        value, err := pointerGet(doc, from)
+        if err != nil {
+            return nil, err
+        }
+        if op.Op == "move" {
+            if len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+                return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalidPatch)
+            }
+            if doc, err = pointerRemove(doc, from); err != nil {
+                return nil, err
+            }
+        } else {
+            value = deepCopy(value)
+        }
+        return pointerAdd(doc, path, value)
+    default:
+        return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+    }
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(p string) ([]string, error) {
+    if p == "" {
+        return nil, nil
+    }
+    if !strings.HasPrefix(p, "/") {
+        return nil, fmt.Errorf("%w: bad pointer %q", ErrInvalidPatch, p)
+    }
+    parts := strings.Split(p[1:], "/")
+    for i, part := range parts {
+        parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+    }
+    return parts, nil
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+    cur := doc
+    for _, tok := range path {
+        switch node := cur.(type) {
+        case map[string]interface{}:
+            v, ok := node[tok]
+            if !ok {
+                return nil, fmt.Errorf("%w: path %q not found", ErrInvalidPatch, tok)
+            }
+            cur = v
+        case []interface{}:
+            i, err := arrayIndex(tok, len(node)-1)
+            if err != nil {
+                return nil, err
+            }
+            cur = node[i]
+        default:
+            return nil, fmt.Errorf("%w: path %q not found", ErrInvalidPatch, tok)
+        }
+    }
+    return cur, nil
+}
+
+// pointerAdd returns doc with value added at path; the root may be replaced.
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+    if len(path) == 0 {
+        return value, nil
+    }
+    parent, err := pointerGet(doc, path[:len(path)-1])
+    if err != nil {
+        return nil, err
+    }
+    last := path[len(path)-1]
+
+    switch node := parent.(type) {
+    case map[string]interface{}:
+        node[last] = value
+        return doc, nil
+    case []interface{}:
+        i := len(node)
+        if last != "-" {
+            if i, err = arrayIndex(last, len(node)); err != nil {
+                return nil, err
+            }
+        }
+        node = append(node, nil)
+        copy(node[i+1:], node[i:])
+        node[i] = value
+        return pointerSet(doc, path[:len(path)-1], node)
+    default:
+        return nil, fmt.Errorf("%w: cannot add to %q", ErrInvalidPatch, last)
+    }
+}
+
+func pointerRemove(doc interface{}, path []string) (interface{}, error) {
+    if len(path) == 0 {
+        return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+    }
+    parent, err := pointerGet(doc, path[:len(path)-1])
+    if err != nil {
+        return nil, err
+    }
+    last := path[len(path)-1]
+
+    switch node := parent.(type) {
+    case map[string]interface{}:
+        if _, ok := node[last]; !ok {
+            return nil, fmt.Errorf("%w: path %q not found", ErrInvalidPatch, last)
+        }
+        delete(node, last)
+        return doc, nil
+    case []interface{}:
+        i, err := arrayIndex(last, len(node)-1)
+        if err != nil {
+            return nil, err
+        }
+        node = append(node[:i], node[i+1:]...)
+        return pointerSet(doc, path[:len(path)-1], node)
+    default:
+        return nil, fmt.Errorf("%w: path %q not found", ErrInvalidPatch, last)
+    }
+}
+
+// pointerSet replaces the value at path, used when a slice header changes.
+func pointerSet(doc interface{}, path []string, value interface{}) (interface{}, error) {
+    if len(path) == 0 {
+        return value, nil
+    }
+    parent, err := pointerGet(doc, path[:len(path)-1])
+    if err != nil {
+        return nil, err
+    }
+    last := path[len(path)-1]
+    switch node := parent.(type) {
+    case map[string]interface{}:
+        node[last] = value
+    case []interface{}:
+        i, err := arrayIndex(last, len(node)-1)
+        if err != nil {
+            return nil, err
+        }
+        node[i] = value
+    }
+    return doc, nil
+}
+
+func arrayIndex(tok string, max int) (int, error) {
+    if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+        return 0, fmt.Errorf("%w: bad array index %q", ErrInvalidPatch, tok)
+    }
+    i, err := strconv.Atoi(tok)
+    if err != nil || i < 0 || i > max {
+        return 0, fmt.Errorf("%w: array index %q out of range", ErrInvalidPatch, tok)
+    }
+    return i, nil
+}
+
+func jsonEqual(a, b interface{}) bool {
+    ab, err1 := json.Marshal(a)
+    bb, err2 := json.Marshal(b)
+    return err1 == nil && err2 == nil && bytes.Equal(ab, bb)
+}
+
+func deepCopy(v interface{}) interface{} {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return v
+    }
+    var out interface{}
+    if err := json.Unmarshal(b, &out); err != nil {
+        return v
+    }
+    return out
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+    "encoding/json"
+    "errors"
+    "reflect"
+    "testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+    const doc = `{"name":"Ann","tags":["a","b","c"],"a/b":1,"m~n":2,"nested":{"x":{"y":1}}}`
+
+    cases := []struct {
+        name    string
+        patch   string
+        want    string
+        wantErr error
+    }{
+        {
+            name:  "add to array end",
+            patch: `[{"op":"add","path":"/tags/3","value":"d"}]`,
+            want:  `{"name":"Ann","tags":["a","b","c","d"],"a/b":1,"m~n":2,"nested":{"x":{"y":1}}}`,
+        },
+        {
+            name:  "add at array index",
+            patch: `[{"op":"add","path":"/tags/1","value":"x"}]`,
+            want:  `{"name":"Ann","tags":["a","x","b","c"],"a/b":1,"m~n":2,"nested":{"x":{"y":1}}}`,
+        },
+        {
+            name:  "append with dash",
+            patch: `[{"op":"add","path":"/tags/-","value":"d"}]`,
+            want:  `{"name":"Ann","tags":["a","b","c","d"],"a/b":1,"m~n":2,"nested":{"x":{"y":1}}}`,
+        },
+        {
+            name:    "add past array end",
+            patch:   `[{"op":"add","path":"/tags/4","value":"d"}]`,
+            wantErr: ErrInvalidPatch,
+        },
+        {
+            name:  "remove array end",
+            patch: `[{"op":"remove","path":"/tags/2"}]`,
+            want:  `{"name":"Ann","tags":["a","b"],"a/b":1,"m~n":2,"nested":{"x":{"y":1}}}`,
+        },
+        {
+            name:  "remove at array index",
+            patch: `[{"op":"remove","path":"/tags/0"}]`,
+            want:  `{"name":"Ann","tags":["b","c"],"a/b":1,"m~n":2,"nested":{"x":{"y":1}}}`,
+        },
+        {
+            name:    "remove past array end",
+            patch:   `[{"op":"remove","path":"/tags/3"}]`,
+            wantErr: ErrInvalidPatch,
+        },
+        {
+            name:    "remove with dash",
+            patch:   `[{"op":"remove","path":"/tags/-"}]`,
+            wantErr: ErrInvalidPatch,
+        },
+        {
+            name:    "leading zero index",
+            patch:   `[{"op":"remove","path":"/tags/01"}]`,
+            wantErr: ErrInvalidPatch,
+        },
+        {
+            name:    "leading zero index on add",
+            patch:   `[{"op":"add","path":"/tags/00","value":"x"}]`,
+            wantErr: ErrInvalidPatch,
+        },
+        {
+            name:  "escaped slash",
+            patch: `[{"op":"replace","path":"/a~1b","value":10}]`,
+            want:  `{"name":"Ann","tags":["a","b","c"],"a/b":10,"m~n":2,"nested":{"x":{"y":1}}}`,
+        },
+        {
+            name:  "escaped tilde",
+            patch: `[{"op":"remove","path":"/m~0n"}]`,
+            want:  `{"name":"Ann","tags":["a","b","c"],"a/b":1,"nested":{"x":{"y":1}}}`,
+        },
+        {
+            name:  "move",
+            patch: `[{"op":"move","from":"/nested/x","path":"/x"}]`,
+            want:  `{"name":"Ann","tags":["a","b","c"],"a/b":1,"m~n":2,"nested":{},"x":{"y":1}}`,
+        },
+        {
+            name:    "move into its own child",
+            patch:   `[{"op":"move","from":"/nested","path":"/nested/x/z"}]`,
+            wantErr: ErrInvalidPatch,
+        },
+        {
+            name:  "copy is independent of its source",
+            patch: `[{"op":"copy","from":"/nested/x","path":"/x"},{"op":"replace","path":"/x/y","value":2}]`,
+            want:  `{"name":"Ann","tags":["a","b","c"],"a/b":1,"m~n":2,"nested":{"x":{"y":1}},"x":{"y":2}}`,
+        },
+        {
+            name:  "passing test",
+            patch: `[{"op":"test","path":"/tags","value":["a","b","c"]},{"op":"replace","path":"/name","value":"Bob"}]`,
+            want:  `{"name":"Bob","tags":["a","b","c"],"a/b":1,"m~n":2,"nested":{"x":{"y":1}}}`,
+        },
+        {
+            name:    "failing test",
+            patch:   `[{"op":"replace","path":"/name","value":"Bob"},{"op":"test","path":"/name","value":"Ann"}]`,
+            wantErr: ErrPatchTestFailed,
+        },
+        {
+            name:    "replace missing member",
+            patch:   `[{"op":"replace","path":"/missing","value":1}]`,
+            wantErr: ErrInvalidPatch,
+        },
+        {
+            name:    "unknown op",
+            patch:   `[{"op":"merge","path":"/name","value":1}]`,
+            wantErr: ErrInvalidPatch,
+        },
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            got, err := ApplyJSONPatch([]byte(doc), []byte(c.patch))
+            if c.wantErr != nil {
+                if !errors.Is(err, c.wantErr) {
+                    t.Fatalf("got error %v, want %v", err, c.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("ApplyJSONPatch: %v", err)
+            }
+            assertJSON(t, got, c.want)
+        })
+    }
+}
+
+func TestMergePatch(t *testing.T) {
+    const doc = `{"name":"Ann","age":30,"address":{"city":"Moscow","zip":"101000"},"tags":["a"]}`
+
+    cases := []struct {
+        name  string
+        patch string
+        want  string
+    }{
+        {
+            name:  "replace member",
+            patch: `{"name":"Bob"}`,
+            want:  `{"name":"Bob","age":30,"address":{"city":"Moscow","zip":"101000"},"tags":["a"]}`,
+        },
+        {
+            name:  "null deletes member",
+            patch: `{"age":null}`,
+            want:  `{"name":"Ann","address":{"city":"Moscow","zip":"101000"},"tags":["a"]}`,
+        },
+        {
+            name:  "null deletes nested member",
+            patch: `{"address":{"zip":null}}`,
+            want:  `{"name":"Ann","age":30,"address":{"city":"Moscow"},"tags":["a"]}`,
+        },
+        {
+            name:  "null for missing member",
+            patch: `{"missing":null}`,
+            want:  doc,
+        },
+        {
+            name:  "arrays are replaced",
+            patch: `{"tags":["b","c"]}`,
+            want:  `{"name":"Ann","age":30,"address":{"city":"Moscow","zip":"101000"},"tags":["b","c"]}`,
+        },
+        {
+            name:  "object replaces scalar",
+            patch: `{"age":{"years":30,"unit":null}}`,
+            want:  `{"name":"Ann","age":{"years":30},"address":{"city":"Moscow","zip":"101000"},"tags":["a"]}`,
+        },
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            got, err := MergePatch([]byte(doc), []byte(c.patch))
+            if err != nil {
+                t.Fatalf("MergePatch: %v", err)
+            }
+            assertJSON(t, got, c.want)
+        })
+    }
+
+    if _, err := MergePatch([]byte(doc), []byte(`{"name":`)); !errors.Is(err, ErrInvalidPatch) {
+        t.Errorf("malformed patch: got %v, want ErrInvalidPatch", err)
+    }
+}
+
+// assertJSON compares got and want as JSON values, ignoring member order.
+func assertJSON(t *testing.T, got []byte, want string) {
+    t.Helper()
+    var g, w interface{}
+    if err := json.Unmarshal(got, &g); err != nil {
+        t.Fatalf("result is not JSON: %v", err)
+    }
+    if err := json.Unmarshal([]byte(want), &w); err != nil {
+        t.Fatalf("bad expected JSON: %v", err)
+    }
+    if !reflect.DeepEqual(g, w) {
+        t.Errorf("got %s, want %s", got, want)
+    }
+}
@@ -2,13 +2,26 @@
 package utils
 
 import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
     "errors"
+    "fmt"
+    "log"
     "strconv"
+    "strings"
+    "time"
 
     "github.com/gin-gonic/gin"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/metrics"
+    "github.com/PhosFactum/kvant-backend-practicum/internal/tracing"
 )
 
+// MaxPageSize caps the limit parameter of list endpoints.
+const MaxPageSize = 100
+
 // ParsePagination extracts page and limit from query parameters, with defaults.
+// Limits above MaxPageSize are clamped.
 func ParsePagination(c *gin.Context) (page, limit int, err error) {
     pageStr := c.DefaultQuery("page", "1")
     limitStr := c.DefaultQuery("limit", "10")
@@ -22,6 +35,9 @@ func ParsePagination(c *gin.Context) (page, limit int, err error) {
     if err != nil || limit < 1 {
         return 0, 0, errors.New("invalid limit parameter")
     }
+    if limit > MaxPageSize {
+        limit = MaxPageSize
+    }
 
     return page, limit, nil
 }
@@ -43,6 +59,28 @@ func ParseAgeFilters(c *gin.Context) (minAge, maxAge int, err error) {
     return minAge, maxAge, nil
 }
 
+// ParseTimeRange extracts optional RFC3339 from and to bounds from query parameters.
+func ParseTimeRange(c *gin.Context, fromParam, toParam string) (from, to *time.Time, err error) {
+    if s := c.Query(fromParam); s != "" {
+        t, err := time.Parse(time.RFC3339, s)
+        if err != nil {
+            return nil, nil, errors.New("invalid " + fromParam + " parameter")
+        }
+        from = &t
+    }
+    if s := c.Query(toParam); s != "" {
+        t, err := time.Parse(time.RFC3339, s)
+        if err != nil {
+            return nil, nil, errors.New("invalid " + toParam + " parameter")
+        }
+        to = &t
+    }
+    if from != nil && to != nil && to.Before(*from) {
+        return nil, nil, errors.New(toParam + " must not be before " + fromParam)
+    }
+    return from, to, nil
+}
+
 // ParseIDParam extracts a uint ID from path parameters.
 func ParseIDParam(c *gin.Context, param string) (uint, error) {
     s := c.Param(param)
@@ -53,15 +91,100 @@ func ParseIDParam(c *gin.Context, param string) (uint, error) {
     return uint(id), nil
 }
 
-// Async runs a function in a new goroutine, recovering from any panics.
-func Async(f func()) {
+// ETag formats a resource version as a strong entity tag.
+func ETag(version uint) string {
+    return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// IfMatch is a parsed If-Match header: any version ("*") or one of Versions.
+type IfMatch struct {
+    Any      bool
+    Versions []uint
+}
+
+// Matches reports whether the header lets a change of a resource at version through.
+func (m IfMatch) Matches(version uint) bool {
+    if m.Any {
+        return true
+    }
+    for _, v := range m.Versions {
+        if v == version {
+            return true
+        }
+    }
+    return false
+}
+
+// ParseIfMatch parses an If-Match header: "*" or a comma-separated list of
+// entity tags. If-Match uses the strong comparison (RFC 7232, section 3.1), so
+// weak tags (W/"1") and tags not made by ETag are valid but never match.
+func ParseIfMatch(header string) (IfMatch, error) {
+    header = strings.TrimSpace(header)
+    if header == "*" {
+        return IfMatch{Any: true}, nil
+    }
+
+    invalid := errors.New("invalid If-Match header")
+    var m IfMatch
+    for rest := header; ; {
+        rest = strings.TrimLeft(rest, " \t")
+        weak := strings.HasPrefix(rest, "W/")
+        if weak {
+            rest = rest[2:]
+        }
+        if !strings.HasPrefix(rest, `"`) {
+            return IfMatch{}, invalid
+        }
+        end := strings.IndexByte(rest[1:], '"')
+        if end < 0 {
+            return IfMatch{}, invalid
+        }
+        opaque := rest[1 : end+1]
+        if v, err := strconv.ParseUint(opaque, 10, 64); err == nil && v > 0 && !weak {
+            m.Versions = append(m.Versions, uint(v))
+        }
+
+        rest = strings.TrimLeft(rest[end+2:], " \t")
+        if rest == "" {
+            return m, nil
+        }
+        if rest[0] != ',' {
+            return IfMatch{}, invalid
+        }
+        rest = rest[1:]
+    }
+}
+
+// NewID returns a random 128-bit hex identifier.
+func NewID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        panic("crypto/rand failed: " + err.Error())
+    }
+    return hex.EncodeToString(b)
+}
+
+// Async runs f in a new goroutine as a background task with the given name.
+// f gets the values of ctx (request metadata, trace) but not its cancellation,
+// and runs in a span of its own within the trace of ctx. Tasks are counted in
+// the metrics; an error returned by f or a panic is logged and counted as a
+// failure of the task.
+func Async(ctx context.Context, task string, f func(ctx context.Context) error) {
+    metrics.TaskStarted(task)
+    ctx, span := tracing.Start(context.WithoutCancel(ctx), "task "+task)
     go func() {
+        var err error
         defer func() {
             if r := recover(); r != nil {
-                // optionally log r
+                err = fmt.Errorf("panic: %v", r)
+            }
+            if err != nil {
+                metrics.TaskFailed(task)
+                log.Printf("background task %s failed: %v", task, err)
             }
+            tracing.End(span, err)
         }()
-        f()
+        err = f(ctx)
     }()
 }
 
@@ -0,0 +1,221 @@
+// internal/utils/xlsx.go
+package utils
+
+import (
+    "archive/zip"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "path"
+    "strings"
+)
+
+// ErrInvalidXLSX is returned when a workbook cannot be read.
+var ErrInvalidXLSX = errors.New("invalid xlsx file")
+
+// Limits of a readable workbook. Columns and rows are those of Excel (XFD,
+// 1048576); parts and cells are capped so that a small zip cannot expand
+// into gigabytes of XML or of padded rows.
+const (
+    xlsxMaxColumns  = 16384
+    xlsxMaxRows     = 1 << 20
+    xlsxMaxCells    = 1 << 22
+    xlsxMaxPartSize = 64 << 20
+)
+
+// Row is one spreadsheet or CSV row with its 1-based line number in the source.
+type Row struct {
+    Line  int
+    Cells []string
+}
+
+type xlsxWorkbook struct {
+    Sheets []struct {
+        RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+    } `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+    Items []struct {
+        ID     string `xml:"Id,attr"`
+        Target string `xml:"Target,attr"`
+    } `xml:"Relationship"`
+}
+
+type xlsxText struct {
+    T    string `xml:"t"`
+    Runs []struct {
+        T string `xml:"t"`
+    } `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+    if len(t.Runs) == 0 {
+        return t.T
+    }
+    var b strings.Builder
+    for _, r := range t.Runs {
+        b.WriteString(r.T)
+    }
+    return b.String()
+}
+
+type xlsxSharedStrings struct {
+    Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+    Rows []struct {
+        R     int `xml:"r,attr"`
+        Cells []struct {
+            R      string   `xml:"r,attr"`
+            T      string   `xml:"t,attr"`
+            V      string   `xml:"v"`
+            Inline xlsxText `xml:"is"`
+        } `xml:"c"`
+    } `xml:"sheetData>row"`
+}
+
+// ReadXLSX returns the rows of the first worksheet of an .xlsx workbook.
+// Only cell values are read: shared strings, inline strings and raw values
+// (numbers are returned as stored, formulas as their cached result).
+func ReadXLSX(r io.ReaderAt, size int64) ([]Row, error) {
+    zr, err := zip.NewReader(r, size)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidXLSX, err)
+    }
+    files := make(map[string]*zip.File, len(zr.File))
+    for _, f := range zr.File {
+        files[f.Name] = f
+    }
+
+    var wb xlsxWorkbook
+    if err := decodeXLSXPart(files, "xl/workbook.xml", &wb); err != nil {
+        return nil, err
+    }
+    if len(wb.Sheets) == 0 {
+        return nil, fmt.Errorf("%w: workbook has no sheets", ErrInvalidXLSX)
+    }
+    var rels xlsxRelationships
+    if err := decodeXLSXPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+        return nil, err
+    }
+    sheetPath := ""
+    for _, rel := range rels.Items {
+        if rel.ID == wb.Sheets[0].RID {
+            sheetPath = rel.Target
+        }
+    }
+    if sheetPath == "" {
+        return nil, fmt.Errorf("%w: first sheet not found", ErrInvalidXLSX)
+    }
+    if strings.HasPrefix(sheetPath, "/") {
+        sheetPath = strings.TrimPrefix(sheetPath, "/")
+    } else {
+        sheetPath = path.Join("xl", sheetPath)
+    }
+
+    // The shared strings part is absent when a workbook has no text cells.
+    var shared xlsxSharedStrings
+    if _, ok := files["xl/sharedStrings.xml"]; ok {
+        if err := decodeXLSXPart(files, "xl/sharedStrings.xml", &shared); err != nil {
+            return nil, err
+        }
+    }
+
+    var sheet xlsxSheet
+    if err := decodeXLSXPart(files, sheetPath, &sheet); err != nil {
+        return nil, err
+    }
+
+    if len(sheet.Rows) > xlsxMaxRows {
+        return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidXLSX, xlsxMaxRows)
+    }
+    rows := make([]Row, 0, len(sheet.Rows))
+    line, cells := 0, 0
+    for _, sr := range sheet.Rows {
+        line++
+        if sr.R > 0 {
+            line = sr.R
+        }
+        if line > xlsxMaxRows {
+            return nil, fmt.Errorf("%w: row %d is out of range", ErrInvalidXLSX, line)
+        }
+        row := Row{Line: line}
+        for _, c := range sr.Cells {
+            col := len(row.Cells)
+            if c.R != "" {
+                if col, err = xlsxColumn(c.R); err != nil {
+                    return nil, err
+                }
+            }
+            if col >= len(row.Cells) {
+                // Cells skipped in the row are padded, so they count too
+                if cells += col + 1 - len(row.Cells); cells > xlsxMaxCells {
+                    return nil, fmt.Errorf("%w: more than %d cells", ErrInvalidXLSX, xlsxMaxCells)
+                }
+            }
+            for len(row.Cells) <= col {
+                row.Cells = append(row.Cells, "")
+            }
+
+            switch c.T {
+            case "s":
+                var idx int
+                if _, err := fmt.Sscan(c.V, &idx); err != nil || idx < 0 || idx >= len(shared.Items) {
+                    return nil, fmt.Errorf("%w: bad shared string index in %s", ErrInvalidXLSX, c.R)
+                }
+                row.Cells[col] = shared.Items[idx].String()
+            case "inlineStr":
+                row.Cells[col] = c.Inline.String()
+            default:
+                row.Cells[col] = c.V
+            }
+        }
+        rows = append(rows, row)
+    }
+    return rows, nil
+}
+
+func decodeXLSXPart(files map[string]*zip.File, name string, v interface{}) error {
+    f, ok := files[name]
+    if !ok {
+        return fmt.Errorf("%w: missing %s", ErrInvalidXLSX, name)
+    }
+    if f.UncompressedSize64 > xlsxMaxPartSize {
+        return fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidXLSX, name, xlsxMaxPartSize)
+    }
+    rc, err := f.Open()
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrInvalidXLSX, err)
+    }
+    defer rc.Close()
+    // The declared size is not to be trusted, so the part is cut off at the cap
+    // and fails to decode rather than being read to the end
+    if err := xml.NewDecoder(io.LimitReader(rc, xlsxMaxPartSize)).Decode(v); err != nil {
+        return fmt.Errorf("%w: %s: %v", ErrInvalidXLSX, name, err)
+    }
+    return nil
+}
+
+// xlsxColumn converts the letters of a cell reference ("AB12") to a 0-based
+// column index, up to XFD.
+func xlsxColumn(ref string) (int, error) {
+    col := 0
+    n := 0
+    for _, ch := range ref {
+        if ch < 'A' || ch > 'Z' {
+            break
+        }
+        col = col*26 + int(ch-'A'+1)
+        n++
+        if col > xlsxMaxColumns {
+            return 0, fmt.Errorf("%w: column of cell reference %q is out of range", ErrInvalidXLSX, ref)
+        }
+    }
+    if n == 0 {
+        return 0, fmt.Errorf("%w: bad cell reference %q", ErrInvalidXLSX, ref)
+    }
+    return col - 1, nil
+}
@@ -0,0 +1,7 @@
+-- users.email stays unique across soft-deleted rows: the email of a deleted
+-- user is reserved until it is purged, so that it can always be restored.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE orders ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+CREATE INDEX idx_orders_deleted_at ON orders (deleted_at);
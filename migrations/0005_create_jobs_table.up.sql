@@ -0,0 +1,15 @@
+CREATE TABLE jobs (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    subject_id INTEGER NOT NULL,
+    requested_by INTEGER,
+    result_path TEXT,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_jobs_status ON jobs (status);
+CREATE INDEX idx_jobs_subject_id ON jobs (subject_id);